@@ -0,0 +1,41 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// An AttributeCase determines how MarshalWithOptions spells attribute names.
+type AttributeCase int
+
+const (
+	// CaseCanonical spells attribute names as in RFC 6265 (e.g. "HttpOnly",
+	// "Max-Age"). This is the default.
+	CaseCanonical AttributeCase = iota
+
+	// CaseLower spells attribute names in lowercase (e.g. "httponly",
+	// "max-age").
+	CaseLower
+
+	// CasePreserveParsed spells attribute names as recorded in the cookie's
+	// AttrCase map, falling back to their canonical form.
+	CasePreserveParsed
+)
+
+// attrName returns the spelling of the attribute with the given canonical
+// name, according to opts.
+func attrName(c *Cookie, name string, opts *MarshalOptions) string {
+	if opts == nil {
+		return name
+	}
+
+	switch {
+	case opts.Case == CaseLower:
+		return strings.ToLower(name)
+	case opts.Case == CasePreserveParsed || opts.PreserveCase:
+		if s, ok := c.AttrCase[name]; ok {
+			return s
+		}
+	}
+
+	return name
+}
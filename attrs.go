@@ -0,0 +1,102 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// attrHandler holds the functions registered for a custom attribute.
+type attrHandler struct {
+	name    string
+	parse   func(*Cookie, string) error
+	marshal func(*Cookie) (string, bool)
+}
+
+var (
+	attrHandlers    = make(map[string]*attrHandler)
+	attrHandlerList []*attrHandler
+)
+
+// builtinAttrs lists the (lowercase) names of attributes handled by the
+// package itself.
+var builtinAttrs = []string{"domain", "expires", "httponly", "max-age", "partitioned", "path", "samesite", "secure"}
+
+// RegisterAttr registers handlers for a cookie attribute the package doesn't
+// support natively. Matching attributes encountered by Parse are passed to
+// the parse function (along with their value) instead of being stored in the
+// cookie's Unparsed slice. When a cookie is marshaled, the marshal function
+// is called and, if its second return value is true, an attribute with the
+// returned value is emitted. An empty value produces a valueless attribute.
+//
+// Attribute names are case-insensitive. RegisterAttr is not safe for
+// concurrent use, and should be called during initialization. It panics if
+// an attribute is registered twice, if name is invalid or refers to an
+// attribute handled by the package, or if either function is nil.
+func RegisterAttr(name string, parse func(*Cookie, string) error, marshal func(*Cookie) (string, bool)) {
+	if !isValidName(name) {
+		panic("cookie: RegisterAttr called with invalid name " + name)
+	}
+	if parse == nil || marshal == nil {
+		panic("cookie: RegisterAttr called with nil function for attribute " + name)
+	}
+
+	key := strings.ToLower(name)
+
+	for _, builtin := range builtinAttrs {
+		if key == builtin {
+			panic("cookie: RegisterAttr called for built-in attribute " + name)
+		}
+	}
+	if _, dup := attrHandlers[key]; dup {
+		panic("cookie: RegisterAttr called twice for attribute " + name)
+	}
+
+	h := &attrHandler{
+		name:    name,
+		parse:   parse,
+		marshal: marshal,
+	}
+
+	attrHandlers[key] = h
+	attrHandlerList = append(attrHandlerList, h)
+}
+
+// unregisterAttr removes the handlers registered for an attribute, so that
+// tests can clean up after themselves.
+func unregisterAttr(name string) {
+	key := strings.ToLower(name)
+
+	h, ok := attrHandlers[key]
+	if !ok {
+		return
+	}
+	delete(attrHandlers, key)
+
+	for i, other := range attrHandlerList {
+		if other == h {
+			attrHandlerList = append(attrHandlerList[:i:i], attrHandlerList[i+1:]...)
+			break
+		}
+	}
+}
+
+// lookupAttr returns the handler registered for an attribute, if any.
+func lookupAttr(key string) *attrHandler {
+	if len(attrHandlers) == 0 {
+		return nil
+	}
+
+	// Lowercase short keys on the stack; the compiler doesn't allocate when
+	// a byte slice is converted to a string for a map lookup.
+	var buf [32]byte
+	if len(key) > len(buf) {
+		return attrHandlers[strings.ToLower(key)]
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		buf[i] = c
+	}
+	return attrHandlers[string(buf[:len(key)])]
+}
@@ -0,0 +1,88 @@
+package cookie
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRegisterAttr(t *testing.T) {
+	// Record the value of the Priority attribute, keyed by cookie name.
+	priorities := make(map[string]string)
+
+	RegisterAttr("Priority",
+		func(c *Cookie, val string) error {
+			if val != "Low" && val != "Medium" && val != "High" {
+				return fmt.Errorf("invalid Priority value: %q", val)
+			}
+			priorities[c.Name] = val
+			return nil
+		},
+		func(c *Cookie) (string, bool) {
+			val, ok := priorities[c.Name]
+			return val, ok
+		},
+	)
+	defer unregisterAttr("Priority")
+
+	c, err := Parse("prio=1; priority=High; Path=/; Other")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := &Cookie{Name: "prio", Value: "1", Path: "/", Unparsed: []string{"Other"}}
+	if !reflect.DeepEqual(c, want) || priorities["prio"] != "High" {
+		t.Errorf("Parse with registered attribute:")
+		t.Errorf("  got  %+v, %q", c, priorities["prio"])
+		t.Errorf("  want %+v, %q", want, "High")
+	}
+
+	if _, err := Parse("prio=1; Priority=Urgent"); err == nil {
+		t.Errorf("Parse with invalid registered attribute value succeeded")
+	}
+
+	out, err := c.Marshal(true)
+	if want := "prio=1; Path=/; Priority=High; Other"; out != want || err != nil {
+		t.Errorf("Marshal with registered attribute:")
+		t.Errorf("  got  %#q, %+v", out, err)
+		t.Errorf("  want %#q, %+v", want, nil)
+	}
+}
+
+func TestRegisterAttrPanics(t *testing.T) {
+	parse := func(*Cookie, string) error { return nil }
+	marshal := func(*Cookie) (string, bool) { return "", false }
+
+	RegisterAttr("Taken", parse, marshal)
+	defer unregisterAttr("Taken")
+
+	tests := []struct {
+		name    string
+		parse   func(*Cookie, string) error
+		marshal func(*Cookie) (string, bool)
+	}{
+		{"in valid", parse, marshal},
+		{"Path", parse, marshal},
+		{"taken", parse, marshal},
+		{"Nil", nil, marshal},
+		{"Nil", parse, nil},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterAttr(%q) didn't panic", test.name)
+					unregisterAttr(test.name)
+				}
+			}()
+			RegisterAttr(test.name, test.parse, test.marshal)
+		}()
+	}
+
+	// Unregistered attributes are stored in Unparsed again.
+	unregisterAttr("Taken")
+	if c, err := Parse("a=b; Taken=1"); err != nil || !reflect.DeepEqual(c.Unparsed, []string{"Taken=1"}) {
+		t.Errorf("Parse after unregistering = %+v, %v", c, err)
+	}
+}
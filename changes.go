@@ -0,0 +1,195 @@
+package cookie
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	errInvalidChange = errors.New("invalid change")
+)
+
+// maxTombstones is the number of removals a jar remembers for ChangesSince.
+// Older removals are forgotten once twice as many have accumulated.
+const maxTombstones = 1024
+
+// A Change describes a single modification of a Jar's contents.
+type Change struct {
+	// Revision at which the change was made.
+	Rev uint64
+
+	// Removed is true if the entry was deleted from the jar, in which case
+	// only the Domain, Path and Name fields of Entry are meaningful.
+	Removed bool
+
+	// Evicted is true if the entry was removed to keep the jar within its
+	// limits. It is only reported to observers, and never set in changes
+	// returned by ChangesSince.
+	Evicted bool
+
+	Entry Entry
+}
+
+// Rev returns the jar's current revision. The revision is incremented every
+// time an entry is added, updated or removed.
+func (j *Jar) Rev() uint64 {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.rev
+}
+
+// ChangesSince returns all changes made to the jar after revision rev, in
+// the order they were made, along with the jar's current revision. Only the
+// most recent change to each entry is reported.
+//
+// Passing the returned revision to a subsequent call will yield only the
+// changes made in between. ChangesSince(0) describes the jar's full state.
+//
+// Only the most recent removals are remembered, so revisions older than the
+// one returned by Horizon can't be answered completely. A caller which has
+// fallen that far behind should start over from ChangesSince(0), replacing
+// its copy, for example using ReplaceFrom.
+func (j *Jar) ChangesSince(rev uint64) ([]Change, uint64) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var changes []Change
+
+	for _, bucket := range j.ent {
+		for _, entry := range bucket {
+			if entry.Rev > rev {
+				changes = append(changes, Change{Rev: entry.Rev, Entry: entry.clone()})
+			}
+		}
+	}
+
+	// Removals are of no interest to a caller starting from scratch.
+	if rev > 0 {
+		for _, entry := range j.dead {
+			if entry.Rev > rev {
+				changes = append(changes, Change{Rev: entry.Rev, Removed: true, Entry: entry.clone()})
+			}
+		}
+	}
+
+	sort.Sort(changeList(changes))
+
+	return changes, j.rev
+}
+
+// Horizon returns the oldest revision, other than 0, that ChangesSince can
+// report all subsequent changes for.
+func (j *Jar) Horizon() uint64 {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.pruned
+}
+
+// pruneDead forgets all but the maxTombstones most recent removals.
+func (j *Jar) pruneDead() {
+	revs := make([]uint64, 0, len(j.dead))
+	for _, entry := range j.dead {
+		revs = append(revs, entry.Rev)
+	}
+	sort.Slice(revs, func(a, b int) bool { return revs[a] < revs[b] })
+
+	cutoff := revs[len(revs)-maxTombstones-1]
+	for key, entry := range j.dead {
+		if entry.Rev <= cutoff {
+			delete(j.dead, key)
+		}
+	}
+	j.pruned = cutoff
+}
+
+// Apply applies a list of changes, typically obtained by calling ChangesSince
+// on another jar. Changes are applied in order, and receive new revision
+// numbers in this jar.
+func (j *Jar) Apply(changes []Change) (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	profile("Jar.Apply", func() { err = j.apply(changes) })
+	return
+}
+
+// apply implements Apply.
+func (j *Jar) apply(changes []Change) error {
+	for i := range changes {
+		if changes[i].Entry.Name == "" || changes[i].Entry.Domain == "" {
+			return errInvalidChange
+		}
+	}
+
+	now := j.clockNow()
+
+	for i := range changes {
+		entry := &jarEntry{Entry: changes[i].Entry}
+		entry.Root = j.root(entry.Domain)
+		entry.Key = j.entryKey(entry)
+
+		if changes[i].Removed {
+			j.remove(entry, now)
+		} else {
+			j.set(entry, now)
+		}
+	}
+
+	return nil
+}
+
+// Observe registers a function to be called after every modification of the
+// jar, with a description of the changes made. Changes committed as part of a
+// transaction are reported in a single call.
+//
+// Observers are called with the jar locked, so that they see changes in the
+// order they were made, and must not call the jar's methods.
+func (j *Jar) Observe(fn func(changes []Change)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.observers = append(j.observers, fn)
+}
+
+// notify reports a change to the jar's observers, or adds it to the current
+// batch if a transaction is being committed.
+func (j *Jar) notify(c Change) {
+	if len(j.observers) == 0 {
+		return
+	}
+
+	if j.batching {
+		j.batch = append(j.batch, c)
+		return
+	}
+
+	changes := []Change{c}
+	for _, fn := range j.observers {
+		fn(changes)
+	}
+}
+
+// batched runs fn, reporting all changes it makes to observers in a single
+// call.
+func (j *Jar) batched(fn func()) {
+	j.batching = true
+	fn()
+
+	changes := j.batch
+	j.batch, j.batching = nil, false
+
+	if len(changes) > 0 {
+		for _, obs := range j.observers {
+			obs(changes)
+		}
+	}
+}
+
+// changeList implements sort.Interface, ordering changes by revision.
+type changeList []Change
+
+func (l changeList) Len() int           { return len(l) }
+func (l changeList) Less(i, j int) bool { return l[i].Rev < l[j].Rev }
+func (l changeList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -4,6 +4,7 @@ const (
 	nameChar = 1 << iota
 	valueChar
 	attrChar
+	octetChar
 )
 
 var chars = [256]uint8{}
@@ -28,5 +29,65 @@ func init() {
 		if c != ';' {
 			chars[c] |= attrChar
 		}
+
+		// The cookie-octet characters of RFC 6265, which exclude spaces and
+		// commas.
+		if c != ' ' && c != '"' && c != ',' && c != ';' && c != '\\' {
+			chars[c] |= octetChar
+		}
+	}
+}
+
+// A Class is a set of characters. Classes may be combined using the bitwise
+// OR operator, in which case a character belongs to the resulting class if it
+// belongs to any of its constituents.
+type Class uint8
+
+const (
+	// TokenClass contains the characters valid in a cookie name.
+	TokenClass Class = nameChar
+
+	// ValueClass contains the characters valid in a cookie value.
+	ValueClass Class = valueChar
+
+	// AttrClass contains the characters valid in a cookie attribute.
+	AttrClass Class = attrChar
+
+	// OctetClass contains the characters RFC 6265 strictly allows in a
+	// cookie value. Unlike ValueClass, it excludes spaces and commas.
+	OctetClass Class = octetChar
+)
+
+// Contains returns true if b is a member of the character class.
+func (cls Class) Contains(b byte) bool {
+	return chars[b]&uint8(cls) != 0
+}
+
+// Valid returns true if s is non-empty and consists solely of characters
+// which are members of the character class.
+func (cls Class) Valid(s string) bool {
+	if len(s) == 0 {
+		return false
 	}
+	for i := 0; i < len(s); i++ {
+		if chars[s[i]]&uint8(cls) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsTokenChar returns true if b is valid in a cookie name.
+func IsTokenChar(b byte) bool {
+	return chars[b]&nameChar != 0
+}
+
+// IsValueChar returns true if b is valid in a cookie value.
+func IsValueChar(b byte) bool {
+	return chars[b]&valueChar != 0
+}
+
+// IsAttrChar returns true if b is valid in a cookie attribute.
+func IsAttrChar(b byte) bool {
+	return chars[b]&attrChar != 0
 }
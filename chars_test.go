@@ -0,0 +1,45 @@
+package cookie
+
+import (
+	"testing"
+)
+
+var classTests = []struct {
+	cls Class
+	in  string
+	out bool
+}{
+	{TokenClass, "foo", true},
+	{TokenClass, "foo=bar", false},
+	{TokenClass, "", false},
+	{ValueClass, "a b,c=d", true},
+	{ValueClass, `a"b`, false},
+	{AttrClass, `Path=/"x"`, true},
+	{AttrClass, "a;b", false},
+	{OctetClass, "a=b/c", true},
+	{OctetClass, "a b", false},
+	{OctetClass, "a,b", false},
+	{TokenClass | ValueClass, "a=b", true},
+	{TokenClass | ValueClass, "a;b", false},
+}
+
+func TestClass(t *testing.T) {
+	for _, test := range classTests {
+		if out := test.cls.Valid(test.in); out != test.out {
+			t.Errorf("Class(%d).Valid(%q):", test.cls, test.in)
+			t.Errorf("  got  %v", out)
+			t.Errorf("  want %v", test.out)
+		}
+	}
+}
+
+func TestIsChar(t *testing.T) {
+	for c := 0; c < 256; c++ {
+		b := byte(c)
+		if IsTokenChar(b) != TokenClass.Contains(b) ||
+			IsValueChar(b) != ValueClass.Contains(b) ||
+			IsAttrChar(b) != AttrClass.Contains(b) {
+			t.Errorf("mismatch between Is*Char and Class.Contains for %q", b)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// A ValueKind describes what a cookie value appears to contain, as guessed by
+// ClassifyValue.
+type ValueKind int
+
+const (
+	// ValueOther means the value matched none of the other kinds.
+	ValueOther ValueKind = iota
+
+	// ValueJWT is a JSON Web Token in compact serialization, such as
+	// "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln".
+	ValueJWT
+
+	// ValueUUID is a UUID in its canonical textual form, such as
+	// "123e4567-e89b-12d3-a456-426614174000".
+	ValueUUID
+
+	// ValueJSON is a JSON object or array, either as is or URL-encoded,
+	// such as "%7B%22id%22%3A1%7D".
+	ValueJSON
+
+	// ValueBase64 is a blob of at least 16 characters in standard or URL-safe
+	// base64 encoding, mixing upper and lower case letters. Hexadecimal
+	// strings and plain words are not classified as base64.
+	ValueBase64
+)
+
+// String returns the name of k.
+func (k ValueKind) String() string {
+	switch k {
+	case ValueJWT:
+		return "JWT"
+	case ValueUUID:
+		return "UUID"
+	case ValueJSON:
+		return "JSON"
+	case ValueBase64:
+		return "base64"
+	}
+	return "other"
+}
+
+// minBase64 is the minimum length of values classified as ValueBase64, as
+// shorter ones are easily confused with ordinary words and identifiers.
+const minBase64 = 16
+
+// ClassifyValue guesses what kind of data a cookie value holds. It relies on
+// heuristics, so it's meant for reports and audits rather than for making
+// security decisions. Surrounding double quotes are ignored.
+func ClassifyValue(value string) ValueKind {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+
+	switch {
+	case isJWT(value):
+		return ValueJWT
+	case isUUID(value):
+		return ValueUUID
+	case isJSON(value):
+		return ValueJSON
+	case isBase64(value):
+		return ValueBase64
+	}
+	return ValueOther
+}
+
+// isJWT returns true if s consists of three base64url segments, the first of
+// which decodes to a JSON object with an "alg" member.
+func isJWT(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	for _, part := range parts[1:] {
+		if _, err := base64.RawURLEncoding.DecodeString(part); err != nil {
+			return false
+		}
+	}
+
+	var h struct {
+		Alg *string `json:"alg"`
+	}
+	return json.Unmarshal(header, &h) == nil && h.Alg != nil
+}
+
+// isUUID returns true if s is a UUID in 8-4-4-4-12 hexadecimal form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isHex returns true if c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isJSON returns true if s, or s with URL encoding removed, is a JSON object
+// or array.
+func isJSON(s string) bool {
+	if strings.Contains(s, "%") {
+		if u, err := url.QueryUnescape(s); err == nil {
+			s = u
+		}
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '{' && s[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+// isBase64 returns true if s is long enough, mixes upper and lower case
+// letters, and decodes as either standard or URL-safe base64, with or without
+// padding.
+func isBase64(s string) bool {
+	if len(s) < minBase64 {
+		return false
+	}
+	if strings.ToLower(s) == s || strings.ToUpper(s) == s {
+		return false
+	}
+
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	}
+	for _, enc := range encodings {
+		if _, err := enc.DecodeString(s); err == nil {
+			return true
+		}
+	}
+	return false
+}
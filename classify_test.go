@@ -0,0 +1,37 @@
+package cookie
+
+import (
+	"testing"
+)
+
+var classifyTests = []struct {
+	in   string
+	kind ValueKind
+}{
+	{"", ValueOther},
+	{"hello", ValueOther},
+	{"abcdefghijklmnopqrstuvwxyz", ValueOther},
+	{"0123456789abcdef0123456789abcdef", ValueOther},
+	{"123e4567-e89b-12d3-a456-426614174000", ValueUUID},
+	{"123E4567-E89B-12D3-A456-426614174000", ValueUUID},
+	{"123e4567-e89b-12d3-a456-42661417400g", ValueOther},
+	{"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln", ValueJWT},
+	{"eyJmb28iOiJiYXIifQ.eyJzdWIiOiIxIn0.c2ln", ValueOther},
+	{"a.b.c", ValueOther},
+	{"%7B%22id%22%3A1%7D", ValueJSON},
+	{`{"id":1}`, ValueJSON},
+	{"%5B1%2C2%5D", ValueJSON},
+	{"%7Bnot json", ValueOther},
+	{"SGVsbG8sIFdvcmxkIQ==", ValueBase64},
+	{"SGVsbG8sIFdvcmxkIQ", ValueBase64},
+	{"a-_bCdEfGhIjKlMnOp", ValueBase64},
+	{`"SGVsbG8sIFdvcmxkIQ=="`, ValueBase64},
+}
+
+func TestClassifyValue(t *testing.T) {
+	for _, test := range classifyTests {
+		if kind := ClassifyValue(test.in); kind != test.kind {
+			t.Errorf("ClassifyValue(%#q) = %v, want %v", test.in, kind, test.kind)
+		}
+	}
+}
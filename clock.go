@@ -0,0 +1,53 @@
+package cookie
+
+import (
+	"time"
+)
+
+// SetClock sets the function used to obtain the current time by the jar's
+// convenience methods, such as CookiesNow and SetCookieNow, by Save and by
+// the jar's HTTPAdapter. Passing nil restores the default, the package-level
+// Now function. Methods taking an explicit time are unaffected.
+func (j *Jar) SetClock(clock func() time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.clock = clock
+}
+
+// now returns the current time according to the jar's clock.
+func (j *Jar) now() time.Time {
+	j.mu.RLock()
+	clock := j.clock
+	j.mu.RUnlock()
+
+	if clock == nil {
+		return Now()
+	}
+	return clock()
+}
+
+// clockNow is like now, but for use with the jar's lock held.
+func (j *Jar) clockNow() time.Time {
+	if j.clock == nil {
+		return Now()
+	}
+	return j.clock()
+}
+
+// CookiesNow is like Cookies, but uses the jar's clock for the current time.
+func (j *Jar) CookiesNow(scheme, host, path string) ([]*Cookie, error) {
+	return j.Cookies(scheme, host, path, j.now())
+}
+
+// CookieHeaderNow is like CookieHeader, but uses the jar's clock for the
+// current time.
+func (j *Jar) CookieHeaderNow(scheme, host, path string) (string, error) {
+	return j.CookieHeader(scheme, host, path, j.now())
+}
+
+// SetCookieNow is like SetCookie, but uses the jar's clock for the current
+// time.
+func (j *Jar) SetCookieNow(scheme, host, path string, c *Cookie) error {
+	return j.SetCookie(scheme, host, path, c, j.now())
+}
@@ -0,0 +1,104 @@
+package cookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrDecrypt is returned by EncryptedCodec.Open when a cookie's value wasn't
+// sealed by any of the codec's keys, or was tampered with.
+var ErrDecrypt = errors.New("cookie: value can't be decrypted")
+
+var (
+	errNoCodecKey = errors.New("no encryption key")
+)
+
+// An EncryptedCodec stores payloads in cookies encrypted and authenticated
+// using AES-GCM, so that session data can be kept on the client without the
+// client being able to read or modify it. A sealed value consists of a
+// random nonce followed by the ciphertext, base64url-encoded. The cookie's
+// name is authenticated as well, so that a sealed value can't be moved to
+// another cookie.
+//
+// Browsers limit cookies to around 4096 bytes, so payloads should be kept
+// well below 3000 bytes once the encoding's overhead is accounted for.
+type EncryptedCodec struct {
+	// Keys used to open cookies, the first of which is also used to seal
+	// them. Keys must be 16, 24 or 32 random bytes long, selecting AES-128,
+	// AES-192 or AES-256. To rotate keys, prepend a new key and keep the old
+	// ones around until the cookies sealed with them have expired.
+	Keys [][]byte
+}
+
+// NewEncryptedCodec returns an EncryptedCodec sealing cookies with key, and
+// opening them with key or any of the older keys.
+func NewEncryptedCodec(key []byte, old ...[]byte) *EncryptedCodec {
+	return &EncryptedCodec{Keys: append([][]byte{key}, old...)}
+}
+
+// Seal returns a cookie with the given name, holding payload encrypted using
+// the first key. Other attributes, such as Path and Secure, are left for the
+// caller to set.
+func (ec *EncryptedCodec) Seal(name string, payload []byte) (*Cookie, error) {
+	if !isValidName(name) {
+		return nil, errInvalidName
+	}
+	if len(ec.Keys) == 0 {
+		return nil, errNoCodecKey
+	}
+
+	aead, err := newGCM(ec.Keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nonce, nonce, payload, []byte(name))
+
+	return &Cookie{
+		Name:  name,
+		Value: base64.RawURLEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// Open decrypts the payload of a cookie sealed by Seal, trying each of the
+// keys in turn. It returns ErrDecrypt if none of them succeeds.
+func (ec *EncryptedCodec) Open(c *Cookie) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	for _, key := range ec.Keys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrDecrypt
+		}
+
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, []byte(c.Name)); err == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, ErrDecrypt
+}
+
+// newGCM returns an AES-GCM AEAD using key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
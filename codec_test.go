@@ -0,0 +1,82 @@
+package cookie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptedCodec(t *testing.T) {
+	oldKey := []byte("fedcba9876543210fedcba9876543210")
+	newKey := []byte("0123456789abcdef0123456789abcdef")
+
+	old := NewEncryptedCodec(oldKey)
+	ec := NewEncryptedCodec(newKey, oldKey)
+
+	payload := []byte(`{"user":42,"roles":["admin"]}`)
+
+	c, err := ec.Seal("session", payload)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if c.Name != "session" || !isValidValue(c.Value) || bytes.Contains([]byte(c.Value), []byte("admin")) {
+		t.Fatalf("Seal returned %+v", c)
+	}
+
+	// Sealed values must survive a round trip through a header.
+	raw, err := c.Marshal(true)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if c, err = Parse(raw); err != nil {
+		t.Fatalf("Parse(%#q): %v", raw, err)
+	}
+
+	if out, err := ec.Open(c); err != nil || !bytes.Equal(out, payload) {
+		t.Errorf("Open returned %q, %v", out, err)
+	}
+
+	// Sealing twice uses different nonces.
+	if again, _ := ec.Seal("session", payload); again.Value == c.Value {
+		t.Errorf("Seal reused a nonce")
+	}
+
+	// Cookies sealed with a retired key can still be opened, but only by
+	// codecs which know it.
+	c, _ = old.Seal("session", payload)
+	if out, err := ec.Open(c); err != nil || !bytes.Equal(out, payload) {
+		t.Errorf("Open of cookie sealed with an old key returned %q, %v", out, err)
+	}
+	if _, err := NewEncryptedCodec(newKey).Open(c); err != ErrDecrypt {
+		t.Errorf("Open of cookie sealed with an unknown key returned %v", err)
+	}
+
+	c, _ = ec.Seal("session", payload)
+	flipped := []byte(c.Value)
+	if flipped[20] == 'A' {
+		flipped[20] = 'B'
+	} else {
+		flipped[20] = 'A'
+	}
+
+	tampered := []*Cookie{
+		{Name: "session", Value: string(flipped)},
+		{Name: "other", Value: c.Value},
+		{Name: "session", Value: "AAAA"},
+		{Name: "session", Value: "not base64!"},
+	}
+	for _, c := range tampered {
+		if _, err := ec.Open(c); err != ErrDecrypt {
+			t.Errorf("Open(%s=%s) returned %v", c.Name, c.Value, err)
+		}
+	}
+
+	if _, err := (&EncryptedCodec{}).Seal("a", payload); err == nil {
+		t.Errorf("Seal succeeded without a key")
+	}
+	if _, err := NewEncryptedCodec([]byte("short")).Seal("a", payload); err == nil {
+		t.Errorf("Seal succeeded with an invalid key")
+	}
+	if _, err := ec.Seal("a b", payload); err == nil {
+		t.Errorf("Seal succeeded with an invalid name")
+	}
+}
@@ -0,0 +1,163 @@
+package cookie
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJarConcurrent(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.EnablePathIndex()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			host := "d" + strconv.Itoa(g%2) + ".com"
+			for i := 0; i < 200; i++ {
+				j.SetCookie("http", host, "/", &Cookie{Name: "c" + strconv.Itoa(i%10), Value: strconv.Itoa(i)}, testNow)
+				j.Cookies("http", host, "/", testNow)
+				j.ReadOnly().Cookies("http", host, "/", testNow)
+				j.Stats(testNow)
+				j.ChangesSince(0)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := j.Stats(testNow).Total(); n != 20 {
+		t.Errorf("jar holds %d entries, want 20", n)
+	}
+}
+
+// TestJarRouterConcurrent adds routes while cookies are being dispatched. Run
+// it with -race.
+func TestJarRouterConcurrent(t *testing.T) {
+	r := NewJarRouter(testPSL{}, NewJar(testPSL{}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			host := "d" + strconv.Itoa(g) + ".com"
+			for i := 0; i < 50; i++ {
+				if i == 25 {
+					r.Route(host, NewJar(testPSL{}))
+				}
+				r.SetCookie("http", host, "/", &Cookie{Name: "c", Value: strconv.Itoa(i)}, testNow)
+				r.Cookies("http", host, "/", testNow)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for g := 0; g < 8; g++ {
+		j, _ := r.Jar("d" + strconv.Itoa(g) + ".com")
+		if cookies, _ := j.Cookies("http", "d"+strconv.Itoa(g)+".com", "/", testNow); len(cookies) != 1 || cookies[0].Value != "49" {
+			t.Errorf("routed jar for d%d.com holds %+v, want c=49", g, cookies)
+		}
+	}
+}
+
+// TestRangeConcurrent modifies a jar from several goroutines, and from the
+// callbacks of Range itself, while ranging over it. Run it with -race.
+func TestRangeConcurrent(t *testing.T) {
+	j := NewJar(testPSL{})
+	for i := 0; i < 10; i++ {
+		j.SetCookie("http", "d"+strconv.Itoa(i)+".com", "/", &Cookie{Name: "c", Value: "0"}, testNow)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				host := "d" + strconv.Itoa((g+i)%10) + ".com"
+
+				// Every iteration sees the ten cookies, each of which
+				// exists throughout, whatever else happens meanwhile.
+				var n int
+				j.Range(func(e Entry) bool {
+					if e.Name == "c" {
+						n++
+					}
+					j.SetCookie("http", host, "/", &Cookie{Name: "c", Value: strconv.Itoa(i)}, testNow)
+					j.SetCookie("http", host, "/", &Cookie{Name: "t" + strconv.Itoa(g), Value: "1"}, testNow)
+					j.Remove(host, "/", "t"+strconv.Itoa(g))
+					j.Cookies("http", host, "/", testNow)
+					return true
+				})
+				if n != 10 {
+					t.Errorf("Range visited %d cookies named c, want 10", n)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// mutexWait returns the total time goroutines have spent blocked on mutexes.
+func mutexWait() float64 {
+	s := []metrics.Sample{{Name: "/sync/mutex/wait/total:seconds"}}
+	metrics.Read(s)
+	if s[0].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return s[0].Value.Float64()
+}
+
+// BenchmarkJarConcurrent runs a mix of Cookies and SetCookie calls from many
+// goroutines, either all hitting the same domain (hot) or spread over many
+// (cold). Besides throughput, it reports the time spent waiting for locks per
+// operation, which is what a sharded design would reduce.
+func BenchmarkJarConcurrent(b *testing.B) {
+	const domains = 256
+
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		for _, hot := range []bool{true, false} {
+			for _, writes := range []int{1, 10, 50} {
+				name := fmt.Sprintf("g=%d/hot=%v/writes=%d%%", goroutines, hot, writes)
+				b.Run(name, func(b *testing.B) {
+					j := NewJar(testPSL{})
+					for i := 0; i < domains; i++ {
+						for k := 0; k < 10; k++ {
+							j.SetCookie("http", "d"+strconv.Itoa(i)+".com", "/", &Cookie{Name: "c" + strconv.Itoa(k), Value: "1"}, testNow)
+						}
+					}
+
+					var seq int64
+					b.SetParallelism(goroutines)
+					b.ResetTimer()
+					wait := mutexWait()
+
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							n := atomic.AddInt64(&seq, 1)
+
+							host := "d0.com"
+							if !hot {
+								host = "d" + strconv.Itoa(int(n%domains)) + ".com"
+							}
+
+							if int(n%100) < writes {
+								j.SetCookie("http", host, "/", &Cookie{Name: "c" + strconv.Itoa(int(n%10)), Value: strconv.Itoa(int(n))}, testNow)
+							} else {
+								j.Cookies("http", host, "/", testNow)
+							}
+						}
+					})
+
+					b.ReportMetric((mutexWait()-wait)*1e9/float64(b.N), "wait-ns/op")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cookie
+
+import (
+	"time"
+)
+
+// Now returns the current time. It is used by package-level helpers when the
+// caller hasn't supplied a time, and may be replaced (during initialization)
+// to control the package's notion of time, for example in tests.
+var Now = time.Now
+
+// ExpiresLocation is used to interpret Expires values with a time zone
+// abbreviation other than "GMT" or "UTC", which time.Parse can't resolve
+// unless it happens to match the local time zone. This is typically a concern
+// in containers without time zone data. It may be replaced during
+// initialization.
+var ExpiresLocation = time.UTC
+
+// Layouts accepted for Expires values.
+var expiresLayouts = []string{
+	time.RFC1123,
+	"Mon, 02-Jan-2006 15:04:05 MST",
+}
+
+// parseExpires parses the value of an Expires attribute. Values in one of
+// the usual layouts are parsed taking their time zone into account, and
+// anything else using the more tolerant algorithm of RFC 6265, which treats
+// all times as UTC.
+func parseExpires(val string) (time.Time, bool) {
+	for _, layout := range expiresLayouts {
+		// TODO: This is not as efficient as it could be.
+		t, err := time.ParseInLocation(layout, val, ExpiresLocation)
+		if err != nil {
+			continue
+		}
+
+		// Zone abbreviations not known to ParseInLocation result in a
+		// fabricated location with a zero offset. Reinterpret those times in
+		// the fallback location.
+		if name, offset := t.Zone(); offset == 0 && name != "GMT" && name != "UTC" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(),
+				t.Second(), t.Nanosecond(), ExpiresLocation)
+		}
+
+		return t, true
+	}
+
+	return parseCookieDate(val)
+}
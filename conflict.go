@@ -0,0 +1,69 @@
+package cookie
+
+// A ConflictPolicy determines how a jar treats a host-only cookie and a
+// domain cookie sharing a name, domain and path, such as those set by
+// example.com with "sid=1" and "sid=2; Domain=example.com".
+type ConflictPolicy int
+
+const (
+	// ConflictReplace treats the two as the same cookie, so that setting
+	// either replaces the other, as specified by RFC 6265, section 5.3.
+	// This is the default.
+	ConflictReplace ConflictPolicy = iota
+
+	// ConflictKeepBoth stores the two as separate cookies, as Chrome does.
+	// Both are sent to matching hosts, ordered like any other cookies, and
+	// each counts toward the jar's limits.
+	ConflictKeepBoth
+)
+
+// SetConflictPolicy sets the jar's conflict policy. When switching to
+// ConflictReplace, the most recently stored of any conflicting entries is
+// kept and the others are removed.
+func (j *Jar) SetConflictPolicy(p ConflictPolicy) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if p == j.conflict {
+		return
+	}
+	j.conflict = p
+
+	now := j.clockNow()
+
+	for _, entry := range j.sorted() {
+		key := j.entryKey(entry)
+		if key == entry.Key {
+			continue
+		}
+
+		// Move the entry to its new key, unless a newer entry already
+		// claimed it.
+		if other, ok := j.ent[entry.Root][key]; ok && other.Rev > entry.Rev {
+			j.remove(entry, now)
+			continue
+		}
+
+		j.preserve(entry.Root)
+		delete(j.ent[entry.Root], entry.Key)
+		if j.paths != nil {
+			j.paths.remove(entry)
+		}
+
+		if other, ok := j.ent[entry.Root][key]; ok {
+			j.remove(other, now)
+		}
+
+		entry.Key = key
+		j.insert(entry)
+	}
+}
+
+// key returns the key identifying an entry in its bucket.
+func (j *Jar) key(domain, path, name string, hostOnly bool) string {
+	key := domain + ";" + path + ";" + name
+	if hostOnly && j.conflict == ConflictKeepBoth {
+		key += ";host"
+	}
+	return key
+}
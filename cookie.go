@@ -1,14 +1,45 @@
 package cookie
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+	"unsafe"
 )
 
+// ErrMaxAgeOverflow is passed to ParseOptions.Warn when a cookie's Max-Age
+// value exceeded MaxAgeLimit and had to be clamped. The cookie is parsed
+// successfully regardless.
+var ErrMaxAgeOverflow = errors.New("cookie.Parse: Max-Age value overflows")
+
+// ErrTruncated is returned by ParseWithOptions when its input exceeds the
+// budget set by ParseOptions.MaxBytes or ParseOptions.MaxAttrs.
+var ErrTruncated = errors.New("cookie.Parse: input exceeds budget")
+
+// ErrDuplicateAttr is returned by ParseWithOptions when an attribute occurs
+// more than once, and ParseOptions.Duplicates is DuplicateReject.
+var ErrDuplicateAttr = errors.New("cookie.Parse: duplicate attribute")
+
+// ErrSameSiteNoneInsecure is returned by Marshal for cookies with SameSite set
+// to SameSiteNone but without the Secure attribute, which browsers drop. See
+// MarshalOptions.SecureSameSiteNone.
+var ErrSameSiteNoneInsecure = errors.New("cookie.Marshal: SameSite=None requires Secure")
+
+// MaxAgeLimit is the largest Max-Age value, in seconds, Parse will accept
+// without clamping. The default is the longest duration representable by a
+// time.Duration.
+var MaxAgeLimit int64 = math.MaxInt64 / int64(time.Second)
+
+// maxInt is the largest value representable by an int.
+const maxInt = int64(^uint(0) >> 1)
+
 // The Cookie struct describes an HTTP cookie.
 type Cookie struct {
 	Name    string
@@ -24,113 +55,820 @@ type Cookie struct {
 	// was specified, and negative values are used to express "Max-Age=0".
 	MaxAge int
 
+	// SameSite restriction. SameSiteDefault means no SameSite attribute was
+	// specified, or that its value wasn't recognized.
+	SameSite SameSite
+
+	// Partitioned is true if the cookie should be stored separately for each
+	// top-level site it's set in the context of, as proposed by CHIPS.
+	Partitioned bool
+
 	// Unparsed attributes.
 	Unparsed []string
+
+	// Original spelling of attribute names which differed from their
+	// canonical form, keyed by canonical name (e.g. "HttpOnly"). Only
+	// populated by ParseWithOptions when ParseOptions.PreserveCase is set.
+	AttrCase map[string]string
+
+	// Order in which the cookie's attributes appeared, as canonical names
+	// (e.g. "Max-Age"). Attributes stored in Unparsed are recorded as empty
+	// strings. Only populated by ParseWithOptions when
+	// ParseOptions.PreserveOrder is set.
+	AttrOrder []string
 }
 
-// Marshal serializes a Cookie.
+// MarshalOptions controls the behavior of MarshalWithOptions.
+type MarshalOptions struct {
+	// EmitBothExpiries makes sure cookies with either an Expires or a Max-Age
+	// attribute are marshaled with both, for the benefit of clients which
+	// only understand one of them. The missing attribute is computed relative
+	// to Now. Clients which understand both give Max-Age precedence, so when
+	// both are set they are emitted as is.
+	EmitBothExpiries bool
+
+	// MaxLifetime, if positive, caps the lifetime of marshaled cookies. An
+	// Expires value later than MaxLifetime after Now, such as ExpiresNever,
+	// and a Max-Age value exceeding it are clamped, so that "never expire"
+	// serializes consistently regardless of client limits. Limits under a
+	// second are rounded up to one.
+	MaxLifetime time.Duration
+
+	// Case controls the spelling of attribute names.
+	Case AttributeCase
+
+	// PreserveCase is equivalent to setting Case to CasePreserveParsed.
+	PreserveCase bool
+
+	// PreserveOrder makes Marshal emit attributes in the order recorded in
+	// the cookie's AttrOrder slice. Attributes missing from it follow in the
+	// usual order.
+	PreserveOrder bool
+
+	// BackslashEscapes allows values containing double quotes and
+	// backslashes, which are emitted as a quoted string with each of them
+	// preceded by a backslash, as parsed by ParseOptions.BackslashEscapes.
+	BackslashEscapes bool
+
+	// EncodePercent percent-encodes the bytes in cookie values which aren't
+	// allowed by RFC 6265 (including spaces, commas and '%' itself), as
+	// decoded by ParseOptions.DecodePercent.
+	EncodePercent bool
+
+	// SecureSameSiteNone adds the Secure attribute to cookies with SameSite
+	// set to SameSiteNone which lack it, rather than failing with
+	// ErrSameSiteNoneInsecure.
+	SecureSameSiteNone bool
+
+	// Lint makes Marshal fail if the cookie's value, domain or path contains
+	// what appears to be an unsubstituted template placeholder, such as
+	// "${user}", "{{.Token}}" or "%s".
+	Lint bool
+
+	// MaxSize is a soft limit on the length of a marshaled cookie, such as
+	// the Set-Cookie size ceiling of a CDN. Zero means no limit.
+	MaxSize int
+
+	// OnOversize decides how to handle a cookie which exceeds MaxSize once
+	// marshaled, given the cookie and its marshaled size. If nil, such
+	// cookies are rejected.
+	OnOversize func(c *Cookie, size int) OversizeAction
+
+	// The current time. If zero, the package-level Now function is used.
+	Now time.Time
+}
+
+// now returns opts.Now, or the current time if it isn't set.
+func (opts *MarshalOptions) now() time.Time {
+	if opts.Now.IsZero() {
+		return Now()
+	}
+	return opts.Now
+}
+
+// Marshal serializes a Cookie. The cookie's name and value are validated
+// whether or not attrs is true, so an error is returned for a nil cookie, an
+// empty name or value, or one containing invalid characters.
 func (c *Cookie) Marshal(attrs bool) (string, error) {
+	return c.MarshalWithOptions(attrs, nil)
+}
+
+// MarshalWithOptions is like Marshal, but allows the caller to control the
+// output. Passing nil options is equivalent to calling Marshal.
+func (c *Cookie) MarshalWithOptions(attrs bool, opts *MarshalOptions) (string, error) {
+	s, err := c.marshal(attrs, opts)
+	if err != nil || opts == nil || opts.MaxSize <= 0 || len(s) <= opts.MaxSize {
+		return s, err
+	}
+	return c.marshalOversize(s, c.oversizeAction(len(s), opts), attrs, opts)
+}
+
+// AppendMarshal is like Marshal, but appends the serialized cookie to dst and
+// returns the extended buffer. No memory is allocated if dst has enough
+// spare capacity, except by the marshal functions of registered attributes.
+// If the cookie can't be marshaled, dst is returned unchanged along with the
+// error, as by the Append functions of package strconv.
+func (c *Cookie) AppendMarshal(dst []byte, attrs bool) ([]byte, error) {
+	b, err := c.appendMarshal(dst, attrs, nil)
+	if err != nil {
+		return dst, err
+	}
+	return b, nil
+}
+
+// maxPooledBuffer is the capacity beyond which MarshalTo doesn't return
+// buffers to the pool.
+const maxPooledBuffer = 64 << 10
+
+// marshalBuffers holds buffers used by MarshalTo.
+var marshalBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// MarshalTo is like Marshal, but writes the serialized cookie to w, using a
+// pooled buffer rather than allocating a string. It returns the number of
+// bytes written.
+func (c *Cookie) MarshalTo(w io.Writer, attrs bool) (int64, error) {
+	buf := marshalBuffers.Get().(*[]byte)
+
+	b, err := c.appendMarshal((*buf)[:0], attrs, nil)
+	if err != nil {
+		marshalBuffers.Put(buf)
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+
+	// Don't hold on to buffers grown by unusually large cookies.
+	if cap(b) <= maxPooledBuffer {
+		*buf = b
+		marshalBuffers.Put(buf)
+	}
+
+	return int64(n), err
+}
+
+// marshal implements MarshalWithOptions, disregarding size limits.
+func (c *Cookie) marshal(attrs bool, opts *MarshalOptions) (string, error) {
+	b, err := c.appendMarshal(nil, attrs, opts)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// appendMarshal implements marshal, appending the serialized cookie to b.
+func (c *Cookie) appendMarshal(b []byte, attrs bool, opts *MarshalOptions) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("cookie.Marshal: nil cookie")
+	}
+
+	if opts != nil && opts.MaxLifetime > 0 && attrs {
+		c = withMaxLifetime(c, opts.MaxLifetime, opts.now())
+	}
+
+	if opts != nil && opts.EmitBothExpiries && attrs {
+		c = withBothExpiries(c, opts.now())
+	}
+
+	if opts != nil && opts.SecureSameSiteNone && attrs && c.SameSite == SameSiteNone && !c.Secure {
+		e := *c
+		e.Secure = true
+		c = &e
+	}
+
+	if opts != nil && opts.Lint {
+		if err := lintPlaceholders(c); err != nil {
+			return nil, err
+		}
+	}
+
 	if !isValidName(c.Name) {
-		return "", fmt.Errorf("cookie.Marshal: invalid cookie name: %q", c.Name)
+		return nil, fmt.Errorf("cookie.Marshal: invalid cookie name: %q", c.Name)
 	}
-	if !isValidValue(c.Value) {
-		return "", fmt.Errorf("cookie.Marshal: invalid cookie value: %q", c.Value)
+
+	// Begin by writing the name and value.
+	var ok bool
+	b = append(b, c.Name...)
+	b = append(b, '=')
+	if b, ok = appendValue(b, c.Value, opts); !ok {
+		return nil, fmt.Errorf("cookie.Marshal: invalid cookie value: %q", c.Value)
 	}
 
 	// Short path for when the user doesn't want the cookie's attributes.
 	if !attrs {
-		if shouldQuoteValue(c.Value) {
-			return c.Name + `="` + c.Value + `"`, nil
-		} else {
-			return c.Name + `=` + c.Value, nil
+		return b, nil
+	}
+
+	if reason := checkPrefix(c, true); reason != "" {
+		return nil, fmt.Errorf("cookie.Marshal: %s", reason)
+	}
+
+	// Cookie attributes, in their original order if requested.
+	var done map[string]bool
+	var unparsed int
+	var err error
+
+	if opts != nil && opts.PreserveOrder && len(c.AttrOrder) > 0 {
+		done = make(map[string]bool, len(c.AttrOrder))
+		for _, name := range c.AttrOrder {
+			if name == "" {
+				if unparsed < len(c.Unparsed) {
+					if b, err = appendUnparsed(b, c.Unparsed[unparsed]); err != nil {
+						return nil, err
+					}
+					unparsed++
+				}
+				continue
+			}
+			if done[name] {
+				continue
+			}
+			done[name] = true
+			if b, err = appendAttr(b, c, name, opts); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	// Begin by writing the name and value.
-	b := new(bytes.Buffer)
-	b.WriteString(c.Name)
-	b.WriteByte('=')
-
-	if shouldQuoteValue(c.Value) {
-		b.WriteByte('"')
-		b.WriteString(c.Value)
-		b.WriteByte('"')
-	} else {
-		b.WriteString(c.Value)
+	for _, name := range attrOrder {
+		if done[name] {
+			continue
+		}
+		if b, err = appendAttr(b, c, name, opts); err != nil {
+			return nil, err
+		}
 	}
 
-	// Cookie attributes.
-	if c.Domain != "" {
-		if !isValidDomain(c.Domain) {
-			return "", fmt.Errorf("cookie.Marshal: invalid Domain value: %q", c.Domain)
+	// Registered attributes.
+	for _, h := range attrHandlerList {
+		if done[h.name] {
+			continue
+		}
+		if b, err = appendRegistered(b, c, h, opts); err != nil {
+			return nil, err
 		}
-		b.WriteString("; Domain=")
-		b.WriteString(c.Domain)
 	}
 
-	if c.Path != "" {
-		if !isValidAttr(c.Path) {
-			return "", fmt.Errorf("cookie.Marshal: invalid Path value: %q", c.Path)
+	// Unparsed attributes.
+	for _, attr := range c.Unparsed[unparsed:] {
+		if b, err = appendUnparsed(b, attr); err != nil {
+			return nil, err
 		}
-		b.WriteString("; Path=")
-		b.WriteString(c.Path)
 	}
 
-	if c.Expires.Unix() > 0 {
-		// TODO: This is not as efficient as it could be.
-		b.WriteString("; Expires=")
-		b.WriteString(c.Expires.UTC().Format(time.RFC1123))
+	return b, nil
+}
+
+// appendValue validates a cookie value, and appends it to b as it should
+// appear in a header, quoted if necessary.
+func appendValue(b []byte, v string, opts *MarshalOptions) ([]byte, bool) {
+	if opts != nil && opts.EncodePercent {
+		v = escapeValue(v)
+	}
+	if opts != nil && opts.BackslashEscapes && strings.ContainsAny(v, `"\`) {
+		q, ok := quoteValue(v)
+		return append(b, q...), ok
 	}
+	if !isValidValue(v) {
+		return b, false
+	}
+	if shouldQuoteValue(v) {
+		b = append(b, '"')
+		b = append(b, v...)
+		return append(b, '"'), true
+	}
+	return append(b, v...), true
+}
 
-	if c.MaxAge > 0 {
-		// TODO: This is not as efficient as it could be.
-		b.WriteString("; Max-Age=")
-		b.WriteString(strconv.Itoa(c.MaxAge))
-	} else if c.MaxAge < 0 {
-		b.WriteString("; Max-Age=0")
+// attrOrder is the order in which Marshal emits built-in attributes.
+var attrOrder = []string{"Domain", "Path", "Expires", "Max-Age", "HttpOnly", "Secure", "SameSite", "Partitioned"}
+
+// appendAttr appends the attribute with the given canonical name to b, if the
+// cookie has it.
+func appendAttr(b []byte, c *Cookie, name string, opts *MarshalOptions) ([]byte, error) {
+	switch name {
+	case "Domain":
+		if c.Domain != "" {
+			if !isValidDomain(c.Domain) {
+				return nil, fmt.Errorf("cookie.Marshal: invalid Domain value: %q", c.Domain)
+			}
+			b = appendAttrName(b, c, "Domain", opts)
+			b = append(b, '=')
+			b = append(b, c.Domain...)
+		}
+
+	case "Path":
+		if c.Path != "" {
+			if !isValidAttr(c.Path) {
+				return nil, fmt.Errorf("cookie.Marshal: invalid Path value: %q", c.Path)
+			}
+			b = appendAttrName(b, c, "Path", opts)
+			b = append(b, '=')
+			b = append(b, c.Path...)
+		}
+
+	case "Expires":
+		if c.Expires.Unix() > 0 {
+			b = appendAttrName(b, c, "Expires", opts)
+			b = append(b, '=')
+			b = c.Expires.UTC().AppendFormat(b, time.RFC1123)
+		}
+
+	case "Max-Age":
+		if c.MaxAge > 0 {
+			b = appendAttrName(b, c, "Max-Age", opts)
+			b = append(b, '=')
+			b = strconv.AppendInt(b, int64(c.MaxAge), 10)
+		} else if c.MaxAge < 0 {
+			b = appendAttrName(b, c, "Max-Age", opts)
+			b = append(b, "=0"...)
+		}
+
+	case "HttpOnly":
+		if c.HttpOnly {
+			b = appendAttrName(b, c, "HttpOnly", opts)
+		}
+
+	case "Secure":
+		if c.Secure {
+			b = appendAttrName(b, c, "Secure", opts)
+		}
+
+	case "SameSite":
+		if c.SameSite != SameSiteDefault {
+			// Browsers drop SameSite=None cookies lacking the Secure
+			// attribute.
+			if c.SameSite == SameSiteNone && !c.Secure {
+				return nil, ErrSameSiteNoneInsecure
+			}
+			b = appendAttrName(b, c, "SameSite", opts)
+			b = append(b, '=')
+			b = append(b, c.SameSite.String()...)
+		}
+
+	case "Partitioned":
+		if c.Partitioned {
+			// Browsers drop partitioned cookies lacking the Secure
+			// attribute.
+			if !c.Secure {
+				return nil, fmt.Errorf("cookie.Marshal: Partitioned requires Secure")
+			}
+			b = appendAttrName(b, c, "Partitioned", opts)
+		}
+
+	default:
+		if h := lookupAttr(name); h != nil {
+			return appendRegistered(b, c, h, opts)
+		}
+	}
+
+	return b, nil
+}
+
+// appendRegistered appends a registered attribute to b, if the cookie has it.
+func appendRegistered(b []byte, c *Cookie, h *attrHandler, opts *MarshalOptions) ([]byte, error) {
+	val, ok := h.marshal(c)
+	if !ok {
+		return b, nil
+	}
+	if val != "" && !isValidValue(val) {
+		return nil, fmt.Errorf("cookie.Marshal: invalid %s value: %q", h.name, val)
 	}
+	b = appendAttrName(b, c, h.name, opts)
+	if val != "" {
+		b = append(b, '=')
+		b = append(b, val...)
+	}
+	return b, nil
+}
 
-	if c.HttpOnly {
-		b.WriteString("; HttpOnly")
+// appendUnparsed validates an unparsed attribute, and appends it to b.
+func appendUnparsed(b []byte, attr string) ([]byte, error) {
+	if !isValidAttr(attr) {
+		return nil, fmt.Errorf("cookie.Marshal: invalid attribute: %q", attr)
 	}
+	b = append(b, "; "...)
+	return append(b, attr...), nil
+}
+
+// appendAttrName appends the separator preceding an attribute, followed by
+// the attribute's name.
+func appendAttrName(b []byte, c *Cookie, name string, opts *MarshalOptions) []byte {
+	b = append(b, "; "...)
+	return append(b, attrName(c, name, opts)...)
+}
+
+// withBothExpiries returns a copy of c with its Expires or MaxAge field
+// derived from the other, if only one of them is set.
+func withBothExpiries(c *Cookie, now time.Time) *Cookie {
+	hasExpires := c.Expires.Unix() > 0
 
-	if c.Secure {
-		b.WriteString("; Secure")
+	if hasExpires == (c.MaxAge != 0) {
+		return c
 	}
 
-	// Unparsed attributes.
-	for _, attr := range c.Unparsed {
-		if !isValidAttr(attr) {
-			return "", fmt.Errorf("cookie.Marshal: invalid attribute: %q", attr)
+	e := *c
+
+	if hasExpires {
+		// Round up, so the cookie doesn't expire early.
+		d := e.Expires.Sub(now)
+		if d <= 0 {
+			e.MaxAge = -1
+		} else {
+			e.MaxAge = int((d + time.Second - 1) / time.Second)
 		}
-		b.WriteString("; ")
-		b.WriteString(attr)
+	} else if e.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(e.MaxAge) * time.Second)
+	} else {
+		e.Expires = time.Unix(1, 0)
 	}
 
-	return b.String(), nil
+	return &e
 }
 
 // shouldQuoteValue returns true if the cookie value should be quoted. Matches
 // the behavior of package net/http (see http://golang.org/issue/7243).
 func shouldQuoteValue(s string) bool {
+	if s == "" {
+		return false
+	}
 	first, last := s[0], s[len(s)-1]
 	return first == ' ' || first == ',' || last == ' ' || last == ','
 }
 
-// Parse parses an HTTP cookie. In the case of a "Cookie" header, each
-// semicolon-delimited part should be parsed separately.
+// A DuplicatePolicy determines how ParseWithOptions treats attributes which
+// occur more than once, such as in "a=b; Path=/x; Path=/y".
+type DuplicatePolicy int
+
+const (
+	// DuplicateLast keeps the last occurrence, as RFC 6265 requires.
+	DuplicateLast DuplicatePolicy = iota
+	// DuplicateFirst keeps the first occurrence, ignoring later ones.
+	DuplicateFirst
+	// DuplicateReject rejects the cookie with ErrDuplicateAttr.
+	DuplicateReject
+)
+
+// ParseOptions controls the behavior of ParseWithOptions.
+type ParseOptions struct {
+	// Lenient enables workarounds for common mistakes made by servers, such
+	// as including a port number in the Domain attribute.
+	Lenient bool
+
+	// Strict enforces the syntax of RFC 6265. Cookie values must consist of
+	// cookie-octet characters, so that spaces and commas are rejected even
+	// within quotes, and malformed attributes are rejected rather than
+	// stored in Unparsed: attribute names must be tokens, Max-Age must be a
+	// plain number, Path must begin with '/', SameSite must be Strict, Lax
+	// or None, and HttpOnly, Secure and Partitioned must not have a value.
+	// Well-formed extension attributes are still stored in Unparsed. Strict
+	// takes precedence over Lenient.
+	Strict bool
+
+	// Warn, if non-nil, is called with a description of the problem every
+	// time a lenient workaround is applied, and with ErrMaxAgeOverflow every
+	// time a Max-Age value is clamped.
+	Warn func(err error)
+
+	// PreserveCase records the original spelling of known attribute names
+	// in the cookie's AttrCase map.
+	PreserveCase bool
+
+	// PreserveOrder records the order in which attributes appear in the
+	// cookie's AttrOrder slice.
+	PreserveOrder bool
+
+	// BackslashEscapes decodes the backslash escapes \" and \\ in quoted
+	// cookie values, as allowed by the quoted-string syntax of RFC 2616 and
+	// still emitted by some legacy servers. The resulting value may contain
+	// double quotes and backslashes, which MarshalOptions.BackslashEscapes
+	// escapes again.
+	BackslashEscapes bool
+
+	// DecodePercent decodes percent-encoded bytes (%XX) in cookie values, as
+	// produced by frameworks which URL-encode their cookies. Values with a
+	// malformed escape sequence, or which don't decode to valid UTF-8, are
+	// rejected. MarshalOptions.EncodePercent reverses the decoding.
+	DecodePercent bool
+
+	// RejectIPDomain rejects Domain attributes which are IP addresses with
+	// ErrIPDomain. Such domains are accepted by default, although they only
+	// match an identical host.
+	RejectIPDomain bool
+
+	// UTF8 accepts cookie values containing non-ASCII characters, as long as
+	// they're valid UTF-8, as browsers do. Such values can only be marshaled
+	// again using MarshalOptions.EncodePercent. UTF8 has no effect in strict
+	// mode.
+	UTF8 bool
+
+	// Duplicates determines which occurrence of a repeated attribute takes
+	// effect, or whether the cookie is rejected. It applies to the standard
+	// attributes; repeated unknown attributes are all stored in Unparsed.
+	Duplicates DuplicatePolicy
+
+	// MaxBytes, if positive, is the maximum length of the input. Longer
+	// input is rejected with ErrTruncated before any parsing takes place.
+	MaxBytes int
+
+	// MaxAttrs, if positive, is the maximum number of semicolon-delimited
+	// attributes, counting empty ones. Parsing stops with ErrTruncated as
+	// soon as it's exceeded. Together with MaxBytes, it bounds the work spent
+	// on untrusted input.
+	MaxAttrs int
+}
+
+// recordAttr records the position and spelling of an attribute, if the
+// options ask for it.
+func (opts *ParseOptions) recordAttr(c *Cookie, canonical, key string) {
+	if opts.PreserveOrder {
+		c.AttrOrder = append(c.AttrOrder, canonical)
+	}
+	if !opts.PreserveCase || key == canonical || canonical == "" {
+		return
+	}
+	if c.AttrCase == nil {
+		c.AttrCase = make(map[string]string)
+	}
+	c.AttrCase[canonical] = key
+}
+
+// attrSet is a set of standard attributes, used to detect duplicates.
+type attrSet uint16
+
+const (
+	attrDomain attrSet = 1 << iota
+	attrExpires
+	attrHttpOnly
+	attrMaxAge
+	attrPartitioned
+	attrPath
+	attrSameSite
+	attrSecure
+)
+
+// duplicate adds attr to seen. If it was already present, it returns true if
+// the attribute should be skipped according to the duplicate policy, along
+// with the error to reject the cookie with, if any.
+func (opts *ParseOptions) duplicate(seen *attrSet, attr attrSet) (bool, error) {
+	if *seen&attr == 0 {
+		*seen |= attr
+		return false, nil
+	}
+
+	switch opts.Duplicates {
+	case DuplicateFirst:
+		return true, nil
+	case DuplicateReject:
+		return true, ErrDuplicateAttr
+	}
+	return false, nil
+}
+
+// lenient returns true if lenient workarounds should be applied.
+func (opts *ParseOptions) lenient() bool {
+	return opts.Lenient && !opts.Strict
+}
+
+// warn reports a problem worked around in lenient mode.
+func (opts *ParseOptions) warn(err error) {
+	if opts.Warn != nil {
+		opts.Warn(err)
+	}
+}
+
+// defaultParseOptions are used when ParseWithOptions is called with nil
+// options.
+var defaultParseOptions = &ParseOptions{}
+
+// Parse parses the value of a "Set-Cookie" header. Use ParseRequestHeader to
+// parse the value of a "Cookie" request header. Max-Age values exceeding
+// MaxAgeLimit are clamped; use ParseWithOptions with a Warn function to find
+// out when that happens. The returned cookie is the only memory allocated,
+// unless the cookie has unknown attributes; ParseInto avoids even that.
 func Parse(raw string) (*Cookie, error) {
-	s := strings.IndexByte(raw, ';')
-	if s < 0 {
-		s = len(raw)
+	if c, ok := cachedParse(raw); ok {
+		return c, nil
+	}
+
+	// Parse is too hot to wrap in a closure unless it's being profiled.
+	if profileHook.Load() != nil {
+		var c *Cookie
+		var err error
+		profile("Parse", func() { c, err = parse(raw) })
+		return c, err
+	}
+
+	return parse(raw)
+}
+
+// parse implements Parse, after the cache has been consulted.
+func parse(raw string) (*Cookie, error) {
+	c, err := ParseWithOptions(raw, nil)
+	if err == nil {
+		cacheParse(raw, c)
+	}
+
+	return c, err
+}
+
+// ParseSetCookie is an alias for Parse, named to contrast with
+// ParseRequestHeader.
+func ParseSetCookie(raw string) (*Cookie, error) {
+	return Parse(raw)
+}
+
+// ParseStrict is like Parse, but enforces the syntax of RFC 6265, as
+// described for ParseOptions.Strict.
+func ParseStrict(raw string) (*Cookie, error) {
+	return ParseWithOptions(raw, strictParseOptions)
+}
+
+// strictParseOptions are used by ParseStrict.
+var strictParseOptions = &ParseOptions{Strict: true}
+
+// ParseBytes is like Parse, but parses a header value held in a byte slice,
+// as returned by many HTTP libraries. The cookie doesn't retain b. Rather than
+// copying all of b, only the strings kept by the cookie are copied, using a
+// single allocation. Parse functions of attributes registered with
+// RegisterAttr may hold on to their values, so b is copied as a whole once any
+// have been registered.
+func ParseBytes(b []byte) (*Cookie, error) {
+	if len(attrHandlerList) > 0 {
+		return ParseWithOptions(string(b), nil)
+	}
+
+	c := new(Cookie)
+	if err := ParseInto(c, unsafe.String(unsafe.SliceData(b), len(b)), nil); err != nil {
+		return nil, err
+	}
+	c.detach()
+	return c, nil
+}
+
+// detach copies the strings held by a cookie into a buffer of its own, so it
+// no longer refers to the memory it was parsed from.
+func (c *Cookie) detach() {
+	n := len(c.Name) + len(c.Value) + len(c.Domain) + len(c.Path)
+	for _, s := range c.Unparsed {
+		n += len(s)
+	}
+
+	var sb strings.Builder
+	sb.Grow(n)
+
+	fields := [4]*string{&c.Name, &c.Value, &c.Domain, &c.Path}
+	for _, f := range fields {
+		sb.WriteString(*f)
+	}
+	for _, s := range c.Unparsed {
+		sb.WriteString(s)
+	}
+
+	buf := sb.String()
+	next := func(s string) string {
+		s, buf = buf[:len(s)], buf[len(s):]
+		return s
+	}
+	for _, f := range fields {
+		*f = next(*f)
+	}
+	for i, s := range c.Unparsed {
+		c.Unparsed[i] = next(s)
 	}
+}
+
+// ParseWithOptions is like Parse, but allows the caller to control the
+// parser's behavior. Passing nil options is equivalent to calling Parse.
+// It's the parser's single entry point: Parse, ParseStrict and ParseBytes
+// are shorthands for common profiles.
+func ParseWithOptions(raw string, opts *ParseOptions) (*Cookie, error) {
+	c := new(Cookie)
+	if err := ParseInto(c, raw, opts); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseInto is like ParseWithOptions, but parses into an existing cookie,
+// which is reset first, except for the capacity of its Unparsed slice. It
+// allows hot paths to reuse a Cookie, in which case no memory is allocated
+// unless the cookie has unknown attributes or the options call for it. On
+// error, the contents of c are unspecified.
+func ParseInto(c *Cookie, raw string, opts *ParseOptions) error {
+	if opts == nil {
+		opts = defaultParseOptions
+	}
+
+	*c = Cookie{Unparsed: c.Unparsed[:0]}
+
+	if err := checkSize(raw, opts); err != nil {
+		return err
+	}
+
+	end := nextSemicolon(raw, 0)
+
+	if err := parsePair(c, trim(raw[:end]), opts); err != nil {
+		return err
+	}
+
+	var seen attrSet
+
+	it := attrIter{raw: raw, end: end}
+	for it.next(opts) {
+		if err := parseAttr(c, it.part, opts, &seen); err != nil {
+			return err
+		}
+	}
+
+	return it.err
+}
+
+// checkSize returns ErrTruncated if raw exceeds opts.MaxBytes.
+func checkSize(raw string, opts *ParseOptions) error {
+	if opts.MaxBytes > 0 && len(raw) > opts.MaxBytes {
+		return ErrTruncated
+	}
+	return nil
+}
+
+// An attrIter iterates over the attributes of a "Set-Cookie" header value in
+// a single pass, each one delimited by the semicolon found at end. It's
+// shared by ParseInto and ParseFunc.
+type attrIter struct {
+	raw   string
+	end   int
+	attrs int
+	part  string
+	err   error
+}
 
-	part := trim(raw[:s])
+// next advances to the next non-empty attribute, storing it in part. It
+// returns false once there are no more attributes, or if opts.MaxAttrs is
+// exceeded, in which case err is set to ErrTruncated.
+func (it *attrIter) next(opts *ParseOptions) bool {
+	for it.end < len(it.raw) {
+		start := it.end + 1
+		it.end = nextSemicolon(it.raw, start)
 
+		if it.attrs++; opts.MaxAttrs > 0 && it.attrs > opts.MaxAttrs {
+			it.err = ErrTruncated
+			return false
+		}
+
+		// Ignore empty attributes, such as those left by a trailing semicolon.
+		if it.part = trim(it.raw[start:it.end]); it.part != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nextSemicolon returns the index of the first semicolon in s at or after
+// offset i, or len(s) if there is none.
+func nextSemicolon(s string, i int) int {
+	if n := strings.IndexByte(s[i:], ';'); n >= 0 {
+		return i + n
+	}
+	return len(s)
+}
+
+// Errors returned when parsing a cookie's name and value. They're allocated
+// up front, as the Scanner may encounter them often.
+var (
+	errParseMissing = errors.New("cookie.Parse: missing cookie value")
+	errParseName    = errors.New("cookie.Parse: invalid cookie name")
+	errParseValue   = errors.New("cookie.Parse: invalid cookie value")
+	errParseEscape  = errors.New("cookie.Parse: invalid escape sequence in cookie value")
+)
+
+// Errors returned when parsing a cookie's attributes, and warnings passed to
+// ParseOptions.Warn. Like those above, they're allocated up front, so parsing
+// an invalid attribute doesn't allocate.
+var (
+	errParseAttr     = errors.New("cookie.Parse: invalid attribute")
+	errParseDomain   = errors.New("cookie.Parse: invalid Domain value")
+	errParseExpires  = errors.New("cookie.Parse: invalid Expires value")
+	errParseMaxAge   = errors.New("cookie.Parse: invalid Max-Age value")
+	errParsePath     = errors.New("cookie.Parse: invalid Path value")
+	errParseSameSite = errors.New("cookie.Parse: invalid SameSite value")
+
+	errWarnSpace = errors.New("cookie.Parse: whitespace around '=' in attribute")
+	errWarnPort  = errors.New("cookie.Parse: port in Domain value")
+	errWarnIDN   = errors.New("cookie.Parse: non-ASCII Domain value")
+)
+
+// parsePair parses a cookie's name and value into c.
+func parsePair(c *Cookie, part string, opts *ParseOptions) error {
 	// Separate the cookie's name and value.
 	eq := strings.IndexByte(part, '=')
 	if eq < 0 {
-		return nil, fmt.Errorf("cookie.Parse: missing cookie value")
+		return errParseMissing
 	}
 
 	var name = part[:eq]
@@ -139,35 +877,32 @@ func Parse(raw string) (*Cookie, error) {
 
 	name, ok = parseName(name)
 	if !ok {
-		return nil, fmt.Errorf("cookie.Parse: invalid cookie name")
+		return errParseName
 	}
 
-	value, ok = parseValue(value)
-	if !ok {
-		return nil, fmt.Errorf("cookie.Parse: invalid cookie value")
+	if opts.Strict && !isStrictValue(value) {
+		return errParseValue
 	}
 
-	c := &Cookie{
-		Name:  name,
-		Value: value,
+	if opts.BackslashEscapes && isQuoted(value) {
+		value, ok = unquoteValue(value[1 : len(value)-1])
+	} else if opts.UTF8 && !opts.Strict && !isASCII(value) {
+		value, ok = parseUTF8Value(value)
+	} else {
+		value, ok = parseValue(value)
+	}
+	if !ok {
+		return errParseValue
 	}
 
-	// Parse the cookie's attributes.
-	for 0 <= s && s < len(raw) {
-		raw = raw[s+1:]
-
-		if s = strings.IndexByte(raw, ';'); s < 0 {
-			part = trim(raw)
-		} else {
-			part = trim(raw[:s])
-		}
-
-		if err := parseAttr(c, part); err != nil {
-			return nil, err
+	if opts.DecodePercent {
+		if value, ok = unescapeValue(value); !ok || !utf8.ValidString(value) {
+			return errParseEscape
 		}
 	}
 
-	return c, nil
+	c.Name, c.Value = name, value
+	return nil
 }
 
 // parseName validates and parses a cookie name.
@@ -180,21 +915,13 @@ func parseName(raw string) (string, bool) {
 
 // isValidName returns true if the input string is a valid cookie name.
 func isValidName(s string) bool {
-	if len(s) == 0 {
-		return false
-	}
-	for i := 0; i < len(s); i++ {
-		if chars[s[i]]&nameChar == 0 {
-			return false
-		}
-	}
-	return true
+	return TokenClass.Valid(s)
 }
 
 // parseValue validates and parses a cookie name.
 func parseValue(raw string) (string, bool) {
 	// Unwrap quotes.
-	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+	if isQuoted(raw) {
 		raw = raw[1 : len(raw)-1]
 	}
 
@@ -206,42 +933,100 @@ func parseValue(raw string) (string, bool) {
 	return raw, true
 }
 
-// isValidValue returns true if the input string is a valid cookie value.
-func isValidValue(s string) bool {
-	if len(s) == 0 {
-		return false
+// parseUTF8Value is like parseValue, but also accepts non-ASCII characters
+// encoded as valid UTF-8.
+func parseUTF8Value(raw string) (string, bool) {
+	if isQuoted(raw) {
+		raw = raw[1 : len(raw)-1]
 	}
+
+	if raw == "" || !utf8.ValidString(raw) {
+		return "", false
+	}
+	for i := 0; i < len(raw); i++ {
+		if raw[i] < utf8.RuneSelf && !IsValueChar(raw[i]) {
+			return "", false
+		}
+	}
+
+	return raw, true
+}
+
+// isQuoted returns true if s is surrounded by double quotes.
+func isQuoted(s string) bool {
+	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
+}
+
+// isStrictValue returns true if raw, possibly surrounded by double quotes,
+// consists of RFC 6265 cookie-octet characters.
+func isStrictValue(raw string) bool {
+	if isQuoted(raw) {
+		raw = raw[1 : len(raw)-1]
+	}
+	return OctetClass.Valid(raw)
+}
+
+// isDigits returns true if s is non-empty and consists of decimal digits
+// only.
+func isDigits(s string) bool {
 	for i := 0; i < len(s); i++ {
-		if chars[s[i]]&valueChar == 0 {
+		if s[i] < '0' || s[i] > '9' {
 			return false
 		}
 	}
-	return true
+	return s != ""
 }
 
-// parseAttr validates and parses a cookie attribute, then adding it to a
-// Cookie struct.
-func parseAttr(c *Cookie, raw string) error {
-	if !isValidAttr(raw) {
-		fmt.Errorf("cookie.Parse: invalid attribute: %q", raw)
-	}
+// isValidValue returns true if the input string is a valid cookie value.
+func isValidValue(s string) bool {
+	return ValueClass.Valid(s)
+}
 
-	// Separate the value from the key, if there is one.
-	var key, val string
-	var ok bool
+// splitAttr validates a cookie attribute and separates its key from its
+// value, which is empty if there is none.
+func splitAttr(raw string, opts *ParseOptions) (key, val string, err error) {
+	// In lenient mode, non-ASCII Domain values are converted to punycode
+	// by parseAttr, so they get a pass here.
+	idn := opts.lenient() && isUnicodeDomainAttr(raw)
+
+	if !isValidAttr(raw) && !idn {
+		return "", "", errParseAttr
+	}
 
 	if eq := strings.IndexByte(raw, '='); eq >= 0 {
-		key = raw[:eq]
-		val, ok = parseValue(raw[eq+1:])
-		if !ok {
-			fmt.Errorf("cookie.Parse: invalid attribute: %q", raw)
+		key, val = raw[:eq], raw[eq+1:]
+
+		// Some servers surround the '=' with whitespace.
+		if opts.lenient() {
+			if k, v := trim(key), trim(val); k != key || v != val {
+				opts.warn(errWarnSpace)
+				key, val = k, v
+			}
+		}
+
+		if val != "" && !idn {
+			var ok bool
+			if val, ok = parseValue(val); !ok {
+				return "", "", errParseAttr
+			}
 		}
 	} else {
 		key = raw
 	}
 
-	if key == "" {
-		fmt.Errorf("cookie.Parse: invalid attribute: %q", raw)
+	if key == "" || opts.Strict && !isValidName(key) {
+		return "", "", errParseAttr
+	}
+
+	return key, val, nil
+}
+
+// parseAttr validates and parses a cookie attribute, then adding it to a
+// Cookie struct.
+func parseAttr(c *Cookie, raw string, opts *ParseOptions, seen *attrSet) error {
+	key, val, err := splitAttr(raw, opts)
+	if err != nil {
+		return err
 	}
 
 	// Attribute-specific logic.
@@ -256,8 +1041,33 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
-		if !isValidDomain(val[1:]) {
-			return fmt.Errorf("cookie.Parse: invalid Domain value: %q", val)
+		if skip, err := opts.duplicate(seen, attrDomain); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "Domain", key)
+
+		// Some servers erroneously include a port number, or send
+		// internationalized domain names as is.
+		if opts.lenient() {
+			if host, ok := stripPort(val); ok {
+				opts.warn(errWarnPort)
+				val = host
+			}
+			if !isASCII(val) && checkUTF8(val) == nil {
+				if ascii, err := toASCII(strings.ToLower(val)); err == nil {
+					opts.warn(errWarnIDN)
+					val = ascii
+				}
+			}
+		}
+
+		if opts.RejectIPDomain && ipDomain(val) != nil {
+			return ErrIPDomain
+		}
+
+		if !isValidDomain(val) {
+			return errParseDomain
 		}
 
 		c.Domain = val
@@ -274,13 +1084,15 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
-		// TODO: This is not as efficient as it could be.
-		expires, err := time.Parse(time.RFC1123, val)
-		if err != nil {
-			expires, err = time.Parse("Mon, 02-Jan-2006 15:04:05 MST", val)
-			if err != nil {
-				return fmt.Errorf("cookie.Parse: invalid Expires value: %q", val)
-			}
+		if skip, err := opts.duplicate(seen, attrExpires); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "Expires", key)
+
+		expires, ok := parseExpires(val)
+		if !ok {
+			return errParseExpires
 		}
 
 		c.Expires = expires
@@ -298,6 +1110,16 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
+		if skip, err := opts.duplicate(seen, attrHttpOnly); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "HttpOnly", key)
+
+		if opts.Strict && val != "" {
+			return errParseAttr
+		}
+
 		c.HttpOnly = true
 		return nil
 
@@ -312,20 +1134,66 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
+		if skip, err := opts.duplicate(seen, attrMaxAge); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "Max-Age", key)
+
+		if opts.Strict && !isDigits(val) {
+			return errParseMaxAge
+		}
+
 		// TODO: This is not as efficient as it could be.
-		n, err := strconv.Atoi(val)
-		if err != nil || n < 0 {
-			return fmt.Errorf("cookie.Parse: invalid Max-Age value: %q", val)
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil && err.(*strconv.NumError).Err == strconv.ErrRange && n > 0 {
+			n = math.MaxInt64
+		} else if err != nil || n < 0 {
+			return errParseMaxAge
+		}
+
+		// Clamp excessive values.
+		limit := MaxAgeLimit
+		if limit > maxInt {
+			limit = maxInt
 		}
 
 		if n == 0 {
 			c.MaxAge = -1
+		} else if n > limit {
+			c.MaxAge = int(limit)
+			opts.warn(ErrMaxAgeOverflow)
 		} else {
-			c.MaxAge = n
+			c.MaxAge = int(n)
 		}
 		return nil
 
 	case 'p':
+		if len(key) == 11 &&
+			key[1]|0x20 == 'a' &&
+			key[2]|0x20 == 'r' &&
+			key[3]|0x20 == 't' &&
+			key[4]|0x20 == 'i' &&
+			key[5]|0x20 == 't' &&
+			key[6]|0x20 == 'i' &&
+			key[7]|0x20 == 'o' &&
+			key[8]|0x20 == 'n' &&
+			key[9]|0x20 == 'e' &&
+			key[10]|0x20 == 'd' {
+			if skip, err := opts.duplicate(seen, attrPartitioned); skip {
+				return err
+			}
+
+			opts.recordAttr(c, "Partitioned", key)
+
+			if opts.Strict && val != "" {
+				return errParseAttr
+			}
+
+			c.Partitioned = true
+			return nil
+		}
+
 		if len(key) != 4 ||
 			key[1]|0x20 != 'a' ||
 			key[2]|0x20 != 't' ||
@@ -333,10 +1201,41 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
+		if skip, err := opts.duplicate(seen, attrPath); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "Path", key)
+
+		if opts.Strict && (val == "" || val[0] != '/') {
+			return errParsePath
+		}
+
 		c.Path = val
 		return nil
 
 	case 's':
+		if len(key) == 8 &&
+			key[1]|0x20 == 'a' &&
+			key[2]|0x20 == 'm' &&
+			key[3]|0x20 == 'e' &&
+			key[4]|0x20 == 's' &&
+			key[5]|0x20 == 'i' &&
+			key[6]|0x20 == 't' &&
+			key[7]|0x20 == 'e' {
+			if skip, err := opts.duplicate(seen, attrSameSite); skip {
+				return err
+			}
+
+			opts.recordAttr(c, "SameSite", key)
+
+			c.SameSite = parseSameSite(val)
+			if opts.Strict && c.SameSite == SameSiteDefault {
+				return errParseSameSite
+			}
+			return nil
+		}
+
 		if len(key) != 6 ||
 			key[1]|0x20 != 'e' ||
 			key[2]|0x20 != 'c' ||
@@ -346,28 +1245,59 @@ func parseAttr(c *Cookie, raw string) error {
 			break
 		}
 
+		if skip, err := opts.duplicate(seen, attrSecure); skip {
+			return err
+		}
+
+		opts.recordAttr(c, "Secure", key)
+
+		if opts.Strict && val != "" {
+			return errParseAttr
+		}
+
 		c.Secure = true
 		return nil
 	}
 
+	// Hand registered attributes to their parse functions.
+	if h := lookupAttr(key); h != nil {
+		opts.recordAttr(c, h.name, h.name)
+		return h.parse(c, val)
+	}
+
 	// Store attributes we don't understand in the unparsed slice.
+	opts.recordAttr(c, "", key)
 	c.Unparsed = append(c.Unparsed, raw)
 	return nil
 }
 
-// isValidAttr returns true if the input string is a valid cookie attribute.
-func isValidAttr(s string) bool {
-	if len(s) == 0 {
+// isUnicodeDomainAttr returns true if raw is a Domain attribute whose value
+// contains non-ASCII characters, but is otherwise valid.
+func isUnicodeDomainAttr(raw string) bool {
+	eq := strings.IndexByte(raw, '=')
+	if eq < 0 || !strings.EqualFold(trim(raw[:eq]), "Domain") {
 		return false
 	}
-	for i := 0; i < len(s); i++ {
-		if chars[s[i]]&attrChar == 0 {
+
+	val := trim(raw[eq+1:])
+	if isASCII(val) {
+		return false
+	}
+
+	for i := 0; i < len(val); i++ {
+		if val[i] < 0x80 && !ValueClass.Contains(val[i]) {
 			return false
 		}
 	}
+
 	return true
 }
 
+// isValidAttr returns true if the input string is a valid cookie attribute.
+func isValidAttr(s string) bool {
+	return AttrClass.Valid(s)
+}
+
 // isValidDomain returns true if the input string is is a valid "Domain"
 // attribute value.
 func isValidDomain(s string) bool {
@@ -430,6 +1360,16 @@ func isDomainName(s string) bool {
 	return ok
 }
 
+// stripPort removes a trailing port number from a domain name. The second
+// return value is false if s had no port number.
+func stripPort(s string) (string, bool) {
+	colon := strings.LastIndexByte(s, ':')
+	if colon < 0 || strings.IndexByte(s[:colon], ':') >= 0 || !isValidPort(s[colon+1:]) {
+		return s, false
+	}
+	return s[:colon], true
+}
+
 // trim removes leading and trailing whitespace from the input string.
 func trim(s string) string {
 	l, r := 0, len(s)-1
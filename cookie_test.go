@@ -1,7 +1,12 @@
 package cookie
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -81,6 +86,42 @@ var parseTests = []struct {
 		nil,
 	},
 
+	// Empty attributes, such as those left by stray semicolons, and empty
+	// attribute values are skipped.
+	{
+		"foo=bar; Path=/; ",
+		&Cookie{
+			Name:  "foo",
+			Value: "bar",
+			Path:  "/",
+		},
+		nil,
+	},
+	{"a=b;; Path=/", &Cookie{Name: "a", Value: "b", Path: "/"}, nil},
+	{"a=b; ;Secure", &Cookie{Name: "a", Value: "b", Secure: true}, nil},
+	{"a=b; Path=", &Cookie{Name: "a", Value: "b"}, nil},
+
+	{
+		"big=1; Max-Age=99999999999999999999; Path=/",
+		&Cookie{
+			Name:   "big",
+			Value:  "1",
+			Path:   "/",
+			MaxAge: int(MaxAgeLimit),
+		},
+		nil,
+	},
+
+	// Invalid attributes fail the whole cookie.
+	{"a=b; Pa\x01th=/", nil, errParseAttr},
+	{`a=b; Path="/`, nil, errParseAttr},
+	{"a=b; =x", nil, errParseAttr},
+
+	// Domain values are validated as a whole, including their first byte.
+	{"a=b; Domain=x", &Cookie{Name: "a", Value: "b", Domain: "x"}, nil},
+	{"a=b; Domain=-example.com", nil, errParseDomain},
+	{"a=b; Domain=.", nil, errParseDomain},
+
 	// Weird ones.
 	{`x=a z`, &Cookie{Name: "x", Value: "a z"}, nil},
 	{`x=" z"`, &Cookie{Name: "x", Value: " z"}, nil},
@@ -172,3 +213,1069 @@ func TestMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestAppendMarshal(t *testing.T) {
+	for _, test := range marshalTests {
+		out, err := test.in.AppendMarshal([]byte("Set-Cookie: "), true)
+		if string(out) != "Set-Cookie: "+test.out || !reflect.DeepEqual(err, test.err) {
+			t.Errorf("(%+v).AppendMarshal(true):", test.in)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", "Set-Cookie: "+test.out, test.err)
+		}
+
+		var b bytes.Buffer
+		n, err := test.in.MarshalTo(&b, true)
+		if b.String() != test.out || n != int64(len(test.out)) || !reflect.DeepEqual(err, test.err) {
+			t.Errorf("(%+v).MarshalTo(true):", test.in)
+			t.Errorf("  got  %#q, %d, %+v", b.String(), n, err)
+			t.Errorf("  want %#q, %d, %+v", test.out, len(test.out), test.err)
+		}
+	}
+
+	// Failures leave dst as it was.
+	for _, c := range []*Cookie{{Name: "a b", Value: "c"}, {Name: "a", Value: "b", SameSite: SameSiteNone}} {
+		if out, err := c.AppendMarshal([]byte("x"), true); string(out) != "x" || err == nil {
+			t.Errorf("AppendMarshal of invalid cookie %+v returned %q, %v", c, out, err)
+		}
+	}
+}
+
+func TestAppendMarshalAllocs(t *testing.T) {
+	c := marshalTests[3].in
+	buf := make([]byte, 0, 256)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		c.AppendMarshal(buf[:0], true)
+		c.MarshalTo(io.Discard, true)
+	})
+	if allocs != 0 {
+		t.Errorf("marshaling allocated %v times per run, want 0", allocs)
+	}
+}
+
+var marshalInvalidTests = []struct {
+	in      *Cookie
+	err     error
+	escaped bool // whether MarshalEscaped succeeds
+}{
+	{nil, errors.New("cookie.Marshal: nil cookie"), false},
+	{&Cookie{Value: "b"}, errors.New(`cookie.Marshal: invalid cookie name: ""`), false},
+	{&Cookie{Name: "a"}, errors.New(`cookie.Marshal: invalid cookie value: ""`), false},
+	{&Cookie{Name: "a", Value: `"`}, errors.New(`cookie.Marshal: invalid cookie value: "\""`), true},
+	{&Cookie{Name: "a b", Value: "c"}, errors.New(`cookie.Marshal: invalid cookie name: "a b"`), false},
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	for _, test := range marshalInvalidTests {
+		for _, attrs := range []bool{false, true} {
+			out, err := test.in.Marshal(attrs)
+			if out != "" || !reflect.DeepEqual(err, test.err) {
+				t.Errorf("(%+v).Marshal(%v):", test.in, attrs)
+				t.Errorf("  got  %#q, %+v", out, err)
+				t.Errorf("  want %#q, %+v", "", test.err)
+			}
+
+			if _, err := test.in.MarshalEscaped(attrs); (err == nil) != test.escaped {
+				t.Errorf("(%+v).MarshalEscaped(%v) returned error %v", test.in, attrs, err)
+			}
+		}
+	}
+}
+
+var escapeTests = []struct {
+	in  string
+	out string
+}{
+	{"plain", "x=plain"},
+	{"a b,c", "x=a%20b%2Cc"},
+	{`"100%";\`, "x=%22100%25%22%3B%5C"},
+	{"smörgåsbord", "x=sm%C3%B6rg%C3%A5sbord"},
+}
+
+func TestEscape(t *testing.T) {
+	for _, test := range escapeTests {
+		out, err := (&Cookie{Name: "x", Value: test.in}).MarshalEscaped(false)
+		if out != test.out || err != nil {
+			t.Errorf("MarshalEscaped(%q):", test.in)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+			continue
+		}
+
+		c, err := ParseEscaped(out)
+		if err != nil || c.Value != test.in {
+			t.Errorf("ParseEscaped(%#q):", out)
+			t.Errorf("  got  %+v, %+v", c, err)
+			t.Errorf("  want value %q", test.in)
+		}
+	}
+
+	for _, in := range []string{"x=%", "x=a%2", "x=%zz"} {
+		if _, err := ParseEscaped(in); err == nil {
+			t.Errorf("ParseEscaped(%#q) succeeded", in)
+		}
+	}
+}
+
+var sameSiteTests = []struct {
+	in   string
+	s    SameSite
+	out  string
+	fail bool // whether Marshal fails
+}{
+	{"a=b", SameSiteDefault, "a=b", false},
+	{"a=b; SameSite=Lax", SameSiteLax, "a=b; SameSite=Lax", false},
+	{"a=b; samesite=STRICT", SameSiteStrict, "a=b; SameSite=Strict", false},
+	{"a=b; SameSite=None; Secure", SameSiteNone, "a=b; Secure; SameSite=None", false},
+	{"a=b; SameSite=None", SameSiteNone, "", true},
+	{"a=b; SameSite=bogus", SameSiteDefault, "a=b", false},
+	{"a=b; SameSite", SameSiteDefault, "a=b", false},
+}
+
+func TestSameSiteAttr(t *testing.T) {
+	for _, test := range sameSiteTests {
+		c, err := Parse(test.in)
+		if err != nil || c.SameSite != test.s || len(c.Unparsed) != 0 {
+			t.Errorf("Parse(%#q):", test.in)
+			t.Errorf("  got  %+v, %+v", c, err)
+			t.Errorf("  want SameSite %v", test.s)
+			continue
+		}
+
+		out, err := c.Marshal(true)
+		if out != test.out || (err != nil) != test.fail {
+			t.Errorf("(%+v).Marshal(true):", c)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, error %v", test.out, test.fail)
+		}
+	}
+}
+
+func TestSameSiteNoneInsecure(t *testing.T) {
+	c := &Cookie{Name: "a", Value: "b", SameSite: SameSiteNone}
+
+	if _, err := c.Marshal(true); err != ErrSameSiteNoneInsecure {
+		t.Errorf("Marshal returned %v, want ErrSameSiteNoneInsecure", err)
+	}
+
+	out, err := c.MarshalWithOptions(true, &MarshalOptions{SecureSameSiteNone: true})
+	if want := "a=b; Secure; SameSite=None"; out != want || err != nil {
+		t.Errorf("MarshalWithOptions returned %#q, %v, want %#q", out, err, want)
+	}
+	if c.Secure {
+		t.Errorf("MarshalWithOptions modified the cookie")
+	}
+}
+
+func TestPartitionedAttr(t *testing.T) {
+	tests := []struct {
+		in   string
+		p    bool
+		out  string
+		fail bool
+	}{
+		{"a=b; Secure; Partitioned", true, "a=b; Secure; Partitioned", false},
+		{"a=b; partitioned; SECURE", true, "a=b; Secure; Partitioned", false},
+		{"a=b; Partitioned", true, "", true},
+		{"a=b; Partition", false, "a=b; Partition", false},
+	}
+
+	for _, test := range tests {
+		c, err := Parse(test.in)
+		if err != nil || c.Partitioned != test.p {
+			t.Errorf("Parse(%#q) = %+v, %v, want Partitioned %v", test.in, c, err, test.p)
+			continue
+		}
+
+		out, err := c.Marshal(true)
+		if out != test.out || (err != nil) != test.fail {
+			t.Errorf("(%+v).Marshal(true):", c)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, error %v", test.out, test.fail)
+		}
+	}
+}
+
+func TestRejectIPDomain(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{"a=b; Domain=example.com", nil},
+		{"a=b; Domain=127.0.0.1", ErrIPDomain},
+		{"a=b; Domain=.127.0.0.1", ErrIPDomain},
+		{"a=b; Domain=::1", ErrIPDomain},
+		{"a=b; Domain=[2001:db8::1]", ErrIPDomain},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseWithOptions(test.in, &ParseOptions{RejectIPDomain: true}); err != test.err {
+			t.Errorf("ParseWithOptions(%#q, reject IP domain) returned error %v, want %v", test.in, err, test.err)
+		}
+	}
+
+	if c, err := Parse("a=b; Domain=127.0.0.1"); err != nil || c.Domain != "127.0.0.1" {
+		t.Errorf("Parse of IP domain returned %+v, %v", c, err)
+	}
+}
+
+var backslashTests = []struct {
+	in    string
+	value string
+	out   string
+}{
+	{`x=plain`, "plain", `x=plain`},
+	{`x="plain"`, "plain", `x=plain`},
+	{`x="say \"hi\""`, `say "hi"`, `x="say \"hi\""`},
+	{`x="a\\b"`, `a\b`, `x="a\\b"`},
+}
+
+func TestBackslashEscapes(t *testing.T) {
+	for _, test := range backslashTests {
+		c, err := ParseWithOptions(test.in, &ParseOptions{BackslashEscapes: true})
+		if err != nil || c.Value != test.value {
+			t.Errorf("ParseWithOptions(%#q, backslash escapes):", test.in)
+			t.Errorf("  got  %+v, %+v", c, err)
+			t.Errorf("  want value %#q", test.value)
+			continue
+		}
+
+		out, err := c.MarshalWithOptions(false, &MarshalOptions{BackslashEscapes: true})
+		if out != test.out || err != nil {
+			t.Errorf("MarshalWithOptions(%#q, backslash escapes):", test.value)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+
+	for _, in := range []string{`x="a\b"`, `x="a\"`, `x="a"b"`, `x=a\"b`} {
+		if _, err := ParseWithOptions(in, &ParseOptions{BackslashEscapes: true}); err == nil {
+			t.Errorf("ParseWithOptions(%#q, backslash escapes) succeeded", in)
+		}
+	}
+
+	// Without the option, such values are still rejected.
+	if _, err := (&Cookie{Name: "x", Value: `a"b`}).Marshal(false); err == nil {
+		t.Errorf("Marshal succeeded for value containing a double quote")
+	}
+}
+
+func TestDecodePercent(t *testing.T) {
+	tests := []struct {
+		in    string
+		value string
+		out   string
+	}{
+		{"x=abc", "abc", "x=abc"},
+		{"x=a%20b%2C%20c", "a b, c", "x=a%20b%2C%20c"},
+		{"x=%7B%22id%22%3A1%7D", `{"id":1}`, "x={%22id%22:1}"},
+		{"x=caf%C3%A9", "café", "x=caf%C3%A9"},
+		{"x=100%25", "100%", "x=100%25"},
+		{"x=a+b", "a+b", "x=a+b"},
+		{`x="a%3Bb"`, "a;b", "x=a%3Bb"},
+	}
+
+	for _, test := range tests {
+		c, err := ParseWithOptions(test.in, &ParseOptions{DecodePercent: true})
+		if err != nil || c.Value != test.value {
+			t.Errorf("ParseWithOptions(%#q, decode percent):", test.in)
+			t.Errorf("  got  %+v, %+v", c, err)
+			t.Errorf("  want value %#q", test.value)
+			continue
+		}
+
+		out, err := c.MarshalWithOptions(false, &MarshalOptions{EncodePercent: true})
+		if out != test.out || err != nil {
+			t.Errorf("MarshalWithOptions(%#q, encode percent):", test.value)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+
+	for _, in := range []string{"x=%", "x=%4", "x=%zz", "x=%FF%FE"} {
+		if _, err := ParseWithOptions(in, &ParseOptions{DecodePercent: true}); err == nil {
+			t.Errorf("ParseWithOptions(%#q, decode percent) succeeded", in)
+		}
+	}
+
+	// Without the option, values are left alone.
+	if c, _ := Parse("x=a%20b"); c.Value != "a%20b" {
+		t.Errorf("Parse decoded %q without DecodePercent", c.Value)
+	}
+}
+
+var lenientTests = []struct {
+	in   string
+	out  *Cookie
+	err  error
+	warn bool
+}{
+	{
+		"a=b; Domain=example.com:8080",
+		&Cookie{Name: "a", Value: "b", Domain: "example.com"},
+		nil,
+		true,
+	},
+	{
+		"a=b; Domain=.example.com",
+		&Cookie{Name: "a", Value: "b", Domain: ".example.com"},
+		nil,
+		false,
+	},
+	{
+		"a=b; Domain=example.com:http",
+		nil,
+		errParseDomain,
+		false,
+	},
+	{
+		"a=b; Path = /; Max-Age  =60; Secure ",
+		&Cookie{Name: "a", Value: "b", Path: "/", MaxAge: 60, Secure: true},
+		nil,
+		true,
+	},
+	{
+		"a=b; Domain=.Bücher.example",
+		&Cookie{Name: "a", Value: "b", Domain: ".xn--bcher-kva.example"},
+		nil,
+		true,
+	},
+	{
+		`a=b; Domain= "example.com"`,
+		&Cookie{Name: "a", Value: "b", Domain: "example.com"},
+		nil,
+		true,
+	},
+}
+
+func TestParseLenient(t *testing.T) {
+	for _, test := range lenientTests {
+		var warned bool
+
+		out, err := ParseWithOptions(test.in, &ParseOptions{
+			Lenient: true,
+			Warn:    func(error) { warned = true },
+		})
+
+		if !reflect.DeepEqual(out, test.out) || !reflect.DeepEqual(err, test.err) || warned != test.warn {
+			t.Errorf("ParseWithOptions(%#q, lenient):", test.in)
+			t.Errorf("  got  %+v, %+v, warned=%v", out, err, warned)
+			t.Errorf("  want %+v, %+v, warned=%v", test.out, test.err, test.warn)
+		}
+	}
+
+	// Strict mode shouldn't accept internationalized domains.
+	if _, err := Parse("a=b; Domain=bücher.example"); err == nil {
+		t.Errorf("Parse accepted non-ASCII Domain value")
+	}
+}
+
+func TestParseMaxAgeOverflow(t *testing.T) {
+	in := "a=b; Max-Age=99999999999999999999"
+
+	for _, opts := range []*ParseOptions{{}, {Lenient: true}, {Strict: true}} {
+		var warnings []error
+		opts.Warn = func(err error) { warnings = append(warnings, err) }
+
+		out, err := ParseWithOptions(in, opts)
+		if out == nil || out.MaxAge != int(MaxAgeLimit) || err != nil || len(warnings) != 1 || warnings[0] != ErrMaxAgeOverflow {
+			t.Errorf("ParseWithOptions(%#q, %+v) = %+v, %v, warnings %v", in, opts, out, err, warnings)
+		}
+	}
+}
+
+var strictTests = []struct {
+	in  string
+	out *Cookie
+	err error
+}{
+	{
+		`a="b"; Path=/x; Max-Age=0; Secure; HttpOnly; SameSite=lax; Foo=bar`,
+		&Cookie{Name: "a", Value: "b", Path: "/x", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: SameSiteLax, Unparsed: []string{"Foo=bar"}},
+		nil,
+	},
+	{"a=b c", nil, errors.New("cookie.Parse: invalid cookie value")},
+	{`a=" b"`, nil, errors.New("cookie.Parse: invalid cookie value")},
+	{"a=b,c", nil, errors.New("cookie.Parse: invalid cookie value")},
+	{"a=b; Path = /", nil, errParseAttr},
+	{"a=b; Max-Age=+60", nil, errParseMaxAge},
+	{"a=b; Path=x", nil, errParsePath},
+	{"a=b; SameSite=Sometimes", nil, errParseSameSite},
+	{"a=b; Secure=yes", nil, errParseAttr},
+	{"a=b; Domain=example.com:8080", nil, errParseDomain},
+}
+
+func TestParseStrict(t *testing.T) {
+	for _, test := range strictTests {
+		out, err := ParseStrict(test.in)
+		if !reflect.DeepEqual(out, test.out) || !reflect.DeepEqual(err, test.err) {
+			t.Errorf("ParseStrict(%#q):", test.in)
+			t.Errorf("  got  %+v, %+v", out, err)
+			t.Errorf("  want %+v, %+v", test.out, test.err)
+		}
+	}
+
+	// Strict takes precedence over Lenient.
+	if _, err := ParseWithOptions("a=b; Domain=example.com:8080", &ParseOptions{Strict: true, Lenient: true}); err == nil {
+		t.Errorf("lenient workaround applied in strict mode")
+	}
+}
+
+var parseOptionsTests = []struct {
+	in   string
+	opts *ParseOptions
+	out  *Cookie
+	err  error
+}{
+	{"a=b; Path=/x; Path=/y", nil, &Cookie{Name: "a", Value: "b", Path: "/y"}, nil},
+	{"a=b; Path=/x; path=/y", &ParseOptions{Duplicates: DuplicateFirst}, &Cookie{Name: "a", Value: "b", Path: "/x"}, nil},
+	{"a=b; Max-Age=60; Max-Age=abc", &ParseOptions{Duplicates: DuplicateFirst}, &Cookie{Name: "a", Value: "b", MaxAge: 60}, nil},
+	{"a=b; path=/x; PATH=/y", &ParseOptions{Duplicates: DuplicateFirst, PreserveCase: true, PreserveOrder: true}, &Cookie{Name: "a", Value: "b", Path: "/x", AttrCase: map[string]string{"Path": "path"}, AttrOrder: []string{"Path"}}, nil},
+	{"a=b; Secure; Secure", &ParseOptions{Duplicates: DuplicateReject}, nil, ErrDuplicateAttr},
+	{"a=b; Foo=1; Foo=2", &ParseOptions{Duplicates: DuplicateReject}, &Cookie{Name: "a", Value: "b", Unparsed: []string{"Foo=1", "Foo=2"}}, nil},
+	{"a=é", nil, nil, errors.New("cookie.Parse: invalid cookie value")},
+	{"a=café", &ParseOptions{UTF8: true}, &Cookie{Name: "a", Value: "café"}, nil},
+	{`a="naïve"`, &ParseOptions{UTF8: true}, &Cookie{Name: "a", Value: "naïve"}, nil},
+	{"a=caf\xe9", &ParseOptions{UTF8: true}, nil, errors.New("cookie.Parse: invalid cookie value")},
+	{"a=\"é", &ParseOptions{UTF8: true}, nil, errors.New("cookie.Parse: invalid cookie value")},
+	{"a=café", &ParseOptions{UTF8: true, Strict: true}, nil, errors.New("cookie.Parse: invalid cookie value")},
+}
+
+func TestParseOptions(t *testing.T) {
+	for _, test := range parseOptionsTests {
+		out, err := ParseWithOptions(test.in, test.opts)
+		if !reflect.DeepEqual(out, test.out) || !reflect.DeepEqual(err, test.err) {
+			t.Errorf("ParseWithOptions(%#q, %+v):", test.in, test.opts)
+			t.Errorf("  got  %+v, %+v", out, err)
+			t.Errorf("  want %+v, %+v", test.out, test.err)
+		}
+	}
+}
+
+var bothExpiriesTests = []struct {
+	in  *Cookie
+	out string
+}{
+	{
+		&Cookie{Name: "a", Value: "b", MaxAge: 3600},
+		"a=b; Expires=Thu, 01 Jan 2015 01:00:00 UTC; Max-Age=3600",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", MaxAge: -1},
+		"a=b; Expires=Thu, 01 Jan 1970 00:00:01 UTC; Max-Age=0",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", Expires: time.Date(2015, 1, 1, 0, 0, 30, 500, time.UTC)},
+		"a=b; Expires=Thu, 01 Jan 2015 00:00:30 UTC; Max-Age=31",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", Expires: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"a=b; Expires=Wed, 01 Jan 2014 00:00:00 UTC; Max-Age=0",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", Expires: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), MaxAge: 60},
+		"a=b; Expires=Fri, 01 Jan 2016 00:00:00 UTC; Max-Age=60",
+	},
+	{
+		&Cookie{Name: "a", Value: "b"},
+		"a=b",
+	},
+}
+
+func TestMarshalBothExpiries(t *testing.T) {
+	opts := &MarshalOptions{
+		EmitBothExpiries: true,
+		Now:              time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, test := range bothExpiriesTests {
+		out, err := test.in.MarshalWithOptions(true, opts)
+		if out != test.out || err != nil {
+			t.Errorf("(%+v).MarshalWithOptions(true, %+v):", test.in, opts)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+}
+
+func TestScanner(t *testing.T) {
+	s := NewScanner(` a=1; b="2";; c=3 `)
+
+	var got []*Cookie
+	for s.Next() {
+		got = append(got, s.Cookie())
+	}
+
+	want := []*Cookie{
+		{Name: "a", Value: "1"},
+		{Name: "b", Value: "2"},
+		{Name: "c", Value: "3"},
+	}
+
+	if !reflect.DeepEqual(got, want) || s.Err() != nil {
+		t.Errorf("Scanner:")
+		t.Errorf("  got  %+v, %+v", got, s.Err())
+		t.Errorf("  want %+v, %+v", want, nil)
+	}
+
+	// Invalid pairs are skipped, like ParseRequestHeader does.
+	s = NewScanner("a=1; b; c d=2; c=3; e=")
+	got = nil
+	for s.Next() {
+		got = append(got, s.Cookie())
+	}
+	if want := []*Cookie{{Name: "a", Value: "1"}, {Name: "c", Value: "3"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Scanner skipping invalid pairs returned %+v, want %+v", got, want)
+	}
+}
+
+var lintTests = []struct {
+	in  *Cookie
+	bad bool
+}{
+	{&Cookie{Name: "a", Value: "${session}"}, true},
+	{&Cookie{Name: "a", Value: "x{{.Token}}y"}, true},
+	{&Cookie{Name: "a", Value: "id-%s"}, true},
+	{&Cookie{Name: "a", Value: "%d"}, true},
+	{&Cookie{Name: "a", Value: "b", Domain: "${host}"}, true},
+	{&Cookie{Name: "a", Value: "b", Path: "/%v/"}, true},
+	{&Cookie{Name: "a", Value: "%d9%2C"}, false},
+	{&Cookie{Name: "a", Value: "{not}a$template}"}, false},
+	{&Cookie{Name: "a", Value: "100%"}, false},
+}
+
+func TestMarshalLint(t *testing.T) {
+	opts := &MarshalOptions{Lint: true}
+
+	for _, test := range lintTests {
+		_, err := test.in.MarshalWithOptions(true, opts)
+		if (err != nil) != test.bad {
+			t.Errorf("(%+v).MarshalWithOptions(true, lint) returned error %v", test.in, err)
+		}
+	}
+}
+
+func TestPreserveCase(t *testing.T) {
+	in := "a=b; domain=example.com; PATH=/; max-age=60; secure; HttpOnly"
+
+	c, err := ParseWithOptions(in, &ParseOptions{PreserveCase: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions(%#q): %v", in, err)
+	}
+
+	want := map[string]string{
+		"Domain":  "domain",
+		"Path":    "PATH",
+		"Max-Age": "max-age",
+		"Secure":  "secure",
+	}
+	if !reflect.DeepEqual(c.AttrCase, want) {
+		t.Errorf("AttrCase:")
+		t.Errorf("  got  %v", c.AttrCase)
+		t.Errorf("  want %v", want)
+	}
+
+	out, err := c.MarshalWithOptions(true, &MarshalOptions{PreserveCase: true})
+	if want := "a=b; domain=example.com; PATH=/; max-age=60; HttpOnly; secure"; out != want || err != nil {
+		t.Errorf("MarshalWithOptions(true, preserve case):")
+		t.Errorf("  got  %#q, %+v", out, err)
+		t.Errorf("  want %#q, %+v", want, nil)
+	}
+}
+
+func TestAttributeCase(t *testing.T) {
+	in := "a=b; domain=example.com; PATH=/; max-age=60; secure; HttpOnly"
+
+	c, err := ParseWithOptions(in, &ParseOptions{PreserveCase: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions(%#q): %v", in, err)
+	}
+
+	tests := []struct {
+		ac  AttributeCase
+		out string
+	}{
+		{CaseCanonical, "a=b; Domain=example.com; Path=/; Max-Age=60; HttpOnly; Secure"},
+		{CaseLower, "a=b; domain=example.com; path=/; max-age=60; httponly; secure"},
+		{CasePreserveParsed, "a=b; domain=example.com; PATH=/; max-age=60; HttpOnly; secure"},
+	}
+
+	for _, test := range tests {
+		out, err := c.MarshalWithOptions(true, &MarshalOptions{Case: test.ac})
+		if out != test.out || err != nil {
+			t.Errorf("MarshalWithOptions(true, case %d):", test.ac)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+}
+
+var budgetTests = []struct {
+	in   string
+	opts ParseOptions
+	err  error
+}{
+	{"a=b; Path=/; Secure", ParseOptions{MaxBytes: 19, MaxAttrs: 2}, nil},
+	{"a=b; Path=/; Secure", ParseOptions{MaxBytes: 18}, ErrTruncated},
+	{"a=b; Path=/; Secure", ParseOptions{MaxAttrs: 1}, ErrTruncated},
+	{"a=b;;;;", ParseOptions{MaxAttrs: 3}, ErrTruncated},
+	{"a=bcdefghijklmnop", ParseOptions{MaxBytes: 4}, ErrTruncated},
+}
+
+func TestParseBudget(t *testing.T) {
+	for _, test := range budgetTests {
+		opts := test.opts
+		if _, err := ParseWithOptions(test.in, &opts); err != test.err {
+			t.Errorf("ParseWithOptions(%#q, %+v) returned error %v, want %v", test.in, test.opts, err, test.err)
+		}
+	}
+}
+
+func TestPreserveOrder(t *testing.T) {
+	in := "a=b; secure; Foo=bar; max-age=60; HttpOnly; Path=/; Baz"
+
+	c, err := ParseWithOptions(in, &ParseOptions{PreserveOrder: true, PreserveCase: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions(%#q): %v", in, err)
+	}
+
+	want := []string{"Secure", "", "Max-Age", "HttpOnly", "Path", ""}
+	if !reflect.DeepEqual(c.AttrOrder, want) {
+		t.Errorf("AttrOrder:")
+		t.Errorf("  got  %q", c.AttrOrder)
+		t.Errorf("  want %q", want)
+	}
+
+	out, err := c.MarshalWithOptions(true, &MarshalOptions{PreserveOrder: true, PreserveCase: true})
+	if out != in || err != nil {
+		t.Errorf("MarshalWithOptions(true, preserve order):")
+		t.Errorf("  got  %#q, %+v", out, err)
+		t.Errorf("  want %#q, %+v", in, nil)
+	}
+
+	// Attributes added after parsing follow those with a recorded position.
+	c.Domain = "example.com"
+	out, err = c.MarshalWithOptions(true, &MarshalOptions{PreserveOrder: true})
+	if want := "a=b; Secure; Foo=bar; Max-Age=60; HttpOnly; Path=/; Baz; Domain=example.com"; out != want || err != nil {
+		t.Errorf("MarshalWithOptions(true, preserve order):")
+		t.Errorf("  got  %#q, %+v", out, err)
+		t.Errorf("  want %#q, %+v", want, nil)
+	}
+}
+
+func TestExpiresLocation(t *testing.T) {
+	defer func(loc *time.Location) { ExpiresLocation = loc }(ExpiresLocation)
+	ExpiresLocation = time.FixedZone("XST", -8*3600)
+
+	tests := []struct {
+		in  string
+		out time.Time
+	}{
+		{"a=b; Expires=Wed, 23 Nov 2011 01:05:03 GMT", time.Date(2011, 11, 23, 1, 5, 3, 0, time.UTC)},
+		{"a=b; Expires=Wed, 23 Nov 2011 01:05:03 XST", time.Date(2011, 11, 23, 9, 5, 3, 0, time.UTC)},
+		{"a=b; Expires=Wed, 23 Nov 2011 01:05:03 QQQ", time.Date(2011, 11, 23, 9, 5, 3, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		c, err := Parse(test.in)
+		if err != nil || !c.Expires.Equal(test.out) {
+			t.Errorf("Parse(%#q):", test.in)
+			t.Errorf("  got  %+v, %+v", c, err)
+			t.Errorf("  want Expires %s", test.out)
+		}
+	}
+}
+
+var cookieDateTests = []struct {
+	in  string
+	out time.Time
+	ok  bool
+}{
+	{"Thu, 01 Jan 1970 00:00:00 GMT", time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"Thu, 01-Jan-70 00:00:01 GMT", time.Date(1970, 1, 1, 0, 0, 1, 0, time.UTC), true},
+	{"Wed, 23-Nov-11 1:5:3", time.Date(2011, 11, 23, 1, 5, 3, 0, time.UTC), true},
+	{"23 November 2011 01:05:03", time.Date(2011, 11, 23, 1, 5, 3, 0, time.UTC), true},
+	{"Nov 23 01:05:03 2011", time.Date(2011, 11, 23, 1, 5, 3, 0, time.UTC), true},
+	{"2011/nov/23 01:05:03", time.Date(2011, 11, 23, 1, 5, 3, 0, time.UTC), true},
+	{"Sunday, 06-Nov-94 08:49:37 GMT", time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC), true},
+	{"Thu, 01 Jan 1970 00:00:00", time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"1 Jan 69 00:00:00", time.Date(2069, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	{"30 Feb 2015 00:00:00", time.Time{}, false},
+	{"01 Jan 2015", time.Time{}, false},
+	{"01 Jan 2015 24:00:00", time.Time{}, false},
+	{"01 Foo 2015 00:00:00", time.Time{}, false},
+	{"01 Jan 1600 00:00:00", time.Time{}, false},
+	{"001 Jan 2015 00:00:00", time.Time{}, false},
+}
+
+func TestCookieDate(t *testing.T) {
+	for _, test := range cookieDateTests {
+		out, ok := parseExpires(test.in)
+		if ok != test.ok || !out.Equal(test.out) {
+			t.Errorf("parseExpires(%q) = %v, %v, want %v, %v", test.in, out, ok, test.out, test.ok)
+		}
+	}
+}
+
+var oversizeTests = []struct {
+	action OversizeAction
+	out    string
+	err    error
+}{
+	{OversizeReject, "", ErrOversize},
+	{OversizeTruncate, "a=0123; Path=/", nil},
+	{OversizeAllow, "a=0123456789; Path=/", nil},
+}
+
+func TestMarshalOversize(t *testing.T) {
+	c := &Cookie{Name: "a", Value: "0123456789", Path: "/"}
+
+	for _, test := range oversizeTests {
+		var size int
+		out, err := c.MarshalWithOptions(true, &MarshalOptions{
+			MaxSize: 14,
+			OnOversize: func(c *Cookie, n int) OversizeAction {
+				size = n
+				return test.action
+			},
+		})
+
+		if out != test.out || err != test.err || size != 20 {
+			t.Errorf("MarshalWithOptions(oversize action %d):", test.action)
+			t.Errorf("  got  %#q, %+v, size %d", out, err, size)
+			t.Errorf("  want %#q, %+v, size %d", test.out, test.err, 20)
+		}
+	}
+
+	// Cookies within the limit are left alone, and those exceeding it are
+	// rejected by default.
+	if out, err := c.MarshalWithOptions(true, &MarshalOptions{MaxSize: 20}); out != "a=0123456789; Path=/" || err != nil {
+		t.Errorf("MarshalWithOptions(MaxSize=20) returned %#q, %+v", out, err)
+	}
+	if _, err := c.MarshalWithOptions(true, &MarshalOptions{MaxSize: 19}); err != ErrOversize {
+		t.Errorf("MarshalWithOptions(MaxSize=19) returned %+v", err)
+	}
+
+	// Truncation can't remove more than the value.
+	truncate := func(*Cookie, int) OversizeAction { return OversizeTruncate }
+	if _, err := c.MarshalWithOptions(true, &MarshalOptions{MaxSize: 9, OnOversize: truncate}); err != ErrOversize {
+		t.Errorf("MarshalWithOptions(MaxSize=9, truncate) returned %+v", err)
+	}
+
+	// Chunking needs more than one header.
+	chunk := func(*Cookie, int) OversizeAction { return OversizeChunk }
+	if _, err := c.MarshalWithOptions(true, &MarshalOptions{MaxSize: 14, OnOversize: chunk}); err != ErrOversize {
+		t.Errorf("MarshalWithOptions(chunk) returned %+v", err)
+	}
+}
+
+var chunkTests = []struct {
+	max int
+	out []string
+	err error
+}{
+	{0, []string{"a=0123456789; Path=/"}, nil},
+	{20, []string{"a=0123456789; Path=/"}, nil},
+	{19, []string{"a~0=2~01234; Path=/", "a~1=56789; Path=/"}, nil},
+	{16, []string{"a~0=5~01; Path=/", "a~1=23; Path=/", "a~2=45; Path=/", "a~3=67; Path=/", "a~4=89; Path=/"}, nil},
+	{14, nil, ErrOversize},
+}
+
+func TestMarshalChunks(t *testing.T) {
+	c := &Cookie{Name: "a", Value: "0123456789", Path: "/"}
+	chunk := func(*Cookie, int) OversizeAction { return OversizeChunk }
+
+	for _, test := range chunkTests {
+		out, err := c.MarshalChunks(true, &MarshalOptions{MaxSize: test.max, OnOversize: chunk})
+		if !reflect.DeepEqual(out, test.out) || err != test.err {
+			t.Errorf("MarshalChunks(MaxSize=%d):", test.max)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// The chunks reassemble into the original value.
+		var cookies []*Cookie
+		for _, s := range out {
+			parsed, err := Parse(s)
+			if err != nil {
+				t.Fatalf("Parse(%#q): %v", s, err)
+			}
+			if len(s) > test.max && test.max > 0 {
+				t.Errorf("chunk %#q exceeds %d bytes", s, test.max)
+			}
+			cookies = append(cookies, parsed)
+		}
+		if value, ok := JoinChunks(cookies, "a"); value != c.Value || !ok {
+			t.Errorf("JoinChunks(MaxSize=%d) = %q, %v", test.max, value, ok)
+		}
+	}
+
+	// Other actions are honored as by MarshalWithOptions.
+	truncate := func(*Cookie, int) OversizeAction { return OversizeTruncate }
+	if out, err := c.MarshalChunks(true, &MarshalOptions{MaxSize: 14, OnOversize: truncate}); len(out) != 1 || out[0] != "a=0123; Path=/" || err != nil {
+		t.Errorf("MarshalChunks(truncate) returned %#q, %+v", out, err)
+	}
+}
+
+var joinChunksTests = []struct {
+	in    []*Cookie
+	value string
+	ok    bool
+}{
+	{[]*Cookie{{Name: "a", Value: "1"}, {Name: "a~0", Value: "1~x"}}, "1", true},
+	{[]*Cookie{{Name: "a~1", Value: "cd"}, {Name: "a~0", Value: "2~ab"}}, "abcd", true},
+	{[]*Cookie{{Name: "a~0", Value: "2~ab"}, {Name: "a~1", Value: "cd"}, {Name: "a~2", Value: "stale"}}, "abcd", true},
+	{[]*Cookie{{Name: "a~0", Value: "3~ab"}, {Name: "a~1", Value: "cd"}}, "", false},
+	{[]*Cookie{{Name: "a~0", Value: "ab"}}, "", false},
+	{[]*Cookie{{Name: "a~0", Value: "0~"}}, "", false},
+	{[]*Cookie{{Name: "b", Value: "1"}}, "", false},
+}
+
+func TestJoinChunks(t *testing.T) {
+	for _, test := range joinChunksTests {
+		if value, ok := JoinChunks(test.in, "a"); value != test.value || ok != test.ok {
+			t.Errorf("JoinChunks(%+v) = %q, %v, want %q, %v", test.in, value, ok, test.value, test.ok)
+		}
+	}
+}
+
+func TestParseCache(t *testing.T) {
+	SetParseCache(2)
+	defer SetParseCache(0)
+
+	in := "a=b; Path=/; foo"
+
+	first, err := Parse(in)
+	if err != nil {
+		t.Fatalf("Parse(%#q): %v", in, err)
+	}
+
+	// Modifying a returned cookie mustn't affect the cache.
+	first.Unparsed[0] = "bar"
+	first.Value = "x"
+
+	second, err := Parse(in)
+	want := &Cookie{Name: "a", Value: "b", Path: "/", Unparsed: []string{"foo"}}
+	if !reflect.DeepEqual(second, want) || err != nil {
+		t.Errorf("cached Parse(%#q):", in)
+		t.Errorf("  got  %+v, %+v", second, err)
+		t.Errorf("  want %+v, %+v", want, nil)
+	}
+
+	// Errors aren't cached, and the cache stays within bounds.
+	for _, s := range []string{"x", "c=d", "e=f", "g=h"} {
+		Parse(s)
+	}
+	if n := len(parseCache.cookies); n > 2 {
+		t.Errorf("cache holds %d cookies, want at most 2", n)
+	}
+}
+
+func BenchmarkParseCached(b *testing.B) {
+	SetParseCache(16)
+	defer SetParseCache(0)
+
+	in := "consent=yes; Domain=.example.com; Path=/; Expires=Wed, 23 Nov 2011 01:05:03 GMT; Secure; SameSite=Lax"
+	for i := 0; i < b.N; i++ {
+		Parse(in)
+	}
+}
+
+// longHeaders are synthetic "Set-Cookie" headers of about 64KB, made up of
+// many attributes or a single long value.
+var longHeaders = []struct {
+	name     string
+	in       string
+	unparsed int // number of unparsed attributes
+	value    int // length of the value
+	secure   bool
+}{
+	{"ManyAttrs", "a=b" + strings.Repeat("; Path=/some/path; x-vendor=1234567890", 1700), 1700, 1, false},
+	{"LongValue", "a=" + strings.Repeat("0123456789abcdef", 4096) + "; Path=/; Secure", 0, 65536, true},
+}
+
+func TestParseLong(t *testing.T) {
+	for _, test := range longHeaders {
+		c, err := Parse(test.in)
+		if err != nil {
+			t.Errorf("%s: Parse returned %v", test.name, err)
+			continue
+		}
+		if len(c.Unparsed) != test.unparsed || len(c.Value) != test.value || c.Secure != test.secure {
+			t.Errorf("%s: got %d unparsed attributes, a value of length %d and Secure=%v, want %d, %d and %v",
+				test.name, len(c.Unparsed), len(c.Value), c.Secure, test.unparsed, test.value, test.secure)
+		}
+	}
+}
+
+func BenchmarkParseLong(b *testing.B) {
+	for _, test := range longHeaders {
+		b.Run(test.name, func(b *testing.B) {
+			b.SetBytes(int64(len(test.in)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseWithOptions(test.in, nil)
+			}
+		})
+	}
+}
+
+// commonHeaders are typical "Set-Cookie" headers, without and with common
+// attributes.
+var commonHeaders = []string{
+	"session=abc123",
+	"session=abc123; Path=/; Domain=example.com; Max-Age=3600; Secure; HttpOnly; SameSite=Lax",
+}
+
+func TestParseInto(t *testing.T) {
+	var c Cookie
+	for _, in := range commonHeaders {
+		want, _ := Parse(in)
+		if err := ParseInto(&c, in, nil); err != nil || !reflect.DeepEqual(&c, want) {
+			t.Errorf("ParseInto(%#q) = %+v, %v, want %+v", in, c, err, want)
+		}
+
+		allocs := testing.AllocsPerRun(100, func() { ParseInto(&c, in, nil) })
+		if allocs != 0 {
+			t.Errorf("ParseInto(%#q) allocated %v times per run, want 0", in, allocs)
+		}
+	}
+
+	// Unparsed attributes reuse the slice's capacity.
+	ParseInto(&c, "a=b; Foo=1; Bar=2", nil)
+	if allocs := testing.AllocsPerRun(100, func() { ParseInto(&c, "a=b; Foo=1", nil) }); allocs != 0 {
+		t.Errorf("ParseInto with unparsed attributes allocated %v times per run, want 0", allocs)
+	}
+
+	// Parse allocates only the cookie it returns.
+	for _, in := range commonHeaders {
+		if allocs := testing.AllocsPerRun(100, func() { ParseWithOptions(in, nil) }); allocs != 1 {
+			t.Errorf("ParseWithOptions(%#q) allocated %v times per run, want 1", in, allocs)
+		}
+	}
+
+	// ParseBytes additionally allocates a buffer for the cookie's strings,
+	// which don't refer to the input.
+	b := []byte("a=b; Secure; Foo=bar")
+	p, err := ParseBytes(b)
+	for i := range b {
+		b[i] = 'x'
+	}
+	if err != nil || p.Name != "a" || p.Value != "b" || !p.Secure || len(p.Unparsed) != 1 || p.Unparsed[0] != "Foo=bar" {
+		t.Errorf("ParseBytes returned %+v, %v", p, err)
+	}
+	in := []byte(commonHeaders[1])
+	if allocs := testing.AllocsPerRun(100, func() { ParseBytes(in) }); allocs != 2 {
+		t.Errorf("ParseBytes(%#q) allocated %v times per run, want 2", in, allocs)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, in := range commonHeaders {
+		b.Run(strconv.Itoa(len(in)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseWithOptions(in, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	var c Cookie
+	for _, in := range commonHeaders {
+		b.Run(strconv.Itoa(len(in)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseInto(&c, in, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	in := []byte(commonHeaders[1])
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseBytes(in)
+	}
+}
+
+var maxLifetimeTests = []struct {
+	in  *Cookie
+	out string
+}{
+	{
+		&Cookie{Name: "a", Value: "b", Expires: ExpiresNever()},
+		"a=b; Expires=Fri, 05 Feb 2016 00:00:00 UTC",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", Expires: time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC)},
+		"a=b; Expires=Fri, 02 Jan 2015 00:00:00 UTC",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", MaxAge: int(maxInt)},
+		"a=b; Max-Age=34560000",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", MaxAge: 60},
+		"a=b; Max-Age=60",
+	},
+	{
+		&Cookie{Name: "a", Value: "b", MaxAge: -1},
+		"a=b; Max-Age=0",
+	},
+}
+
+func TestMarshalMaxLifetime(t *testing.T) {
+	opts := &MarshalOptions{
+		MaxLifetime: 400 * 24 * time.Hour,
+		Now:         time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, test := range maxLifetimeTests {
+		out, err := test.in.MarshalWithOptions(true, opts)
+		if out != test.out || err != nil {
+			t.Errorf("(%+v).MarshalWithOptions(true, max lifetime):", test.in)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+
+	// Sub-second limits round up rather than truncating Max-Age to zero.
+	opts.MaxLifetime = 500 * time.Millisecond
+	for _, test := range []struct {
+		in  *Cookie
+		out string
+	}{
+		{&Cookie{Name: "a", Value: "b", MaxAge: 60}, "a=b; Max-Age=1"},
+		{&Cookie{Name: "a", Value: "b", Expires: ExpiresNever()}, "a=b; Expires=Thu, 01 Jan 2015 00:00:01 UTC"},
+	} {
+		out, err := test.in.MarshalWithOptions(true, opts)
+		if out != test.out || err != nil {
+			t.Errorf("(%+v).MarshalWithOptions(true, sub-second max lifetime):", test.in)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+
+	// Without a limit, ExpiresNever should survive a round trip.
+	c := &Cookie{Name: "a", Value: "b", Expires: ExpiresNever()}
+	s, _ := c.Marshal(true)
+	if p, err := Parse(s); err != nil || !p.Expires.Equal(ExpiresNever()) {
+		t.Errorf("Parse(%#q) = %+v, %+v", s, p, err)
+	}
+}
+
+func TestExpiresIn(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return time.Date(2015, 1, 1, 0, 0, 0, 500, time.UTC) }
+
+	if got, want := ExpiresIn(time.Hour), time.Date(2015, 1, 1, 1, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ExpiresIn(1h) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package cookie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var (
+	errInvalidValue = errors.New("invalid cookie value")
+)
+
+// A CookieMap holds the cookies of a "Cookie" request header, keyed by name.
+// Unlike a regular map it preserves the order of the cookies, as well as any
+// duplicates, which browsers send when cookies with the same name are set
+// for different paths or domains.
+type CookieMap struct {
+	pairs []pair
+}
+
+// pair is a single cookie in a CookieMap.
+type pair struct {
+	name, value string
+}
+
+// ParseRequestHeader parses the value of a "Cookie" header. Like net/http, it
+// skips invalid pairs rather than failing, so that one malformed cookie,
+// which may have been set by another application on the same domain, doesn't
+// take the others with it.
+func ParseRequestHeader(header string) *CookieMap {
+	m := &CookieMap{}
+
+	for header != "" {
+		var part string
+		if i := strings.IndexByte(header, ';'); i < 0 {
+			part, header = header, ""
+		} else {
+			part, header = header[:i], header[i+1:]
+		}
+
+		var c Cookie
+		if part = trim(part); part != "" && parsePair(&c, part, defaultParseOptions) == nil {
+			m.Add(c.Name, c.Value)
+		}
+	}
+
+	return m
+}
+
+// ParseCookieHeader parses the value of a "Cookie" request header, returning
+// its cookies in order. Only the Name and Value fields of each cookie are set.
+// Invalid pairs are skipped as by ParseRequestHeader, so the returned error is
+// always nil; it is kept for compatibility.
+func ParseCookieHeader(raw string) ([]*Cookie, error) {
+	m := ParseRequestHeader(raw)
+
+	var cookies []*Cookie
+	for _, p := range m.pairs {
+		cookies = append(cookies, &Cookie{Name: p.name, Value: p.value})
+	}
+
+	return cookies, nil
+}
+
+// Len returns the number of cookies in the map, including duplicates.
+func (m *CookieMap) Len() int {
+	return len(m.pairs)
+}
+
+// Get returns the value of the first cookie with the given name. The second
+// return value is false if there is no such cookie.
+func (m *CookieMap) Get(name string) (string, bool) {
+	for _, p := range m.pairs {
+		if p.name == name {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns the values of all cookies with the given name, in order.
+func (m *CookieMap) GetAll(name string) []string {
+	var values []string
+	for _, p := range m.pairs {
+		if p.name == name {
+			values = append(values, p.value)
+		}
+	}
+	return values
+}
+
+// Has returns true if the map holds a cookie with the given name.
+func (m *CookieMap) Has(name string) bool {
+	_, ok := m.Get(name)
+	return ok
+}
+
+// Add appends a cookie to the map. An error is returned, and the cookie is
+// not added, if the name contains characters outside of TokenClass or the
+// value contains characters outside of ValueClass.
+func (m *CookieMap) Add(name, value string) error {
+	if !isValidName(name) {
+		return errInvalidName
+	}
+	if !isValidValue(value) {
+		return errInvalidValue
+	}
+
+	m.pairs = append(m.pairs, pair{name, value})
+	return nil
+}
+
+// Del removes all cookies with the given name.
+func (m *CookieMap) Del(name string) {
+	pairs := m.pairs[:0]
+	for _, p := range m.pairs {
+		if p.name != name {
+			pairs = append(pairs, p)
+		}
+	}
+	m.pairs = pairs
+}
+
+// Encode returns the cookies in the map as the value of a "Cookie" header.
+// Since Add rejects invalid cookies, the result always parses back into the
+// same cookies.
+func (m *CookieMap) Encode() string {
+	b := new(bytes.Buffer)
+	for i, p := range m.pairs {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(p.name)
+		b.WriteByte('=')
+		if shouldQuoteValue(p.value) {
+			b.WriteByte('"')
+			b.WriteString(p.value)
+			b.WriteByte('"')
+		} else {
+			b.WriteString(p.value)
+		}
+	}
+	return b.String()
+}
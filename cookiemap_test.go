@@ -0,0 +1,74 @@
+package cookie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCookieMap(t *testing.T) {
+	m := ParseRequestHeader(`a=1; b=" 2"; a=3; c=4`)
+
+	if v, ok := m.Get("a"); v != "1" || !ok {
+		t.Errorf("Get(a) = %q, %v, want %q, true", v, ok, "1")
+	}
+	if v, ok := m.Get("x"); v != "" || ok {
+		t.Errorf("Get(x) = %q, %v, want %q, false", v, ok, "")
+	}
+	if all := m.GetAll("a"); !reflect.DeepEqual(all, []string{"1", "3"}) {
+		t.Errorf("GetAll(a) = %q", all)
+	}
+	if !m.Has("c") || m.Has("d") {
+		t.Errorf("Has returned wrong results")
+	}
+
+	m.Del("a")
+	m.Add("d", "5")
+
+	// Invalid names and values are rejected, so they can't be used to
+	// inject additional cookies.
+	for _, p := range []pair{{"e", "1; admin=1"}, {"e f", "1"}, {"", "1"}, {"e", ""}, {"e", `"`}} {
+		if err := m.Add(p.name, p.value); err == nil {
+			t.Errorf("Add(%q, %q) succeeded", p.name, p.value)
+		}
+	}
+
+	if out, want := m.Encode(), `b=" 2"; c=4; d=5`; out != want {
+		t.Errorf("Encode() = %#q, want %#q", out, want)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", m.Len())
+	}
+}
+
+var requestHeaderTests = []struct {
+	in  string
+	out []pair
+}{
+	{"", nil},
+	{" ; ", nil},
+	{"a=1", []pair{{"a", "1"}}},
+	{` a=1; b="2";; a=3 `, []pair{{"a", "1"}, {"b", "2"}, {"a", "3"}}},
+	{"a=1; Path=x", []pair{{"a", "1"}, {"Path", "x"}}},
+	{"a=1; b; c d=2; e=3", []pair{{"a", "1"}, {"e", "3"}}},
+}
+
+func TestParseRequestHeader(t *testing.T) {
+	for _, test := range requestHeaderTests {
+		if m := ParseRequestHeader(test.in); !reflect.DeepEqual(m.pairs, test.out) {
+			t.Errorf("ParseRequestHeader(%q) = %+v, want %+v", test.in, m.pairs, test.out)
+		}
+	}
+}
+
+func TestParseCookieHeader(t *testing.T) {
+	for _, test := range requestHeaderTests {
+		var want []*Cookie
+		for _, p := range test.out {
+			want = append(want, &Cookie{Name: p.name, Value: p.value})
+		}
+
+		if out, err := ParseCookieHeader(test.in); !reflect.DeepEqual(out, want) || err != nil {
+			t.Errorf("ParseCookieHeader(%q) = %+v, %v, want %+v", test.in, out, err, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cookie
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+var (
+	errNoCSRFSigner  = errors.New("no CSRF signer")
+	errNoCSRFSession = errors.New("no CSRF session function")
+)
+
+// CSRF implements the signed double-submit cookie pattern for protection
+// against cross-site request forgery. A random token is stored in a cookie
+// signed by a Signer, and must be echoed back in a form field or header by
+// the client. The signature also covers the ID of the session the request
+// belongs to, so that a cookie planted by an attacker, for example through a
+// sibling subdomain, is only accepted along with the attacker's own session.
+type CSRF struct {
+	// Signer used to sign and verify the cookie, whose keys can be rotated
+	// as described for Signer.
+	Signer *Signer
+
+	// Session returns the ID of the session the request belongs to. It must
+	// be set. Requests made before a session is established may return an
+	// empty string, in which case tokens must be issued anew once it is.
+	Session func(r *http.Request) string
+
+	// Name of the cookie. Defaults to "csrf_token".
+	Name string
+
+	// Path of the cookie. Defaults to "/".
+	Path string
+
+	// Secure restricts the cookie to HTTPS requests.
+	Secure bool
+}
+
+// name returns the configured cookie name, or the default.
+func (x *CSRF) name() string {
+	if x.Name == "" {
+		return "csrf_token"
+	}
+	return x.Name
+}
+
+// bound returns the cookie actually signed for a token: the session ID is
+// folded into its name, prefixed by its length so that no two combinations
+// of session ID and token produce the same input to the MAC.
+func (x *CSRF) bound(r *http.Request, value string) *Cookie {
+	session := x.Session(r)
+	return &Cookie{
+		Name:  x.name() + "\x00" + strconv.Itoa(len(session)) + "\x00" + session,
+		Value: value,
+	}
+}
+
+// IssueCSRF returns a token for embedding in forms or passing to scripts. If
+// the request already carries a valid CSRF cookie its token is reused,
+// otherwise a new token is generated and a cookie holding it is added to the
+// response.
+func (x *CSRF) IssueCSRF(w http.ResponseWriter, r *http.Request) (string, error) {
+	if x.Signer == nil {
+		return "", errNoCSRFSigner
+	}
+	if x.Session == nil {
+		return "", errNoCSRFSession
+	}
+
+	if token, ok := x.token(r); ok {
+		return token, nil
+	}
+
+	token, err := GenerateValue(32)
+	if err != nil {
+		return "", err
+	}
+
+	signed := x.bound(r, token)
+	if err := x.Signer.Sign(signed); err != nil {
+		return "", err
+	}
+
+	c := &Cookie{
+		Name:     x.name(),
+		Value:    signed.Value,
+		Path:     x.Path,
+		Secure:   x.Secure,
+		HttpOnly: true,
+		SameSite: SameSiteLax,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+
+	s, err := c.Marshal(true)
+	if err != nil {
+		return "", err
+	}
+
+	w.Header().Add("Set-Cookie", s)
+	return token, nil
+}
+
+// VerifyCSRF returns true if the request carries a CSRF cookie holding the
+// specified token, validly signed for the request's session.
+func (x *CSRF) VerifyCSRF(r *http.Request, token string) bool {
+	if x.Signer == nil || x.Session == nil || token == "" {
+		return false
+	}
+
+	expected, ok := x.token(r)
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// token extracts the token from the first of the request's CSRF cookies
+// whose signature is valid for the request's session. Other cookies with the
+// same name, such as those set for a parent domain, are skipped.
+func (x *CSRF) token(r *http.Request) (string, bool) {
+	name := x.name()
+
+	for _, header := range r.Header["Cookie"] {
+		s := NewScanner(header)
+		for s.Next() {
+			if c := s.Cookie(); c.Name == name {
+				signed := x.bound(r, c.Value)
+				if x.Signer.Verify(signed) == nil {
+					return signed.Value, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,105 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	session := func(r *http.Request) string {
+		sid, _ := ParseRequestHeader(r.Header.Get("Cookie")).Get("sid")
+		return sid
+	}
+	x := &CSRF{Signer: NewSigner([]byte("0123456789abcdef0123456789abcdef")), Session: session}
+
+	// First request; a cookie should be issued.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "sid=alice")
+
+	token, err := x.IssueCSRF(w, r)
+	if err != nil {
+		t.Fatalf("IssueCSRF: %v", err)
+	}
+
+	setCookie := w.Header().Get("Set-Cookie")
+	c, err := Parse(setCookie)
+	if err != nil {
+		t.Fatalf("Parse(%#q): %v", setCookie, err)
+	}
+	if !c.HttpOnly || !strings.HasPrefix(c.Value, token+".") {
+		t.Errorf("unexpected CSRF cookie: %#q", setCookie)
+	}
+
+	// Second request; the same token should be returned.
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Cookie", "sid=alice; other=1; "+c.Name+"="+c.Value)
+
+	w = httptest.NewRecorder()
+	if again, _ := x.IssueCSRF(w, r); again != token || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("IssueCSRF didn't reuse the existing token")
+	}
+
+	if !x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF rejected a valid token")
+	}
+
+	// A malformed cookie set by another application mustn't hide the token.
+	r.Header.Set("Cookie", "junk; sid=alice; "+c.Name+"="+c.Value)
+	if !x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF rejected a valid token following an invalid pair")
+	}
+
+	// Nor must another cookie with the same name, such as one planted
+	// through a sibling subdomain.
+	r.Header.Set("Cookie", "sid=alice; "+c.Name+"=planted.x; "+c.Name+"="+c.Value)
+	if !x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF rejected a valid token following an invalid cookie")
+	}
+
+	// The token is bound to the session.
+	r.Header.Set("Cookie", "sid=mallory; "+c.Name+"="+c.Value)
+	if x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF accepted a token issued for another session")
+	}
+	r.Header.Set("Cookie", c.Name+"="+c.Value)
+	if x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF accepted a token issued for a session without one")
+	}
+	if (&CSRF{Signer: x.Signer}).VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF succeeded without a session function")
+	}
+	if x.VerifyCSRF(r, token+"x") {
+		t.Errorf("VerifyCSRF accepted an invalid token")
+	}
+
+	// A cookie signed with a different key must be rejected.
+	y := &CSRF{Signer: NewSigner([]byte("fedcba9876543210fedcba9876543210")), Session: session}
+	if y.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF accepted a cookie signed with another key")
+	}
+
+	r = httptest.NewRequest("POST", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "alice"})
+	r.AddCookie(&http.Cookie{Name: c.Name, Value: token + ".forged"})
+	if x.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF accepted a forged signature")
+	}
+
+	// Tokens signed with a retired key still verify once it's rotated out.
+	rotated := &CSRF{Signer: NewSigner([]byte("fedcba9876543210fedcba9876543210"), x.Signer.Keys[0]), Session: session}
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Cookie", "sid=alice; "+c.Name+"="+c.Value)
+	if !rotated.VerifyCSRF(r, token) {
+		t.Errorf("VerifyCSRF rejected a cookie signed with a retired key")
+	}
+
+	if _, err := (&CSRF{Session: session}).IssueCSRF(httptest.NewRecorder(), r); err == nil {
+		t.Errorf("IssueCSRF succeeded without a signer")
+	}
+	if _, err := (&CSRF{Signer: x.Signer}).IssueCSRF(httptest.NewRecorder(), r); err == nil {
+		t.Errorf("IssueCSRF succeeded without a session function")
+	}
+}
@@ -0,0 +1,155 @@
+package cookie
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxRejections is the number of rejected cookies remembered by a jar for
+// DebugHandler.
+const maxRejections = 32
+
+// maxDebugBuckets is the number of domain roots listed by DebugHandler.
+const maxDebugBuckets = 20
+
+// rejection records a cookie rejected by SetCookie.
+type rejection struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	Name   string    `json:"name"`
+	Reason string    `json:"reason"`
+}
+
+// gcStats counts the entries removed by the jar itself, rather than by
+// "Set-Cookie" headers or explicit calls.
+type gcStats struct {
+	// Expired entries removed when their domain was next looked up.
+	Swept int `json:"swept"`
+
+	// Entries evicted to stay within the jar's limits.
+	Evicted int `json:"evicted"`
+}
+
+// reject remembers a cookie rejected by SetCookie.
+func (j *Jar) reject(host string, c *Cookie, err error, now time.Time) {
+	if len(j.rejections) == maxRejections {
+		copy(j.rejections, j.rejections[1:])
+		j.rejections = j.rejections[:maxRejections-1]
+	}
+	j.rejections = append(j.rejections, rejection{
+		Time:   now,
+		Host:   host,
+		Name:   c.Name,
+		Reason: err.Error(),
+	})
+}
+
+// debugSummary is the document served by DebugHandler.
+type debugSummary struct {
+	Time       time.Time     `json:"time"`
+	Stats      debugStats    `json:"stats"`
+	Limits     debugLimits   `json:"limits"`
+	Roots      int           `json:"roots"`
+	Buckets    []debugBucket `json:"buckets"`
+	Rejections []rejection   `json:"rejections"`
+	GC         gcStats       `json:"gc"`
+}
+
+// debugStats is the JSON form of Stats.
+type debugStats struct {
+	Total      int `json:"total"`
+	Session    int `json:"session"`
+	Persistent int `json:"persistent"`
+	Expired    int `json:"expired"`
+	Unsent     int `json:"unsent"`
+}
+
+// debugLimits describes the jar's limits, and how close it is to them.
+type debugLimits struct {
+	PerRoot  int     `json:"perRoot"`
+	Total    int     `json:"total"`
+	Evict    string  `json:"evict"`
+	Pressure float64 `json:"pressure"`
+}
+
+// debugBucket is the number of entries stored for a domain root.
+type debugBucket struct {
+	Root    string `json:"root"`
+	Entries int    `json:"entries"`
+}
+
+// DebugHandler returns an http.Handler serving a JSON summary of the jar's
+// health, for mounting under a path such as "/debug/cookies". The summary
+// includes the jar's Stats, its limits and Pressure, the number of entries
+// of the largest domain roots, the most recent cookies rejected by SetCookie
+// or Tx.SetCookie along with the reasons, and the number of expired entries
+// swept and of entries evicted. All of it is gathered at once, so that the
+// figures are consistent with each other.
+//
+// The summary doesn't include any cookie values, but does reveal the names
+// of cookies and the domains they were set by, so the handler shouldn't be
+// exposed publicly.
+func (j *Jar) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.MarshalIndent(j.debugSummary(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(append(b, '\n'))
+	})
+}
+
+// debugSummary gathers the summary served by DebugHandler.
+func (j *Jar) debugSummary() *debugSummary {
+	now := j.now()
+
+	// Stats advances the jar's expiry counts, so the write lock is needed.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := j.currentStats(now)
+
+	d := &debugSummary{
+		Time: now,
+		Stats: debugStats{
+			Total:      stats.Total(),
+			Session:    stats.Session,
+			Persistent: stats.Persistent,
+			Expired:    stats.Expired,
+			Unsent:     stats.Unsent,
+		},
+		Limits: debugLimits{
+			PerRoot:  j.limits.PerRoot,
+			Total:    j.limits.Total,
+			Evict:    j.limits.Evict.String(),
+			Pressure: j.pressure(),
+		},
+		Roots:      len(j.ent),
+		Buckets:    []debugBucket{},
+		Rejections: append([]rejection{}, j.rejections...),
+		GC:         j.gc,
+	}
+
+	for root, bucket := range j.ent {
+		d.Buckets = append(d.Buckets, debugBucket{root, len(bucket)})
+	}
+
+	sort.Slice(d.Buckets, func(a, b int) bool {
+		x, y := d.Buckets[a], d.Buckets[b]
+		if x.Entries != y.Entries {
+			return x.Entries > y.Entries
+		}
+		return x.Root < y.Root
+	})
+	if len(d.Buckets) > maxDebugBuckets {
+		d.Buckets = d.Buckets[:maxDebugBuckets]
+	}
+
+	return d
+}
@@ -0,0 +1,175 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidEntry = errors.New("invalid entry")
+)
+
+// A DedupePolicy selects which of several imported entries sharing a domain,
+// path and name is kept.
+type DedupePolicy int
+
+const (
+	// DedupeLast keeps the entry appearing last in the input.
+	DedupeLast DedupePolicy = iota
+
+	// DedupeLatestCreated keeps the most recently created entry.
+	DedupeLatestCreated
+
+	// DedupeLatestExpires keeps the entry expiring last. Session entries
+	// are considered to expire before persistent ones.
+	DedupeLatestExpires
+)
+
+// ImportOptions controls the behavior of Import and ImportHARWithOptions.
+type ImportOptions struct {
+	// Policy used to choose between duplicate entries. Ties are resolved
+	// in favor of the entry appearing last in the input.
+	Dedupe DedupePolicy
+}
+
+// Import adds entries obtained from an external source, such as a browser's
+// cookie database, to the jar. Each entry is validated as if its cookie had
+// been set over HTTPS by its domain, so that, for example, domain cookies for
+// public suffixes are rejected, and entries which have expired according to
+// the jar's clock (see SetClock) are ignored. Entries with the same domain,
+// path and name are deduplicated according to the options, and those dropped
+// in the process are returned. Entries are only added if all of them are
+// valid. Passing nil options is equivalent to passing a zero ImportOptions.
+func (j *Jar) Import(entries []Entry, opts *ImportOptions) ([]Entry, error) {
+	now := j.now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	list := make([]*jarEntry, 0, len(entries))
+
+	for i := range entries {
+		entry, err := j.importEntry(&entries[i], now)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			list = append(list, entry)
+		}
+	}
+
+	var dropped []Entry
+	profile("Jar.Import", func() { dropped = j.importEntries(list, now, opts) })
+
+	return dropped, nil
+}
+
+// importEntry validates an imported entry the way prepare validates cookies
+// passed to SetCookie, treating its domain as the host which set it, and
+// creates the corresponding jar entry, keeping its creation time, labels,
+// send count, unparsed attributes and partition. It returns a nil entry if the entry has expired,
+// or should be discarded according to the jar's prefix policy.
+func (j *Jar) importEntry(e *Entry, now time.Time) (*jarEntry, error) {
+	if !isValidName(e.Name) || e.Value != "" && !isValidValue(e.Value) {
+		return nil, errInvalidEntry
+	}
+
+	host, err := CanonicalHost(strings.TrimPrefix(e.Domain, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	c := e.Cookie()
+	if !e.HostOnly {
+		c.Domain = host
+	}
+
+	entry, remove, err := j.prepare("https", host, "", c, now)
+	if err != nil || entry == nil || remove {
+		return nil, err
+	}
+
+	// A domain cookie for a public suffix would be sent to every site under
+	// it. SetCookie only accepts such cookies as host-only cookies of the
+	// public suffix itself.
+	if !e.HostOnly && entry.HostOnly {
+		return nil, errIllegalDomain
+	}
+
+	if !e.Created.IsZero() {
+		entry.Created = e.Created
+	}
+	entry.Labels = e.Labels
+	entry.Sends = e.Sends
+	entry.LastUsed = e.LastUsed
+	entry.Unparsed = e.Unparsed
+	if e.Partition != "" {
+		entry.Partition = e.Partition
+		entry.Key = j.entryKey(entry)
+	}
+
+	return entry, nil
+}
+
+// checkImport returns the error Import would fail with because of e, if
+// any, without modifying the jar.
+func (j *Jar) checkImport(e *Entry, now time.Time) error {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	_, err := j.importEntry(e, now)
+	return err
+}
+
+// importEntries deduplicates and stores entries, returning those dropped.
+func (j *Jar) importEntries(entries []*jarEntry, now time.Time, opts *ImportOptions) []Entry {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	winners := make(map[string]*jarEntry, len(entries))
+	var dropped []Entry
+
+	for _, entry := range entries {
+		prev, ok := winners[entry.Key]
+		if !ok {
+			winners[entry.Key] = entry
+			continue
+		}
+
+		if opts.Dedupe.prefers(prev, entry) {
+			dropped = append(dropped, entry.Entry)
+		} else {
+			dropped = append(dropped, prev.Entry)
+			winners[entry.Key] = entry
+		}
+	}
+
+	// Store the winners in input order.
+	for _, entry := range entries {
+		if winners[entry.Key] == entry {
+			j.set(entry, now)
+		}
+	}
+
+	return dropped
+}
+
+// prefers returns true if a should be kept over b, which appears later in
+// the input.
+func (p DedupePolicy) prefers(a, b *jarEntry) bool {
+	switch p {
+	case DedupeLatestCreated:
+		return a.Created.After(b.Created)
+	case DedupeLatestExpires:
+		switch {
+		case a.Expires.IsZero():
+			return false
+		case b.Expires.IsZero():
+			return true
+		}
+		return a.Expires.After(b.Expires)
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package cookie
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var (
+	differential     = flag.Int("differential", 0, "number of random Set-Cookie headers to compare against net/http")
+	differentialSeed = flag.Int64("differential.seed", 1, "seed for -differential")
+)
+
+// differentialCorpus holds Set-Cookie headers which are compared against
+// net/http on every test run.
+var differentialCorpus = []string{
+	"a=b",
+	" a=b ",
+	`a="b"`,
+	`a="b`,
+	"a=b c",
+	"a=b,c",
+	"a=",
+	"=b",
+	"a",
+	"a=b;",
+	"a=b;;c",
+	"a=b; Path=/",
+	"a=b; path",
+	"a=b; Path=/\"x\"",
+	"a=b; Domain=.example.com",
+	"a=b; Domain=example.com:80",
+	"a=b; Domain=",
+	"a=b; Max-Age=0",
+	"a=b; Max-Age=-1",
+	"a=b; Max-Age=x",
+	"a=b; Max-Age=010",
+	"a=b; Max-Age=99999999999999999999",
+	"a=b; Secure",
+	"a=b; secure; HTTPONLY",
+	"a=b; Expires=Wed, 23 Nov 2011 01:05:03 GMT",
+	"a=b; expires=Wed, 23-Nov-2011 01:05:03 GMT",
+	"a=b; Expires=bogus",
+	"a=b; SameSite=Lax",
+	"a=b; Secure; Partitioned",
+	"a=b; foo=bar",
+	`a=b; foo="bar"`,
+	`a=b; foo=\`,
+	"a=b; =x",
+	"a\x01=b",
+	"a=\x7f",
+	"a=é",
+	"a b=c",
+}
+
+// A deviation is a known, intentional difference between this package and
+// net/http.
+type deviation struct {
+	name  string
+	match func(in string, ours *Cookie, err error, theirs *http.Cookie) bool
+}
+
+var deviations = []deviation{
+	{
+		// We require cookie values to be non-empty.
+		"empty value",
+		func(in string, ours *Cookie, err error, theirs *http.Cookie) bool {
+			return err != nil && theirs != nil && theirs.Value == ""
+		},
+	},
+	{
+		// We reject cookies with malformed attributes, rather than
+		// ignoring the attributes.
+		"strict attributes",
+		func(in string, ours *Cookie, err error, theirs *http.Cookie) bool {
+			if theirs == nil {
+				return false
+			}
+			return err == errParseAttr || err == errParseDomain ||
+				err == errParseExpires || err == errParseMaxAge
+		},
+	},
+	{
+		// We don't allow whitespace between a cookie's name and the '='.
+		"whitespace before '='",
+		func(in string, ours *Cookie, err error, theirs *http.Cookie) bool {
+			if err == nil || theirs == nil {
+				return false
+			}
+			name := in[:strings.IndexByte(in, '=')]
+			return strings.TrimRight(name, " \t") != name
+		},
+	},
+	{
+		// We clamp huge Max-Age values instead of ignoring them.
+		"Max-Age overflow",
+		func(in string, ours *Cookie, err error, theirs *http.Cookie) bool {
+			return ours != nil && ours.MaxAge == int(MaxAgeLimit)
+		},
+	},
+	{
+		// We accept Max-Age values with leading zeros.
+		"Max-Age leading zeros",
+		func(in string, ours *Cookie, err error, theirs *http.Cookie) bool {
+			if ours == nil || theirs == nil {
+				return false
+			}
+			for _, attr := range theirs.Unparsed {
+				if len(attr) > 9 && strings.EqualFold(attr[:8], "max-age=") && attr[8] == '0' {
+					return true
+				}
+			}
+			return false
+		},
+	},
+}
+
+// netHTTPSameSite maps net/http's SameSite modes to ours. Both an absent and
+// an unrecognized attribute map to SameSiteDefault.
+var netHTTPSameSite = map[http.SameSite]SameSite{
+	0:                        SameSiteDefault,
+	http.SameSiteDefaultMode: SameSiteDefault,
+	http.SameSiteLaxMode:     SameSiteLax,
+	http.SameSiteStrictMode:  SameSiteStrict,
+	http.SameSiteNoneMode:    SameSiteNone,
+}
+
+// compareNetHTTP parses a Set-Cookie header using both this package and
+// net/http, and describes the differences between the results. An empty
+// string means the results are equivalent.
+func compareNetHTTP(in string) (string, *Cookie, error, *http.Cookie) {
+	resp := &http.Response{Header: http.Header{"Set-Cookie": {in}}}
+
+	var theirs *http.Cookie
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		theirs = cookies[0]
+	}
+
+	ours, err := Parse(in)
+
+	switch {
+	case ours == nil && theirs == nil:
+		return "", ours, err, theirs
+	case ours == nil:
+		return fmt.Sprintf("only accepted by net/http (%v)", err), ours, err, theirs
+	case theirs == nil:
+		return "only accepted by this package", ours, err, theirs
+	}
+
+	var diffs []string
+
+	if ours.Name != theirs.Name {
+		diffs = append(diffs, fmt.Sprintf("Name %q vs %q", ours.Name, theirs.Name))
+	}
+	if ours.Value != theirs.Value {
+		diffs = append(diffs, fmt.Sprintf("Value %q vs %q", ours.Value, theirs.Value))
+	}
+	if ours.Domain != theirs.Domain {
+		diffs = append(diffs, fmt.Sprintf("Domain %q vs %q", ours.Domain, theirs.Domain))
+	}
+	if ours.Path != theirs.Path {
+		diffs = append(diffs, fmt.Sprintf("Path %q vs %q", ours.Path, theirs.Path))
+	}
+	if !ours.Expires.Equal(theirs.Expires) {
+		diffs = append(diffs, fmt.Sprintf("Expires %s vs %s", ours.Expires, theirs.Expires))
+	}
+	if ours.MaxAge != theirs.MaxAge {
+		diffs = append(diffs, fmt.Sprintf("MaxAge %d vs %d", ours.MaxAge, theirs.MaxAge))
+	}
+	if ours.Secure != theirs.Secure {
+		diffs = append(diffs, fmt.Sprintf("Secure %v vs %v", ours.Secure, theirs.Secure))
+	}
+	if ours.HttpOnly != theirs.HttpOnly {
+		diffs = append(diffs, fmt.Sprintf("HttpOnly %v vs %v", ours.HttpOnly, theirs.HttpOnly))
+	}
+	if ours.SameSite != netHTTPSameSite[theirs.SameSite] {
+		diffs = append(diffs, fmt.Sprintf("SameSite %v vs %v", ours.SameSite, theirs.SameSite))
+	}
+	if strings.Join(ours.Unparsed, "; ") != strings.Join(theirs.Unparsed, "; ") {
+		diffs = append(diffs, fmt.Sprintf("Unparsed %q vs %q", ours.Unparsed, theirs.Unparsed))
+	}
+
+	return strings.Join(diffs, ", "), ours, err, theirs
+}
+
+// checkNetHTTP reports divergences from net/http not explained by a known
+// deviation.
+func checkNetHTTP(t *testing.T, in string) {
+	diff, ours, err, theirs := compareNetHTTP(in)
+	if diff == "" {
+		return
+	}
+
+	for _, d := range deviations {
+		if d.match(in, ours, err, theirs) {
+			return
+		}
+	}
+
+	t.Errorf("Parse(%#q) diverges from net/http: %s", in, diff)
+}
+
+func TestDifferential(t *testing.T) {
+	for _, in := range differentialCorpus {
+		checkNetHTTP(t, in)
+	}
+
+	// Random inputs, assembled from fragments likely to trip up a parser.
+	fragments := []string{
+		"a", "b", "x", "=", "=", ";", ";", " ", "\t", `"`, ",", "\\", "-", ".",
+		"/", "0", "1", "é", "\x7f", "Path", "Domain", "Max-Age", "Expires",
+		"Secure", "HttpOnly", "example.com", "Wed, 23 Nov 2011 01:05:03 GMT",
+	}
+
+	r := rand.New(rand.NewSource(*differentialSeed))
+
+	for i := 0; i < *differential; i++ {
+		var b []byte
+		for n := 1 + r.Intn(12); n > 0; n-- {
+			b = append(b, fragments[r.Intn(len(fragments))]...)
+		}
+		checkNetHTTP(t, string(b))
+	}
+}
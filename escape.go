@@ -0,0 +1,141 @@
+package cookie
+
+const hexDigits = "0123456789ABCDEF"
+
+// MarshalEscaped is like Marshal, but percent-encodes any bytes in the
+// cookie's value which aren't allowed by RFC 6265 (including spaces, commas
+// and '%' itself), making it possible to store arbitrary text in a cookie.
+// It's shorthand for MarshalWithOptions with MarshalOptions.EncodePercent set.
+// Use ParseEscaped to reverse the encoding.
+func (c *Cookie) MarshalEscaped(attrs bool) (string, error) {
+	return c.MarshalWithOptions(attrs, escapedMarshalOptions)
+}
+
+// escapedMarshalOptions are used by MarshalEscaped.
+var escapedMarshalOptions = &MarshalOptions{EncodePercent: true}
+
+// ParseEscaped is like Parse, but decodes percent-encoded bytes in the
+// cookie's value, as produced by MarshalEscaped. It's shorthand for
+// ParseWithOptions with ParseOptions.DecodePercent set.
+func ParseEscaped(raw string) (*Cookie, error) {
+	return ParseWithOptions(raw, escapedParseOptions)
+}
+
+// escapedParseOptions are used by ParseEscaped.
+var escapedParseOptions = &ParseOptions{DecodePercent: true}
+
+// shouldEscape returns true if b is not an RFC 6265 cookie-octet, or if it
+// is the escape character itself.
+func shouldEscape(b byte) bool {
+	return b <= ' ' || b >= 0x7f || b == '"' || b == ',' || b == ';' ||
+		b == '\\' || b == '%'
+}
+
+// escapeValue percent-encodes all bytes for which shouldEscape returns true.
+func escapeValue(s string) string {
+	var n int
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			n++
+		}
+	}
+	if n == 0 {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s)+2*n)
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; shouldEscape(c) {
+			buf = append(buf, '%', hexDigits[c>>4], hexDigits[c&15])
+		} else {
+			buf = append(buf, c)
+		}
+	}
+
+	return string(buf)
+}
+
+// unescapeValue decodes percent-encoded bytes. The second return value is
+// false if the input contains a malformed escape sequence.
+func unescapeValue(s string) (string, bool) {
+	var n int
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			if i+2 >= len(s) || unhex(s[i+1]) < 0 || unhex(s[i+2]) < 0 {
+				return "", false
+			}
+			n++
+			i += 2
+		}
+	}
+	if n == 0 {
+		return s, true
+	}
+
+	buf := make([]byte, 0, len(s)-2*n)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			buf = append(buf, byte(unhex(s[i+1])<<4|unhex(s[i+2])))
+			i += 2
+		} else {
+			buf = append(buf, s[i])
+		}
+	}
+
+	return string(buf), true
+}
+
+// unhex returns the value of a hexadecimal digit, or -1 if c isn't one.
+func unhex(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c - 'a' + 10)
+	case 'A' <= c && c <= 'F':
+		return int(c - 'A' + 10)
+	}
+	return -1
+}
+
+// unquoteValue decodes the backslash escapes \" and \\ in the contents of a
+// quoted cookie value. The second return value is false if the input contains
+// any other escape sequence, or a character which is neither escaped nor
+// valid in a cookie value.
+func unquoteValue(s string) (string, bool) {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' {
+			if i+1 == len(s) || (s[i+1] != '"' && s[i+1] != '\\') {
+				return "", false
+			}
+			i++
+			c = s[i]
+		} else if !IsValueChar(c) {
+			return "", false
+		}
+		buf = append(buf, c)
+	}
+	return string(buf), true
+}
+
+// quoteValue is the inverse of unquoteValue, returning a quoted string with
+// double quotes and backslashes escaped. The second return value is false if
+// the input contains any other character which isn't valid in a cookie value.
+func quoteValue(s string) (string, bool) {
+	buf := make([]byte, 0, len(s)+4)
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case IsValueChar(c):
+			buf = append(buf, c)
+		default:
+			return "", false
+		}
+	}
+	buf = append(buf, '"')
+	return string(buf), true
+}
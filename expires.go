@@ -0,0 +1,167 @@
+package cookie
+
+import (
+	"strings"
+	"time"
+)
+
+// expiresNever is the value returned by ExpiresNever.
+var expiresNever = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// ExpiresNever returns an Expires value expressing that a cookie should never
+// expire: the last second of the year 9999, the latest time representable in
+// the format used by Marshal. Clients cap cookie lifetimes anyway (Chrome
+// allows at most 400 days), so consider using MarshalOptions.MaxLifetime to
+// make the cookie's actual lifetime explicit.
+func ExpiresNever() time.Time {
+	return expiresNever
+}
+
+// ExpiresIn returns an Expires value d from now, according to the
+// package-level Now function.
+func ExpiresIn(d time.Duration) time.Time {
+	return Now().Add(d).UTC().Truncate(time.Second)
+}
+
+// withMaxLifetime returns a copy of c with its Expires and MaxAge fields
+// clamped so that the cookie expires no later than max after now. Limits
+// under a second are rounded up to one, since Max-Age can't express them and
+// truncating to zero would delete the cookie instead.
+func withMaxLifetime(c *Cookie, max time.Duration, now time.Time) *Cookie {
+	if max < time.Second {
+		max = time.Second
+	}
+
+	limit := now.Add(max)
+	seconds := int64(max / time.Second)
+
+	clampExpires := c.Expires.Unix() > 0 && c.Expires.After(limit)
+	clampMaxAge := int64(c.MaxAge) > seconds
+
+	if !clampExpires && !clampMaxAge {
+		return c
+	}
+
+	e := *c
+	if clampExpires {
+		e.Expires = limit
+	}
+	if clampMaxAge {
+		e.MaxAge = int(seconds)
+	}
+
+	return &e
+}
+
+// months are the month names recognized by parseCookieDate, of which only
+// the first three letters are significant.
+var months = [12]string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// parseCookieDate parses a date using the algorithm of RFC 6265, section
+// 5.1.1, which accepts dates with arbitrary delimiters and in any order of
+// their parts, such as "Thu, 01-Jan-70 00:00:00 GMT" or "1 Jan 2015 0:0:0".
+func parseCookieDate(s string) (time.Time, bool) {
+	var hour, min, sec, day, month, year int
+	var foundTime, foundDay, foundMonth, foundYear bool
+
+	for _, token := range strings.FieldsFunc(s, isDateDelimiter) {
+		switch {
+		case !foundTime && parseDateTime(token, &hour, &min, &sec):
+			foundTime = true
+		case !foundDay && parseDateDigits(token, 1, 2, &day):
+			foundDay = true
+		case !foundMonth && parseDateMonth(token, &month):
+			foundMonth = true
+		case !foundYear && parseDateDigits(token, 2, 4, &year):
+			foundYear = true
+		}
+	}
+
+	switch {
+	case year >= 70 && year <= 99:
+		year += 1900
+	case year >= 0 && year <= 69:
+		year += 2000
+	}
+
+	if !foundTime || !foundDay || !foundMonth || !foundYear ||
+		day < 1 || day > 31 || year < 1601 || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, min, sec, 0, time.UTC)
+	if t.Day() != day {
+		// Nonexistent days such as February 30 are normalized by time.Date.
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// isDateDelimiter returns true if r is a delimiter as defined in RFC 6265,
+// section 5.1.1.
+func isDateDelimiter(r rune) bool {
+	return r == 0x09 || r >= 0x20 && r <= 0x2f || r >= 0x3b && r <= 0x40 ||
+		r >= 0x5b && r <= 0x60 || r >= 0x7b && r <= 0x7e
+}
+
+// leadingDigits returns the number of decimal digits at the start of s.
+func leadingDigits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// parseDateDigits parses a token consisting of min to max digits, optionally
+// followed by a non-digit and arbitrary other characters.
+func parseDateDigits(token string, min, max int, v *int) bool {
+	n := leadingDigits(token)
+	if n < min || n > max {
+		return false
+	}
+
+	*v = 0
+	for i := 0; i < n; i++ {
+		*v = *v*10 + int(token[i]-'0')
+	}
+	return true
+}
+
+// parseDateTime parses a token of the form "hh:mm:ss", where every field has
+// one or two digits, optionally followed by a non-digit and arbitrary other
+// characters.
+func parseDateTime(token string, hour, min, sec *int) bool {
+	fields := [3]*int{hour, min, sec}
+	for i, v := range fields {
+		n := leadingDigits(token)
+		if !parseDateDigits(token[:n], 1, 2, v) {
+			return false
+		}
+		token = token[n:]
+
+		if i < 2 {
+			if token == "" || token[0] != ':' {
+				return false
+			}
+			token = token[1:]
+		}
+	}
+	return true
+}
+
+// parseDateMonth parses a token starting with the abbreviated name of a
+// month.
+func parseDateMonth(token string, month *int) bool {
+	if len(token) < 3 {
+		return false
+	}
+	for i, name := range months {
+		if strings.EqualFold(token[:3], name) {
+			*month = i + 1
+			return true
+		}
+	}
+	return false
+}
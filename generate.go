@@ -0,0 +1,88 @@
+package cookie
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var (
+	errInvalidLength = errors.New("invalid random value length")
+	errInvalidName   = errors.New("invalid cookie name")
+)
+
+// GenerateValue returns n cryptographically random bytes, encoded using
+// unpadded base64url so the result can be used as a cookie value as is.
+func GenerateValue(n int) (string, error) {
+	if n <= 0 {
+		return "", errInvalidLength
+	}
+
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// SessionOptions controls the cookies created by GenerateSessionCookie.
+type SessionOptions struct {
+	// Number of random bytes in the cookie's value. Defaults to 32.
+	Bytes int
+
+	// Domain and path of the cookie. The path defaults to "/".
+	Domain string
+	Path   string
+
+	// Insecure disables the Secure attribute, which is otherwise set.
+	Insecure bool
+
+	// Script makes the cookie available to scripts, by not setting the
+	// HttpOnly attribute.
+	Script bool
+}
+
+// GenerateSessionCookie returns a cookie with a random value, suitable for
+// holding a session identifier. The cookie expires after ttl, rounded up to
+// the nearest second, or at the end of the browser session if ttl isn't positive.
+// By default, the cookie is restricted to HTTPS and hidden from scripts.
+// Passing nil options is equivalent to passing a zero SessionOptions.
+func GenerateSessionCookie(name string, ttl time.Duration, opts *SessionOptions) (*Cookie, error) {
+	if opts == nil {
+		opts = &SessionOptions{}
+	}
+
+	if !isValidName(name) {
+		return nil, errInvalidName
+	}
+
+	n := opts.Bytes
+	if n == 0 {
+		n = 32
+	}
+
+	value, err := GenerateValue(n)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   opts.Domain,
+		Path:     opts.Path,
+		Secure:   !opts.Insecure,
+		HttpOnly: !opts.Script,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+
+	if ttl > 0 {
+		c.MaxAge = int((ttl + time.Second - 1) / time.Second)
+	}
+
+	return c, nil
+}
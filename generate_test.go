@@ -0,0 +1,44 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerateValue(t *testing.T) {
+	a, err := GenerateValue(16)
+	if err != nil {
+		t.Fatalf("GenerateValue: %v", err)
+	}
+	b, _ := GenerateValue(16)
+
+	if len(a) != 22 || a == b || !isValidValue(a) {
+		t.Errorf("GenerateValue(16) returned %q and %q", a, b)
+	}
+
+	if _, err := GenerateValue(0); err == nil {
+		t.Errorf("GenerateValue(0) succeeded")
+	}
+}
+
+func TestGenerateSessionCookie(t *testing.T) {
+	c, err := GenerateSessionCookie("sid", 1500*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("GenerateSessionCookie: %v", err)
+	}
+	if len(c.Value) != 43 || c.Path != "/" || !c.Secure || !c.HttpOnly || c.MaxAge != 2 {
+		t.Errorf("GenerateSessionCookie returned %+v", c)
+	}
+	if _, err := c.Marshal(true); err != nil {
+		t.Errorf("Marshal: %v", err)
+	}
+
+	c, _ = GenerateSessionCookie("sid", 0, &SessionOptions{Bytes: 8, Path: "/app", Insecure: true, Script: true})
+	if len(c.Value) != 11 || c.Path != "/app" || c.Secure || c.HttpOnly || c.MaxAge != 0 {
+		t.Errorf("GenerateSessionCookie with options returned %+v", c)
+	}
+
+	if _, err := GenerateSessionCookie("bad name", 0, nil); err == nil {
+		t.Errorf("GenerateSessionCookie accepted invalid name")
+	}
+}
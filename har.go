@@ -0,0 +1,133 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidHARCookie = errors.New("invalid HAR cookie")
+)
+
+// A HARCookie is a cookie as represented in the HTTP Archive (HAR) format
+// used by browser developer tools and security scanners.
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ExportHAR returns the jar's unexpired entries as HAR cookies, sorted by
+// domain root, domain, path and name. Following the convention of browsers,
+// the domain of cookies which aren't host-only is prefixed with a dot.
+func (j *Jar) ExportHAR(now time.Time) []HARCookie {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var cookies []HARCookie
+
+	for _, entry := range j.sorted() {
+		if !entry.Expires.IsZero() && !entry.Expires.After(now) {
+			continue
+		}
+
+		h := HARCookie{
+			Name:     entry.Name,
+			Value:    entry.Value,
+			Path:     entry.Path,
+			Domain:   entry.Domain,
+			HTTPOnly: entry.HttpOnly,
+			Secure:   entry.Secure,
+			SameSite: entry.SameSite.String(),
+		}
+
+		if !entry.HostOnly {
+			h.Domain = "." + h.Domain
+		}
+		if !entry.Expires.IsZero() {
+			h.Expires = entry.Expires.UTC().Format(time.RFC3339Nano)
+		}
+
+		cookies = append(cookies, h)
+	}
+
+	return cookies
+}
+
+// ImportHAR adds HAR cookies to the jar. A cookie whose domain starts with a
+// dot is treated as a domain cookie, and any other cookie as host-only. Like
+// Import, it validates cookies as if they had been set by their domain, and
+// ignores expired cookies. Cookies are only added if all of them are
+// valid.
+func (j *Jar) ImportHAR(cookies []HARCookie, now time.Time) error {
+	_, err := j.ImportHARWithOptions(cookies, now, nil)
+	return err
+}
+
+// ImportHARWithOptions is like ImportHAR, but deduplicates cookies according
+// to the options, returning the entries dropped in the process. Since HAR
+// cookies carry no creation time, DedupeLatestCreated keeps the last of any
+// duplicates.
+func (j *Jar) ImportHARWithOptions(cookies []HARCookie, now time.Time, opts *ImportOptions) (dropped []Entry, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	profile("Jar.ImportHAR", func() { dropped, err = j.importHAR(cookies, now, opts) })
+	return
+}
+
+// importHAR implements ImportHARWithOptions.
+func (j *Jar) importHAR(cookies []HARCookie, now time.Time, opts *ImportOptions) ([]Entry, error) {
+	entries := make([]*jarEntry, 0, len(cookies))
+
+	for _, h := range cookies {
+		entry, err := j.harEntry(h, now)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return j.importEntries(entries, now, opts), nil
+}
+
+// harEntry converts a HAR cookie to a jar entry, validated as described for
+// Import. It returns a nil entry if the cookie has expired.
+func (j *Jar) harEntry(h HARCookie, now time.Time) (*jarEntry, error) {
+	if !isValidName(h.Name) {
+		return nil, errInvalidHARCookie
+	}
+
+	e := &Entry{
+		Name:     h.Name,
+		Value:    h.Value,
+		Domain:   h.Domain,
+		Path:     h.Path,
+		Secure:   h.Secure,
+		HttpOnly: h.HTTPOnly,
+		HostOnly: !strings.HasPrefix(h.Domain, "."),
+		SameSite: parseSameSite(h.SameSite),
+	}
+
+	if h.Expires != "" {
+		expires, err := time.Parse(time.RFC3339Nano, h.Expires)
+		if err != nil {
+			return nil, errInvalidHARCookie
+		}
+		if !expires.After(now) {
+			return nil, nil
+		}
+		e.Expires = expires
+	}
+
+	return j.importEntry(e, now)
+}
@@ -0,0 +1,152 @@
+package cookie
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	errHistoryPruned = errors.New("history no longer covers the requested time")
+)
+
+// maxHistory is the number of operations kept in a jar's history.
+const maxHistory = 1 << 14
+
+// A historyRecord describes a single entry being stored or removed, along
+// with the jar's revision after the operation was applied.
+type historyRecord struct {
+	at      time.Time
+	rev     uint64
+	removed bool
+	entry   jarEntry
+}
+
+// EnableHistory makes the jar keep a log of all subsequent modifications,
+// whether made by SetCookie, by removing, importing or rewriting entries, or
+// by evictions, which makes it possible to query past states of the jar using
+// CookiesAt. Modifications made without an explicit time are logged at the
+// time given by the jar's clock (see SetClock). The log is kept in memory. Once it holds more than 16384
+// operations, the oldest half is folded into a snapshot of the entries they
+// left behind, and CookiesAt no longer answers queries about the time before
+// the last of those operations.
+func (j *Jar) EnableHistory() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.history == nil {
+		j.history = make([]historyRecord, 0, 64)
+	}
+}
+
+// logHistory appends a record to the history, pruning it if necessary.
+func (j *Jar) logHistory(rec historyRecord) {
+	if len(j.history) >= maxHistory {
+		j.pruneHistory(maxHistory / 2)
+	}
+	j.history = append(j.history, rec)
+}
+
+// pruneHistory folds the n oldest records into the history's base snapshot,
+// and removes them from the log.
+func (j *Jar) pruneHistory(n int) {
+	if j.historyBase == nil {
+		j.historyBase = make(map[string]*jarEntry)
+	}
+
+	for i := range j.history[:n] {
+		rec := &j.history[i]
+		key := rec.entry.Root + "\x00" + rec.entry.Key
+		if rec.removed {
+			delete(j.historyBase, key)
+		} else {
+			entry := rec.entry
+			j.historyBase[key] = &entry
+		}
+		if rec.at.After(j.historyFrom) {
+			j.historyFrom = rec.at
+		}
+	}
+
+	// Entries which had expired by then can't matter to later queries.
+	for key, entry := range j.historyBase {
+		if !entry.Expires.IsZero() && !entry.Expires.After(j.historyFrom) {
+			delete(j.historyBase, key)
+		}
+	}
+
+	j.history = append(j.history[:0], j.history[n:]...)
+}
+
+// CookiesAt returns the cookies the jar would have returned for the scheme,
+// host and path combination at time asOf, based on the operations logged
+// since history was enabled. Operations are replayed in the order they
+// were applied to the jar. An error is returned if asOf predates operations
+// which have been pruned from the history.
+func (j *Jar) CookiesAt(scheme, host, path string, asOf time.Time) ([]*Cookie, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if scheme != "http" && scheme != "https" {
+		return nil, errInvalidScheme
+	}
+
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if asOf.Before(j.historyFrom) {
+		return nil, errHistoryPruned
+	}
+
+	root := j.root(host)
+
+	// Replay the log up until asOf, by revision, starting from the entries
+	// left behind by pruned records.
+	state := make(map[string]*jarEntry)
+	for _, entry := range j.historyBase {
+		if entry.Root == root {
+			state[entry.Key] = entry
+		}
+	}
+
+	var recs []*historyRecord
+	for i := range j.history {
+		if rec := &j.history[i]; rec.entry.Root == root && !rec.at.After(asOf) {
+			recs = append(recs, rec)
+		}
+	}
+	sort.SliceStable(recs, func(a, b int) bool { return recs[a].rev < recs[b].rev })
+
+	for _, rec := range recs {
+		if rec.removed {
+			delete(state, rec.entry.Key)
+		} else {
+			state[rec.entry.Key] = &rec.entry
+		}
+	}
+
+	live := make([]*jarEntry, 0, len(state))
+	for _, entry := range state {
+		live = append(live, entry)
+	}
+	j.ordered(live)
+
+	var cookies []*Cookie
+
+	for _, entry := range live {
+		if !entry.Expires.IsZero() && !entry.Expires.After(asOf) {
+			continue
+		}
+
+		if entry.shouldSend(scheme, host, path) {
+			cookies = append(cookies, &Cookie{
+				Name:  entry.Name,
+				Value: entry.Value,
+			})
+		}
+	}
+
+	return cookies, nil
+}
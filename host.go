@@ -0,0 +1,171 @@
+package cookie
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var (
+	// ErrEmptyHost is returned by CanonicalHost when the host is empty.
+	ErrEmptyHost = errors.New("empty host")
+
+	// ErrInvalidHost is returned by CanonicalHost when a host with a port
+	// number can't be split into its host and port parts, when a bracketed
+	// host isn't an IPv6 address, or when a host contains characters which
+	// aren't allowed in hostnames.
+	ErrInvalidHost = errors.New("malformed host")
+
+	// ErrUserinfo is returned by CanonicalHost when the host contains
+	// userinfo, as in "user@example.com".
+	ErrUserinfo = errors.New("host contains userinfo")
+
+	// ErrEmptyLabel is returned by CanonicalHost when a hostname begins with
+	// a dot or contains two consecutive dots.
+	ErrEmptyLabel = errors.New("empty label in hostname")
+
+	// ErrInvalidPort is returned by CanonicalHost when a host's port isn't a
+	// decimal number between 0 and 65535.
+	ErrInvalidPort = errors.New("invalid port")
+
+	// ErrMalformedDomain is returned by NormalizeCookieDomain when a cookie
+	// domain is empty, has leading or trailing dots (beyond a single leading
+	// dot), or isn't a valid domain name.
+	ErrMalformedDomain = errors.New("malformed domain")
+)
+
+// CanonicalHost canonicalizes a host, as found in a URL or a "Host" header,
+// into the form used for cookie matching: it is lowercased, stripped of its
+// port number and any trailing dot, and internationalized domain names are
+// converted to their ASCII (punycode) form.
+func CanonicalHost(host string) (string, error) {
+	if host == "" {
+		return "", ErrEmptyHost
+	}
+
+	// Userinfo has no place in a hostname, and would otherwise be mangled
+	// into something unrecognizable by net.SplitHostPort.
+	if strings.IndexByte(host, '@') >= 0 {
+		return "", ErrUserinfo
+	}
+
+	if err := checkUTF8(host); err != nil {
+		return "", err
+	}
+
+	host = strings.ToLower(host)
+
+	if hasPort(host) {
+		var port string
+		var err error
+
+		host, port, err = net.SplitHostPort(host)
+		if err != nil {
+			return "", ErrInvalidHost
+		}
+		if !isValidPort(port) {
+			return "", ErrInvalidPort
+		}
+		if host == "" {
+			return "", ErrEmptyHost
+		}
+	} else if strings.HasPrefix(host, "[") {
+		// Bracketed IPv6 literals are allowed without a port too, but the
+		// brackets aren't part of the address itself.
+		if !strings.HasSuffix(host, "]") || !isIP(host[1:len(host)-1]) {
+			return "", ErrInvalidHost
+		}
+		host = host[1 : len(host)-1]
+	}
+
+	if isIP(host) {
+		return host, nil
+	}
+
+	// Fully qualified domain names are equivalent to their relative form.
+	if strings.HasSuffix(host, ".") {
+		host = host[:len(host)-1]
+	}
+
+	if hasEmptyLabel(host) {
+		return "", ErrEmptyLabel
+	}
+
+	host, err := toASCII(host)
+	if err != nil {
+		return "", err
+	}
+	if !isHostName(host) {
+		return "", ErrInvalidHost
+	}
+
+	return host, nil
+}
+
+// isHostName returns true if host consists only of characters which may
+// appear in a hostname: letters, digits, hyphens, underscores and dots.
+func isHostName(host string) bool {
+	for i := 0; i < len(host); i++ {
+		switch c := host[i]; {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isValidPort returns true if port is a decimal number between 0 and 65535.
+func isValidPort(port string) bool {
+	if len(port) == 0 || len(port) > 5 {
+		return false
+	}
+
+	var n int
+	for i := 0; i < len(port); i++ {
+		if port[i] < '0' || port[i] > '9' {
+			return false
+		}
+		n = n*10 + int(port[i]-'0')
+	}
+
+	return n <= 0xffff
+}
+
+// hasEmptyLabel returns true if host is empty, begins or ends with a dot, or
+// contains two consecutive dots.
+func hasEmptyLabel(host string) bool {
+	return host == "" || host[0] == '.' || host[len(host)-1] == '.' ||
+		strings.Contains(host, "..")
+}
+
+// NormalizeCookieDomain normalizes the value of a cookie's Domain attribute:
+// a single leading dot is removed, the domain is lowercased and converted to
+// its ASCII (punycode) form, and the result is validated.
+func NormalizeCookieDomain(domain string) (string, error) {
+	// We allow (and ignore) a single leading dot. After that, though, domains
+	// which are either empty or have leading or trailing dots are considered
+	// malformed.
+	if domain != "" && domain[0] == '.' {
+		domain = domain[1:]
+	}
+	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", ErrMalformedDomain
+	}
+
+	if err := checkUTF8(domain); err != nil {
+		return "", err
+	}
+
+	domain, err := toASCII(strings.ToLower(domain))
+	if err != nil {
+		return "", err
+	}
+
+	if !isValidDomain(domain) {
+		return "", ErrMalformedDomain
+	}
+
+	return domain, nil
+}
@@ -0,0 +1,116 @@
+package cookie
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var canonicalHostTests = []struct {
+	in  string
+	out string
+	err error
+}{
+	{"Example.COM", "example.com", nil},
+	{"example.com.", "example.com", nil},
+	{"example.com:8080", "example.com", nil},
+	{"Example.com.:8080", "example.com", nil},
+	{"[::1]:80", "::1", nil},
+	{"[::1]", "::1", nil},
+	{"::1", "::1", nil},
+	{"127.0.0.1", "127.0.0.1", nil},
+	{"bücher.example", "xn--bcher-kva.example", nil},
+	{"", "", ErrEmptyHost},
+	{"[::1]]:80", "", ErrInvalidHost},
+	{"[::1", "", ErrInvalidHost},
+	{"[example.com]", "", ErrInvalidHost},
+	{":80", "", ErrEmptyHost},
+	{"ex ample.com", "", ErrInvalidHost},
+	{"a/b", "", ErrInvalidHost},
+	{"user:pass@example.com", "", ErrUserinfo},
+	{"user@example.com:80", "", ErrUserinfo},
+	{"example.com:http", "", ErrInvalidPort},
+	{"example.com:65536", "", ErrInvalidPort},
+	{"example.com:", "", ErrInvalidPort},
+	{"www..example.com", "", ErrEmptyLabel},
+	{".example.com", "", ErrEmptyLabel},
+	{"example.com..", "", ErrEmptyLabel},
+	{".", "", ErrEmptyLabel},
+}
+
+func TestCanonicalHost(t *testing.T) {
+	for _, test := range canonicalHostTests {
+		out, err := CanonicalHost(test.in)
+		if out != test.out || err != test.err {
+			t.Errorf("CanonicalHost(%q):", test.in)
+			t.Errorf("  got  %q, %+v", out, err)
+			t.Errorf("  want %q, %+v", test.out, test.err)
+		}
+	}
+}
+
+var normalizeCookieDomainTests = []struct {
+	in  string
+	out string
+	err error
+}{
+	{"example.com", "example.com", nil},
+	{".Example.COM", "example.com", nil},
+	{".bücher.example", "xn--bcher-kva.example", nil},
+	{"127.0.0.1", "127.0.0.1", nil},
+	{"", "", ErrMalformedDomain},
+	{".", "", ErrMalformedDomain},
+	{"..example.com", "", ErrMalformedDomain},
+	{"example.com.", "", ErrMalformedDomain},
+	{"exa mple.com", "", ErrMalformedDomain},
+	{"-example.com", "", ErrMalformedDomain},
+}
+
+func TestNormalizeCookieDomain(t *testing.T) {
+	for _, test := range normalizeCookieDomainTests {
+		out, err := NormalizeCookieDomain(test.in)
+		if out != test.out || err != test.err {
+			t.Errorf("NormalizeCookieDomain(%q):", test.in)
+			t.Errorf("  got  %q, %+v", out, err)
+			t.Errorf("  want %q, %+v", test.out, test.err)
+		}
+	}
+}
+
+func TestIDNAError(t *testing.T) {
+	long := strings.Repeat("a", 2000) + "\U0010FFFD"
+
+	tests := []struct {
+		in  string
+		err *IDNAError
+	}{
+		{"www.b\xffcher.example", &IDNAError{Label: "b\xffcher", Offset: 5, Reason: "invalid UTF-8"}},
+		{"bücher.\xc3", &IDNAError{Label: "\xc3", Offset: 8, Reason: "invalid UTF-8"}},
+		{"x." + long, &IDNAError{Label: long, Offset: 2, Reason: "label too long", Err: ErrLabelTooLong}},
+	}
+
+	for _, test := range tests {
+		_, err := CanonicalHost(test.in)
+		if !reflect.DeepEqual(err, test.err) {
+			t.Errorf("CanonicalHost(%q):", test.in)
+			t.Errorf("  got  %+v", err)
+			t.Errorf("  want %+v", test.err)
+		}
+		if test.err.Err != nil && !errors.Is(err, test.err.Err) {
+			t.Errorf("CanonicalHost(%q) = %v, want errors.Is %v", test.in, err, test.err.Err)
+		}
+
+		// The error should reach users of the jar.
+		j := NewJar(nil)
+		err = j.SetCookie("http", test.in, "/", &Cookie{Name: "a", Value: "b"}, time.Now())
+		if _, ok := err.(*IDNAError); !ok {
+			t.Errorf("SetCookie(%q) returned %v, want an IDNAError", test.in, err)
+		}
+		err = j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "b", Domain: test.in}, time.Now())
+		if _, ok := err.(*IDNAError); !ok {
+			t.Errorf("SetCookie(Domain=%q) returned %v, want an IDNAError", test.in, err)
+		}
+	}
+}
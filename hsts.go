@@ -0,0 +1,99 @@
+package cookie
+
+import (
+	"sort"
+	"strings"
+)
+
+// AddHSTSHost registers a host as only ever being reached over HTTPS, as
+// announced by a Strict-Transport-Security header or an HSTS preload list.
+// Cookies, CookieHeader and CookiesInContext treat requests to such hosts
+// as HTTPS requests even when passed the "http" scheme, for example by
+// callers which normalize URLs before the client upgrades them, so that
+// Secure cookies are still sent. A "*." prefix makes the entry apply to the
+// domain itself as well as all of its subdomains, like the includeSubDomains
+// directive.
+func (j *Jar) AddHSTSHost(host string) error {
+	pattern, err := hstsPattern(host)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.hsts == nil {
+		j.hsts = make(map[string]bool)
+	}
+	j.hsts[pattern] = true
+	return nil
+}
+
+// RemoveHSTSHost removes a host added with AddHSTSHost. The host must be
+// given with the same "*." prefix, if any.
+func (j *Jar) RemoveHSTSHost(host string) error {
+	pattern, err := hstsPattern(host)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.hsts, pattern)
+	return nil
+}
+
+// HSTSHosts returns the hosts added with AddHSTSHost, in canonical form and
+// sorted.
+func (j *Jar) HSTSHosts() []string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	hosts := make([]string, 0, len(j.hsts))
+	for pattern := range j.hsts {
+		hosts = append(hosts, pattern)
+	}
+
+	sort.Strings(hosts)
+	return hosts
+}
+
+// hstsPattern canonicalizes a host passed to AddHSTSHost, keeping its "*."
+// prefix, if any.
+func hstsPattern(host string) (string, error) {
+	var prefix string
+	if strings.HasPrefix(host, "*.") {
+		prefix, host = "*.", host[2:]
+	}
+
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return "", err
+	}
+	return prefix + host, nil
+}
+
+// isHSTS returns true if the canonical host was registered with AddHSTSHost,
+// either directly or through a "*." entry for it or one of its parents.
+func (j *Jar) isHSTS(host string) bool {
+	if len(j.hsts) == 0 {
+		return false
+	}
+	if j.hsts[host] {
+		return true
+	}
+
+	for domain := host; domain != ""; {
+		if j.hsts["*."+domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	return false
+}
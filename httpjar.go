@@ -0,0 +1,74 @@
+package cookie
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// HTTPJar adapts a Jar to the http.CookieJar interface, so that it can be
+// used as the Jar of an http.Client.
+type HTTPJar struct {
+	j *Jar
+}
+
+// HTTPAdapter returns an http.CookieJar backed by the jar.
+func (j *Jar) HTTPAdapter() *HTTPJar {
+	return &HTTPJar{j: j}
+}
+
+// SetCookies implements the SetCookies method of http.CookieJar. Cookies the
+// jar refuses are silently ignored, as the interface has no way of reporting
+// errors.
+func (h *HTTPJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	now := h.j.now()
+	for _, hc := range cookies {
+		h.j.SetCookie(u.Scheme, u.Host, u.Path, fromHTTPCookie(hc), now)
+	}
+}
+
+// Cookies implements the Cookies method of http.CookieJar. Only the Name and
+// Value fields of the returned cookies are set.
+func (h *HTTPJar) Cookies(u *url.URL) []*http.Cookie {
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	cookies, err := h.j.Cookies(u.Scheme, u.Host, path, h.j.now())
+	if err != nil {
+		return nil
+	}
+
+	out := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		out[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return out
+}
+
+// fromHTTPCookie converts an http.Cookie to a Cookie. The two agree on the
+// meaning of MaxAge.
+func fromHTTPCookie(hc *http.Cookie) *Cookie {
+	c := &Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Domain:   hc.Domain,
+		Path:     hc.Path,
+		Expires:  hc.Expires,
+		MaxAge:   hc.MaxAge,
+		Secure:   hc.Secure,
+		HttpOnly: hc.HttpOnly,
+		Unparsed: hc.Unparsed,
+	}
+
+	switch hc.SameSite {
+	case http.SameSiteLaxMode:
+		c.SameSite = SameSiteLax
+	case http.SameSiteStrictMode:
+		c.SameSite = SameSiteStrict
+	case http.SameSiteNoneMode:
+		c.SameSite = SameSiteNone
+	}
+
+	return c
+}
@@ -0,0 +1,53 @@
+package cookie
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Make sure HTTPJar implements http.CookieJar.
+var _ http.CookieJar = (*HTTPJar)(nil)
+
+func TestHTTPAdapter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Value: "1", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "bad", Value: "1", Domain: "other.org"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Path: "/", MaxAge: -1})
+		default:
+			for _, c := range r.Cookies() {
+				w.Write([]byte(c.Name + "=" + c.Value + ";"))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	j := NewJar(testPSL{})
+	client := &http.Client{Jar: j.HTTPAdapter()}
+
+	get := func(path string) string {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	get("/login")
+	if body := get("/"); body != "sid=1;tmp=1;" && body != "tmp=1;sid=1;" {
+		t.Errorf("cookies sent after login: %q", body)
+	}
+
+	get("/logout")
+	if body := get("/"); body != "sid=1;" {
+		t.Errorf("cookies sent after logout: %q", body)
+	}
+}
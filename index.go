@@ -0,0 +1,301 @@
+package cookie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	errInvalidIndex  = errors.New("invalid jar index")
+	errIndexTooLarge = errors.New("jar too large to index")
+)
+
+// Layout of the jar index format. All integers are little-endian.
+//
+//	header   magic [4]byte, root count uint32, entry count uint32
+//	roots    string ref, first entry uint32, entry count uint32
+//	entries  name, value, domain, path and partition string refs,
+//	         expires int64, flags uint32
+//	strings  string data, referenced by (offset uint32, length uint32)
+//	         pairs relative to the start of the index
+//
+// Roots are sorted, and entries are grouped by root.
+const (
+	indexMagic      = "CKX2"
+	indexHeaderSize = 12
+	indexRootSize   = 16
+	indexEntrySize  = 52
+)
+
+// maxIndexSize is the size limit imposed by the index's 32-bit offsets. It's
+// a variable so that tests can lower it.
+var maxIndexSize uint64 = math.MaxUint32
+
+// Entry flags.
+const (
+	indexSecure = 1 << iota
+	indexHttpOnly
+	indexHostOnly
+	indexSession
+)
+
+// WriteIndex writes the jar's entries to w in a compact binary format, which
+// can later be queried using OpenIndex without being loaded into memory.
+// Since the format uses 32-bit offsets, an error is returned without writing
+// anything if the index would exceed 4 GiB.
+func (j *Jar) WriteIndex(w io.Writer) (err error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	profile("Jar.WriteIndex", func() { err = j.writeIndex(w) })
+	return
+}
+
+// writeIndex implements WriteIndex.
+func (j *Jar) writeIndex(w io.Writer) error {
+	roots := make([]string, 0, len(j.ent))
+	for root := range j.ent {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	entries := j.sorted()
+	n := len(entries)
+
+	// Make sure every offset fits in 32 bits before writing anything.
+	size := uint64(indexHeaderSize + len(roots)*indexRootSize + n*indexEntrySize)
+	for _, root := range roots {
+		size += uint64(len(root))
+	}
+	for _, entry := range entries {
+		size += uint64(len(entry.Name) + len(entry.Value) + len(entry.Domain) + len(entry.Path) + len(entry.Partition))
+	}
+	if size > maxIndexSize {
+		return errIndexTooLarge
+	}
+
+	// Strings are stored after the fixed-size sections.
+	heap := uint32(indexHeaderSize + len(roots)*indexRootSize + n*indexEntrySize)
+	var strs []string
+
+	ref := func(b []byte, s string) []byte {
+		b = binary.LittleEndian.AppendUint32(b, heap)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		heap += uint32(len(s))
+		strs = append(strs, s)
+		return b
+	}
+
+	bw := bufio.NewWriter(w)
+
+	b := make([]byte, 0, indexHeaderSize)
+	b = append(b, indexMagic...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(roots)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(n))
+	bw.Write(b)
+
+	var first int
+	for _, root := range roots {
+		b = ref(b[:0], root)
+		b = binary.LittleEndian.AppendUint32(b, uint32(first))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(j.ent[root])))
+		bw.Write(b)
+		first += len(j.ent[root])
+	}
+
+	for _, entry := range entries {
+		b = ref(b[:0], entry.Name)
+		b = ref(b, entry.Value)
+		b = ref(b, entry.Domain)
+		b = ref(b, entry.Path)
+		b = ref(b, entry.Partition)
+
+		var flags uint32
+		if entry.Secure {
+			flags |= indexSecure
+		}
+		if entry.HttpOnly {
+			flags |= indexHttpOnly
+		}
+		if entry.HostOnly {
+			flags |= indexHostOnly
+		}
+		if entry.Expires.IsZero() {
+			flags |= indexSession
+		}
+
+		b = binary.LittleEndian.AppendUint64(b, uint64(entry.Expires.Unix()))
+		b = binary.LittleEndian.AppendUint32(b, flags)
+		bw.Write(b)
+	}
+
+	for _, s := range strs {
+		bw.WriteString(s)
+	}
+
+	return bw.Flush()
+}
+
+// A JarIndex is a read-only jar backed by data produced by Jar.WriteIndex.
+// Entries are decoded on demand, so the data may be a memory-mapped file
+// holding more cookies than would comfortably fit in the Go heap.
+type JarIndex struct {
+	data  []byte
+	psl   PublicSuffixList
+	roots int
+	close func() error
+}
+
+// OpenIndex returns a JarIndex reading from data, which must not be modified
+// while the index is in use. The public suffix list should match the one
+// used by the jar the index was written from.
+func OpenIndex(data []byte, psl PublicSuffixList) (*JarIndex, error) {
+	if len(data) < indexHeaderSize || string(data[:4]) != indexMagic {
+		return nil, errInvalidIndex
+	}
+
+	roots := int(binary.LittleEndian.Uint32(data[4:]))
+	n := int(binary.LittleEndian.Uint32(data[8:]))
+
+	if uint64(len(data)) < indexHeaderSize+uint64(roots)*indexRootSize+uint64(n)*indexEntrySize {
+		return nil, errInvalidIndex
+	}
+
+	x := &JarIndex{data: data, psl: psl, roots: roots}
+
+	// Validate all references up front, so queries don't have to.
+	var total int
+	for i := 0; i < roots; i++ {
+		r := x.root(i)
+		first := int(binary.LittleEndian.Uint32(r[8:]))
+		count := int(binary.LittleEndian.Uint32(r[12:]))
+		if !x.validRef(r) || first != total || count > n-total {
+			return nil, errInvalidIndex
+		}
+		total += count
+	}
+	if total != n {
+		return nil, errInvalidIndex
+	}
+
+	for i := 0; i < n; i++ {
+		e := x.entry(i)
+		for k := 0; k < 5; k++ {
+			if !x.validRef(e[8*k:]) {
+				return nil, errInvalidIndex
+			}
+		}
+	}
+
+	return x, nil
+}
+
+// Close releases resources associated with the index, such as a memory
+// mapping created by MapIndex.
+func (x *JarIndex) Close() error {
+	if x.close == nil {
+		return nil
+	}
+	err := x.close()
+	x.close, x.data = nil, nil
+	return err
+}
+
+// Cookies returns a slice of cookies relevant for the scheme, host and path
+// combination, like Jar.Cookies would have when the index was written.
+// Partitioned cookies are only returned for their own site, as if no
+// top-level site had been specified. The jar's HSTS hosts aren't part of the
+// index, though, so requests to them must be made with the "https" scheme.
+func (x *JarIndex) Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error) {
+	if scheme != "http" && scheme != "https" {
+		return nil, errInvalidScheme
+	}
+
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	root := domainRoot(host, x.psl)
+
+	// Find the root using binary search.
+	i := sort.Search(x.roots, func(i int) bool {
+		return string(x.str(x.root(i))) >= root
+	})
+	if i == x.roots || string(x.str(x.root(i))) != root {
+		return nil, nil
+	}
+
+	// Requests are made in the context of the host's own site, like in
+	// Jar.partitionKey.
+	partition := root
+	if partition == "" {
+		partition = host
+	}
+	partition = scheme + "://" + partition
+
+	r := x.root(i)
+	first := int(binary.LittleEndian.Uint32(r[8:]))
+	count := int(binary.LittleEndian.Uint32(r[12:]))
+
+	var cookies []*Cookie
+	unix := now.Unix()
+
+	for k := first; k < first+count; k++ {
+		e := x.entry(k)
+		flags := binary.LittleEndian.Uint32(e[48:])
+
+		if flags&indexSession == 0 && int64(binary.LittleEndian.Uint64(e[40:])) <= unix {
+			continue
+		}
+		if p := x.str(e[32:]); len(p) > 0 && string(p) != partition {
+			continue
+		}
+		if flags&indexSecure != 0 && scheme != "https" {
+			continue
+		}
+		if !domainMatch(host, string(x.str(e[16:])), flags&indexHostOnly != 0) ||
+			!PathMatch(path, string(x.str(e[24:]))) {
+			continue
+		}
+
+		cookies = append(cookies, &Cookie{
+			Name:  string(x.str(e)),
+			Value: string(x.str(e[8:])),
+		})
+	}
+
+	return cookies, nil
+}
+
+// root returns the i:th root record.
+func (x *JarIndex) root(i int) []byte {
+	off := indexHeaderSize + i*indexRootSize
+	return x.data[off : off+indexRootSize]
+}
+
+// entry returns the i:th entry record.
+func (x *JarIndex) entry(i int) []byte {
+	off := indexHeaderSize + x.roots*indexRootSize + i*indexEntrySize
+	return x.data[off : off+indexEntrySize]
+}
+
+// str returns the string referenced by the first 8 bytes of b.
+func (x *JarIndex) str(b []byte) []byte {
+	off := binary.LittleEndian.Uint32(b)
+	n := binary.LittleEndian.Uint32(b[4:])
+	return x.data[off : off+n]
+}
+
+// validRef returns true if the string reference at the start of b lies within
+// the index.
+func (x *JarIndex) validRef(b []byte) bool {
+	off := uint64(binary.LittleEndian.Uint32(b))
+	n := uint64(binary.LittleEndian.Uint32(b[4:]))
+	return off+n <= uint64(len(x.data))
+}
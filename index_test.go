@@ -0,0 +1,80 @@
+package cookie
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testIndexJar() *Jar {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "b", Value: "2", Domain: "example.com"}, testNow)
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "c", Value: "3", Secure: true, Path: "/x"}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "d", Value: "4", MaxAge: 60}, testNow)
+	j.SetCookie("http", "other.org", "/", &Cookie{Name: "e", Value: "5"}, testNow)
+
+	// Partitioned cookies, one of which belongs to another site.
+	embed := RequestContext{CrossSite: true, TopLevelSite: "https://news.com"}
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "f", Value: "6", Secure: true, Partitioned: true}, testNow)
+	j.SetCookieInContext("https", "www.example.com", "/", embed, &Cookie{Name: "g", Value: "7", Secure: true, Partitioned: true}, testNow)
+	return j
+}
+
+var indexQueries = []struct {
+	scheme, host, path string
+	after              time.Duration
+}{
+	{"http", "www.example.com", "/", 0},
+	{"https", "www.example.com", "/", 0},
+	{"https", "www.example.com", "/x/y", 0},
+	{"http", "www.example.com", "/x/y", 0},
+	{"http", "example.com", "/", 0},
+	{"http", "www.example.com", "/", time.Hour},
+	{"http", "other.org", "/", 0},
+	{"http", "nothing.net", "/", 0},
+}
+
+func TestIndex(t *testing.T) {
+	j := testIndexJar()
+
+	var buf bytes.Buffer
+	if err := j.WriteIndex(&buf); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	x, err := OpenIndex(buf.Bytes(), testPSL{})
+	if err != nil {
+		t.Fatalf("OpenIndex: %v", err)
+	}
+
+	for _, q := range indexQueries {
+		want, _ := j.ReadOnly().Cookies(q.scheme, q.host, q.path, testNow.Add(q.after))
+		got, err := x.Cookies(q.scheme, q.host, q.path, testNow.Add(q.after))
+
+		sortCookies(want)
+		sortCookies(got)
+
+		if !reflect.DeepEqual(got, want) || err != nil {
+			t.Errorf("JarIndex.Cookies(%q, %q, %q):", q.scheme, q.host, q.path)
+			t.Errorf("  got  %+v, %+v", got, err)
+			t.Errorf("  want %+v, %+v", want, nil)
+		}
+	}
+
+	// Jars which don't fit the format's 32-bit offsets must be rejected.
+	defer func(max uint64) { maxIndexSize = max }(maxIndexSize)
+	maxIndexSize = uint64(buf.Len() - 1)
+	var tooLarge bytes.Buffer
+	if err := j.WriteIndex(&tooLarge); err != errIndexTooLarge || tooLarge.Len() != 0 {
+		t.Errorf("WriteIndex past size limit returned %v after writing %d bytes", err, tooLarge.Len())
+	}
+
+	// Truncated data must be rejected.
+	for _, n := range []int{0, 4, indexHeaderSize, buf.Len() - 1} {
+		if _, err := OpenIndex(buf.Bytes()[:n], testPSL{}); err == nil {
+			t.Errorf("OpenIndex accepted index truncated to %d bytes", n)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package cookie
+
+import (
+	"os"
+	"syscall"
+)
+
+// MapIndex memory-maps a file written by Jar.WriteIndex and opens it as a
+// JarIndex. The mapping is released when the index is closed.
+func MapIndex(f *os.File, psl PublicSuffixList) (*JarIndex, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(fi.Size())
+	if int64(size) != fi.Size() || size == 0 {
+		return nil, errInvalidIndex
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := OpenIndex(data, psl)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	x.close = func() error {
+		return syscall.Munmap(data)
+	}
+
+	return x, nil
+}
@@ -0,0 +1,36 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package cookie
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMapIndex(t *testing.T) {
+	f, err := ioutil.TempFile("", "cookie-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := testIndexJar().WriteIndex(f); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	x, err := MapIndex(f, testPSL{})
+	if err != nil {
+		t.Fatalf("MapIndex: %v", err)
+	}
+
+	if cookies, _ := x.Cookies("http", "other.org", "/", testNow); len(cookies) != 1 {
+		t.Errorf("Cookies returned %+v, want one cookie", cookies)
+	}
+
+	if err := x.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
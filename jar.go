@@ -1,21 +1,37 @@
 package cookie
 
 import (
+	"bytes"
+	"container/heap"
 	"errors"
+	"math"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	errInvalidScheme   = errors.New("invalid scheme")
-	errNoHostname      = errors.New("no hostname")
-	errMalformedDomain = errors.New("malformed domain")
-	errIllegalDomain   = errors.New("illegal domain")
+	errInvalidScheme = errors.New("invalid scheme")
+	errNoHostname    = errors.New("no hostname")
+	errIllegalDomain = errors.New("illegal domain")
 )
 
+// ErrIPHost is returned by SetCookie when a jar set up with RejectIPCookies is
+// given a cookie from a host identified by its IP address.
+var ErrIPHost = errors.New("cookie set by IP address host")
+
+// ErrIPDomain is returned when a cookie's Domain attribute is an IP address,
+// either by SetCookie when it doesn't match the host setting the cookie or
+// the jar was set up with RejectIPDomains, or by ParseWithOptions when
+// ParseOptions.RejectIPDomain is set.
+var ErrIPDomain = errors.New("Domain attribute is an IP address")
+
 // PublicSuffixList returns the public suffixes of domains. It is a subset of
 // the PublicSuffixList interface defined in package net/http/cookiejar.
+// Package psl provides an implementation backed by an embedded copy of the
+// Mozilla Public Suffix List.
 type PublicSuffixList interface {
 	PublicSuffix(domain string) string
 }
@@ -23,117 +39,475 @@ type PublicSuffixList interface {
 // NewJar creates a new cookie jar.
 func NewJar(psl PublicSuffixList) *Jar {
 	return &Jar{
-		psl: psl,
-		ent: make(map[string]map[string]*jarEntry),
+		psl:      psl,
+		ent:      make(map[string]map[string]*jarEntry),
+		dead:     make(map[string]*jarEntry),
+		roots:    make(map[string]string),
+		sameSite: SameSiteNone,
 	}
 }
 
-// Jar is a cookie jar.
+// Jar is a cookie jar. It is safe for concurrent use by multiple goroutines.
 type Jar struct {
+	// Guards everything below, except for the domain root cache.
+	mu sync.RWMutex
+
 	psl PublicSuffixList
 	ent map[string]map[string]*jarEntry
+
+	// Revision counter, and the most recently removed entries, used to
+	// answer ChangesSince queries. Removals up to revision pruned have been
+	// forgotten.
+	rev    uint64
+	dead   map[string]*jarEntry
+	pruned uint64
+
+	// Cache of domain roots, keyed by host, which is updated by readers
+	// too and so has a lock of its own.
+	rootsMu sync.Mutex
+	roots   map[string]string
+
+	// Entries grouped by root and path, if path indexing is enabled.
+	paths pathIndex
+
+	// Retention rules, in the order they were added.
+	rules []RetentionRule
+
+	// Log of SetCookie operations, if history is enabled, along with the
+	// entries left behind by records pruned from it, and the time of the
+	// last of those.
+	history     []historyRecord
+	historyBase map[string]*jarEntry
+	historyFrom time.Time
+
+	// Registered observers, and the changes being collected for them while
+	// a transaction is committed.
+	observers []func([]Change)
+	batch     []Change
+	batching  bool
+
+	// Maximum number of entries per domain root and in total, if limited,
+	// and all entries in eviction order if the total is limited.
+	limits    Limits
+	evictions *evictionHeap
+
+	// Whether cookies set by IP address hosts, or with IP address domains,
+	// are rejected.
+	rejectIP       bool
+	rejectIPDomain bool
+
+	// Whether unparsed attributes are stored along with entries.
+	keepUnparsed bool
+
+	// Hosts known to be reached over HTTPS only, added with AddHSTSHost.
+	hsts map[string]bool
+
+	// Seed determining the order in which entries are visited, if set.
+	seed   uint64
+	seeded bool
+
+	// Source of the current time for methods not taking one, if set.
+	clock func() time.Time
+
+	// Restriction applied to cookies without a SameSite attribute.
+	sameSite SameSite
+
+	// How host-only and domain cookies with the same name are treated.
+	conflict ConflictPolicy
+
+	// How cookies violating the requirements of their name prefix are
+	// treated.
+	prefixes PrefixPolicy
+
+	// Running entry counts, the latest time they have been advanced to, and
+	// the persistent entries ordered by expiry time, used to answer Stats
+	// queries.
+	stats    Stats
+	statsAt  time.Time
+	expiries expiryHeap
+
+	// The cookies most recently rejected by SetCookie, oldest first, and
+	// counts of entries removed by the jar itself, reported by DebugHandler.
+	rejections []rejection
+	gc         gcStats
+
+	// Ongoing calls to Range, the current generation, which is advanced
+	// whenever one begins, and the generation at which each bucket was last
+	// copied for them.
+	iters     map[*iteration]bool
+	iterGen   uint64
+	preserved map[string]uint64
+}
+
+// RejectIPCookies makes the jar refuse cookies set by hosts identified by
+// their IP address, such as "127.0.0.1" or "[::1]", rather than by name.
+// SetCookie returns ErrIPHost for such cookies.
+func (j *Jar) RejectIPCookies() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.rejectIP = true
+}
+
+// RejectIPDomains makes the jar refuse cookies whose Domain attribute is an
+// IP address, such as "127.0.0.1" or "[::1]". Such a domain only matches an
+// identical host, so by default it's accepted from that host and the cookie
+// stored as host-only. SetCookie returns ErrIPDomain for such cookies.
+func (j *Jar) RejectIPDomains() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.rejectIPDomain = true
+}
+
+// KeepUnparsed makes the jar store the unparsed attributes of cookies, such
+// as "Priority" or vendor extensions, in the Unparsed field of their entries,
+// so that proxies passing cookies through a jar don't strip them. By default
+// such attributes are discarded.
+func (j *Jar) KeepUnparsed() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.keepUnparsed = true
 }
 
 // Cookies returns a slice of cookies relevant for the scheme, host and path
-// combination.
+// combination. Only the Name and Value fields of the cookies are set; use
+// EntriesFor to inspect the rest of what the jar stores.
 func (j *Jar) Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.cookies(scheme, host, path, now, true)
+}
+
+// cookies implements Cookies. Expired entries are deleted if sweep is true,
+// and otherwise merely skipped.
+func (j *Jar) cookies(scheme, host, path string, now time.Time, sweep bool) ([]*Cookie, error) {
+	entries, err := j.entries(scheme, host, path, "", now, sweep)
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies []*Cookie
+	for _, entry := range entries {
+		// Cookies read through a View don't count as sent.
+		if sweep {
+			j.sent(entry, now)
+		}
+		cookies = append(cookies, &Cookie{
+			Name:  entry.Name,
+			Value: entry.Value,
+		})
+	}
+
+	return cookies, nil
+}
+
+// entries returns the entries relevant for the scheme, host and path
+// combination. Expired entries are deleted if sweep is true, and otherwise
+// merely skipped.
+func (j *Jar) entries(scheme, host, path, topLevel string, now time.Time, sweep bool) ([]*jarEntry, error) {
 	if scheme != "http" && scheme != "https" {
 		return nil, errInvalidScheme
 	}
 
-	host, err := canonicalHost(host)
+	host, err := CanonicalHost(host)
 	if err != nil {
 		return nil, err
 	}
 
-	root := domainRoot(host, j.psl)
+	if scheme == "http" && j.isHSTS(host) {
+		scheme = "https"
+	}
+
+	root := j.root(host)
 	bucket := j.ent[root]
+	partition := j.partitionKey(scheme, host, topLevel)
 
 	// Once we've established this domain's bucket, delete expired cookies and
 	// output the rest of them.
-	var cookies []*Cookie
+	var entries []*jarEntry
 
-	for _, entry := range bucket {
+	visit := func(entry *jarEntry) {
 		if !entry.Expires.IsZero() && !entry.Expires.After(now) {
-			delete(bucket, entry.Domain+";"+entry.Path+";"+entry.Name)
+			if sweep {
+				j.remove(entry, now)
+				j.gc.Swept++
+			}
+			return
+		}
+
+		if entry.Partition != "" && entry.Partition != partition {
+			return
 		}
 
 		if entry.shouldSend(scheme, host, path) {
-			cookies = append(cookies, &Cookie{
-				Name:  entry.Name,
-				Value: entry.Value,
-			})
+			entries = append(entries, entry)
 		}
 	}
 
-	// Remove the bucket if it's now empty.
-	if len(bucket) == 0 {
-		delete(j.ent, root)
+	// In deterministic jars, collect the candidates first so they can be
+	// visited in the seeded order.
+	each := visit
+	var candidates []*jarEntry
+	if j.seeded {
+		each = func(entry *jarEntry) { candidates = append(candidates, entry) }
 	}
 
-	return cookies, nil
+	// Use the path index to skip irrelevant entries, if there is one.
+	if j.paths != nil {
+		j.paths[root].each(path, each)
+	} else {
+		for _, entry := range bucket {
+			each(entry)
+		}
+	}
+
+	if j.seeded {
+		j.ordered(candidates)
+		for _, entry := range candidates {
+			visit(entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// CookieHeader returns the value of the "Cookie" header to send with a
+// request for the scheme, host and path combination. Cookies are ordered as
+// recommended by RFC 6265, section 5.4: those with longer paths first, and
+// those with equal paths by creation time. An empty string is returned if
+// there are no relevant cookies.
+func (j *Jar) CookieHeader(scheme, host, path string, now time.Time) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.entries(scheme, host, path, "", now, true)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Sort(headerOrder(entries))
+
+	b := new(bytes.Buffer)
+	for i, entry := range entries {
+		j.sent(entry, now)
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(entry.Name)
+		b.WriteByte('=')
+		if shouldQuoteValue(entry.Value) {
+			b.WriteByte('"')
+			b.WriteString(entry.Value)
+			b.WriteByte('"')
+		} else {
+			b.WriteString(entry.Value)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// headerOrder implements sort.Interface, ordering entries as they should
+// appear in a "Cookie" header.
+type headerOrder []*jarEntry
+
+func (l headerOrder) Len() int      { return len(l) }
+func (l headerOrder) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l headerOrder) Less(i, j int) bool {
+	if len(l[i].Path) != len(l[j].Path) {
+		return len(l[i].Path) > len(l[j].Path)
+	}
+	return older(l[i], l[j])
 }
 
 // SetCookie updates the jar with a cookie from a "Set-Cookie" header.
 func (j *Jar) SetCookie(scheme, host, path string, c *Cookie, now time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, remove, err := j.prepare(scheme, host, "", c, now)
+	if err != nil {
+		j.reject(host, c, err, now)
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	j.store(entry, remove, now)
+	return nil
+}
+
+// prepare validates a cookie from a "Set-Cookie" header and creates the
+// corresponding entry, without modifying the jar. The second return value is
+// true if the entry should be removed rather than stored. A nil entry and
+// error means the cookie should be silently ignored. Partitioned cookies are
+// partitioned by the topLevel site, or that of the host if it's empty.
+func (j *Jar) prepare(scheme, host, topLevel string, c *Cookie, now time.Time) (*jarEntry, bool, error) {
 	if scheme != "http" && scheme != "https" {
-		return errInvalidScheme
+		return nil, false, errInvalidScheme
+	}
+
+	if c.Partitioned && !c.Secure {
+		return nil, false, errInsecurePartitioned
 	}
 
-	host, err := canonicalHost(host)
+	if checkPrefix(c, scheme == "https") != "" {
+		if j.prefixes == PrefixDiscard {
+			return nil, false, nil
+		}
+		return nil, false, ErrCookiePrefix
+	}
+
+	host, err := CanonicalHost(host)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	entry, remove, err := newEntry(c, host, j.psl, now)
+	if j.rejectIP && isIP(host) {
+		return nil, false, ErrIPHost
+	}
+
+	if j.rejectIPDomain && ipDomain(c.Domain) != nil {
+		return nil, false, ErrIPDomain
+	}
+
+	entry, remove, err := newEntry(c, host, j.root(host), j.psl, now)
 	if err != nil {
-		return err
+		return nil, false, err
+	}
+	if c.Partitioned {
+		entry.Partition = j.partitionKey(scheme, host, topLevel)
 	}
+	entry.Key = j.entryKey(entry)
+
+	if j.keepUnparsed && len(c.Unparsed) > 0 {
+		entry.Unparsed = append([]string(nil), c.Unparsed...)
+	}
+
+	if !remove {
+		j.applyRetention(host, entry, now)
+	}
+
+	return entry, remove, nil
+}
 
-	// Either save or remove the cookie, depending on when it expires.
+// store either saves or removes an entry received in a Set-Cookie header.
+func (j *Jar) store(entry *jarEntry, remove bool, now time.Time) {
 	if remove {
-		j.remove(entry)
+		j.remove(entry, now)
 	} else {
-		j.set(entry)
-	}
+		// Labels and send counts survive the entry being overwritten.
+		if prev, ok := j.ent[entry.Root][entry.Key]; ok {
+			entry.Labels = prev.Labels
+			entry.Sends = prev.Sends
+		}
 
-	return nil
+		j.set(entry, now)
+	}
 }
 
-// set creates or overwrites a cookie entry.
-func (j *Jar) set(entry *jarEntry) {
-	bucket, ok := j.ent[entry.Root]
-	if !ok {
-		bucket = make(map[string]*jarEntry)
-		j.ent[entry.Root] = bucket
+// set creates or overwrites a cookie entry, logging the operation as having
+// happened at time now if history is enabled.
+func (j *Jar) set(entry *jarEntry, now time.Time) {
+	j.preserve(entry.Root)
+
+	if prev, ok := j.ent[entry.Root][entry.Key]; ok {
+		if j.paths != nil {
+			j.paths.remove(prev)
+		}
+		j.uncount(prev)
+		if j.evictions != nil {
+			heap.Remove(j.evictions, prev.evictIndex)
+		}
+	}
+
+	j.rev++
+	entry.Rev = j.rev
+
+	j.insert(entry)
+	j.count(entry)
+	delete(j.dead, entry.Key)
+	if j.evictions != nil {
+		heap.Push(j.evictions, entry)
 	}
 
-	bucket[entry.Key] = entry
+	j.notify(Change{Rev: entry.Rev, Entry: entry.Entry})
+	if j.history != nil {
+		j.logHistory(historyRecord{at: now, rev: entry.Rev, entry: *entry})
+	}
+
+	j.enforceLimits(entry, now)
+}
+
+// remove removes a cookie entry, logging the operation as having happened at
+// time now if history is enabled.
+func (j *Jar) remove(entry *jarEntry, now time.Time) {
+	j.drop(entry, false, now)
 }
 
-// remove removes a cookie entry.
-func (j *Jar) remove(entry *jarEntry) {
+// drop implements remove, additionally marking the change as an eviction if
+// evicted is true.
+func (j *Jar) drop(entry *jarEntry, evicted bool, now time.Time) {
 	bucket, ok := j.ent[entry.Root]
 	if !ok {
 		return
 	}
 
+	old, ok := bucket[entry.Key]
+	if !ok {
+		return
+	}
+
+	j.preserve(entry.Root)
+
 	delete(bucket, entry.Key)
 	if len(bucket) == 0 {
 		delete(j.ent, entry.Root)
 	}
+
+	if j.paths != nil {
+		j.paths.remove(old)
+	}
+	j.uncount(old)
+	if j.evictions != nil {
+		heap.Remove(j.evictions, old.evictIndex)
+	}
+
+	if evicted {
+		j.gc.Evicted++
+	}
+
+	// Remember the removal so it can be reported by ChangesSince.
+	j.rev++
+	old.Rev = j.rev
+	j.dead[entry.Key] = old
+	if len(j.dead) > 2*maxTombstones {
+		j.pruneDead()
+	}
+
+	j.notify(Change{Rev: old.Rev, Removed: true, Evicted: evicted, Entry: old.Entry})
+	if j.history != nil {
+		j.logHistory(historyRecord{at: now, rev: old.Rev, removed: true, entry: *old})
+	}
 }
 
 // newEntry creates a new jarEntry instance.
-func newEntry(c *Cookie, host string, psl PublicSuffixList, now time.Time) (*jarEntry, bool, error) {
+func newEntry(c *Cookie, host, root string, psl PublicSuffixList, now time.Time) (*jarEntry, bool, error) {
 	var err error
 
 	entry := &jarEntry{
-		Created:  now,
-		Name:     c.Name,
-		Value:    c.Value,
-		Secure:   c.Secure,
-		HttpOnly: c.HttpOnly,
+		Entry: Entry{
+			Created:  now,
+			Name:     c.Name,
+			Value:    c.Value,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: sameSiteOf(c),
+		},
 	}
 
 	entry.Domain, entry.HostOnly, err = validateDomain(host, c.Domain, psl)
@@ -148,35 +522,62 @@ func newEntry(c *Cookie, host string, psl PublicSuffixList, now time.Time) (*jar
 		entry.Path = c.Path
 	}
 
+	// Populate bookkeeping fields. The key is left to the caller, as it
+	// depends on the jar's conflict policy.
+	entry.Root = root
+
 	// Figure out when the cookie is scheduled to expire.
-	// Max-Age takes prescendence over Expires.
-	if c.MaxAge < 0 {
+	var remove bool
+	if entry.Expires, remove = Expiry(c, now); remove {
 		return entry, true, nil
-	} else if c.MaxAge > 0 {
-		entry.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
-	} else if !c.Expires.IsZero() {
-		if c.Expires.After(now) {
-			entry.Expires = c.Expires
-		} else {
-			return entry, true, nil
-		}
 	}
 
-	// Populate bookkeeping fields.
-	entry.Root = domainRoot(host, psl)
-	entry.Key = entry.Domain + ";" + entry.Path + ";" + entry.Name
-
 	return entry, false, nil
 }
 
-// A jarEntry adds some bookkeeping metadata to a reduced Cookie.
-type jarEntry struct {
-	Root string
-	Key  string
+// Expiry determines when a cookie received at time now expires, following
+// RFC 6265, section 5.3. A zero time means the cookie is a session cookie,
+// and the second return value is true if the cookie has already expired. The
+// rules, in order of precedence, are:
+//
+//	MaxAge   Expires           Result
+//	< 0      (any)             expired (Max-Age=0 or negative)
+//	> 0      (any)             expires MaxAge seconds after now
+//	0        zero              session cookie
+//	0        after now         expires at Expires
+//	0        not after now     expired
+//
+// In other words, a Max-Age attribute always takes precedence over Expires,
+// even when Expires is in the past. MaxAge values too large to be represented
+// are clamped to the largest representable duration.
+func Expiry(c *Cookie, now time.Time) (time.Time, bool) {
+	switch {
+	case c.MaxAge < 0:
+		return time.Time{}, true
+
+	case c.MaxAge > 0:
+		const max = int64(math.MaxInt64 / time.Second)
+		if int64(c.MaxAge) > max {
+			return now.Add(time.Duration(max) * time.Second), false
+		}
+		return now.Add(time.Duration(c.MaxAge) * time.Second), false
+
+	case c.Expires.IsZero():
+		return time.Time{}, false
+
+	case c.Expires.After(now):
+		return c.Expires, false
+	}
 
+	return time.Time{}, true
+}
+
+// An Entry describes a cookie as stored in a Jar.
+type Entry struct {
 	Created  time.Time
 	Expires  time.Time
 	HostOnly bool
+	SameSite SameSite
 
 	// Subset of the Cookie type.
 	Name     string
@@ -185,6 +586,155 @@ type jarEntry struct {
 	Path     string
 	Secure   bool
 	HttpOnly bool
+
+	// Application-defined labels, set using Jar.Label.
+	Labels map[string]string
+
+	// Number of times the entry has been included in a request, through
+	// Cookies, CookieHeader or CookiesInContext, and when it last was. The
+	// latter is zero if the entry has never been sent.
+	Sends    uint64
+	LastUsed time.Time
+
+	// Unparsed attributes of the cookie, such as "Priority=High". Only kept
+	// by jars on which KeepUnparsed has been called.
+	Unparsed []string
+
+	// Partition key of a partitioned cookie, consisting of the scheme and
+	// domain root of the top-level site it was set in the context of, such
+	// as "https://example.com". Empty for unpartitioned cookies.
+	Partition string
+}
+
+// Cookie returns the entry as a cookie suitable for a "Set-Cookie" header,
+// for example to pass it on to another client. The Domain field is only set
+// if the entry isn't host-only.
+func (e *Entry) Cookie() *Cookie {
+	c := &Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		SameSite: e.SameSite,
+
+		Partitioned: e.Partition != "",
+		Unparsed:    append([]string(nil), e.Unparsed...),
+	}
+	if !e.HostOnly {
+		c.Domain = e.Domain
+	}
+	return c
+}
+
+// clone returns a copy of the entry which shares no labels or unparsed
+// attributes with the original.
+func (e *Entry) clone() Entry {
+	c := *e
+	if e.Labels != nil {
+		c.Labels = make(map[string]string, len(e.Labels))
+		for k, v := range e.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if e.Unparsed != nil {
+		c.Unparsed = append([]string(nil), e.Unparsed...)
+	}
+	return c
+}
+
+// Entries returns copies of all of the jar's entries, including expired
+// entries which are yet to be swept, sorted by domain, path and name within
+// each domain root.
+func (j *Jar) Entries() []Entry {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	sorted := j.sorted()
+
+	entries := make([]Entry, len(sorted))
+	for i, entry := range sorted {
+		entries[i] = entry.clone()
+	}
+	return entries
+}
+
+// EntriesFor returns copies of the entries relevant for the scheme, host and
+// path combination, with all of their attributes, in the order CookieHeader
+// sends them. Unlike Cookies, it neither counts the entries as sent nor
+// deletes expired entries, so that debugging tools and exporters can inspect
+// what the jar would send without affecting it.
+func (j *Jar) EntriesFor(scheme, host, path string, now time.Time) ([]Entry, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	found, err := j.entries(scheme, host, path, "", now, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(headerOrder(found))
+
+	entries := make([]Entry, len(found))
+	for i, entry := range found {
+		entries[i] = entry.clone()
+	}
+	return entries, nil
+}
+
+// sorted returns all of the jar's entries, sorted by domain root, domain, path
+// and name, so that exports are reproducible.
+func (j *Jar) sorted() []*jarEntry {
+	entries := make([]*jarEntry, 0, j.len())
+	for _, bucket := range j.ent {
+		for _, entry := range bucket {
+			entries = append(entries, entry)
+		}
+	}
+
+	sortEntries(entries)
+	return entries
+}
+
+// sortEntries sorts entries by domain root, domain, path and name.
+func sortEntries(entries []*jarEntry) {
+	sort.Slice(entries, func(a, b int) bool {
+		x, y := entries[a], entries[b]
+		switch {
+		case x.Root != y.Root:
+			return x.Root < y.Root
+		case x.Domain != y.Domain:
+			return x.Domain < y.Domain
+		case x.Path != y.Path:
+			return x.Path < y.Path
+		}
+		return x.Name < y.Name
+	})
+}
+
+// A jarEntry adds some bookkeeping metadata to an Entry.
+type jarEntry struct {
+	Root string
+	Key  string
+	Rev  uint64
+
+	// Whether the entry is counted as expired by Stats.
+	expired bool
+
+	// The entry's position in the jar's eviction heap, if it has one.
+	evictIndex int
+
+	Entry
+}
+
+// lastUsed returns the time the entry was last sent, or the time it was
+// created if it has never been sent.
+func (entry *jarEntry) lastUsed() time.Time {
+	if entry.LastUsed.IsZero() {
+		return entry.Created
+	}
+	return entry.LastUsed
 }
 
 // shouldSend returns true if the cookie entry is relevant for requests to
@@ -194,15 +744,29 @@ func (entry *jarEntry) shouldSend(scheme, host, path string) bool {
 		return false
 	}
 
-	if entry.Domain != host && (entry.HostOnly || !hasDotSuffix(host, entry.Domain)) {
-		return false
+	return domainMatch(host, entry.Domain, entry.HostOnly) && PathMatch(path, entry.Path)
+}
+
+// domainMatch returns true if a cookie with the specified domain should be
+// sent to host.
+func domainMatch(host, domain string, hostOnly bool) bool {
+	return domain == host || (!hostOnly && hasDotSuffix(host, domain))
+}
+
+// PathMatch returns true if a request path path-matches a cookie path, as
+// defined in RFC 6265, section 5.1.4: the paths are identical, or the cookie
+// path is a prefix of the request path ending at a '/'. Note that "/foo"
+// doesn't path-match "/foobar".
+func PathMatch(path, cookiePath string) bool {
+	if cookiePath == "" {
+		return path == ""
 	}
 
-	if path != entry.Path {
-		if !strings.HasPrefix(path, entry.Path) {
+	if path != cookiePath {
+		if !strings.HasPrefix(path, cookiePath) {
 			return false
 		}
-		if entry.Path[len(entry.Path)-1] != '/' && path[len(entry.Path)] != '/' {
+		if cookiePath[len(cookiePath)-1] != '/' && path[len(cookiePath)] != '/' {
 			return false
 		}
 	}
@@ -217,22 +781,24 @@ func validateDomain(host, domain string, psl PublicSuffixList) (string, bool, er
 		return host, true, nil
 	}
 
+	// An IP address only domain-matches itself, so such a cookie can only
+	// ever be sent to the host setting it.
+	if ip := ipDomain(domain); ip != nil {
+		if !ip.Equal(net.ParseIP(host)) {
+			return "", false, ErrIPDomain
+		}
+		return host, true, nil
+	}
+
 	if isIP(host) {
 		return "", false, errNoHostname
 	}
 
-	// We allow (and ignore) a single leading dot. After that, though, domains
-	// which are either empty or have leading or trailing dots are considered
-	// malformed.
-	if domain[0] == '.' {
-		domain = domain[1:]
-	}
-	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
-		return "", false, errMalformedDomain
+	domain, err := NormalizeCookieDomain(domain)
+	if err != nil {
+		return "", false, err
 	}
 
-	domain = strings.ToLower(domain)
-
 	if psl != nil {
 		suffix := psl.PublicSuffix(domain)
 		if suffix != "" && !hasDotSuffix(domain, suffix) {
@@ -254,22 +820,6 @@ func validateDomain(host, domain string, psl PublicSuffixList) (string, bool, er
 	return domain, false, nil
 }
 
-// canonicalHost canonicalizes a hostname.
-func canonicalHost(host string) (string, error) {
-	host = strings.ToLower(host)
-
-	if hasPort(host) {
-		var err error
-
-		host, _, err = net.SplitHostPort(host)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	return toASCII(host)
-}
-
 // domainRoot returns the domain root for a particular host. For example,
 // "example.com" in the case of "foo.bar.example.com".
 func domainRoot(host string, psl PublicSuffixList) string {
@@ -293,6 +843,17 @@ func domainRoot(host string, psl PublicSuffixList) string {
 	return ""
 }
 
+// ipDomain returns the IP address a Domain attribute value consists of,
+// ignoring a single leading dot and any square brackets, or nil if it isn't
+// one.
+func ipDomain(domain string) net.IP {
+	domain = strings.TrimPrefix(domain, ".")
+	if len(domain) > 2 && domain[0] == '[' && domain[len(domain)-1] == ']' {
+		domain = domain[1 : len(domain)-1]
+	}
+	return net.ParseIP(domain)
+}
+
 // isIP returns true if host is an IP address.
 func isIP(host string) bool {
 	return net.ParseIP(host) != nil
@@ -310,7 +871,7 @@ func hasPort(addr string) bool {
 	for i, c := range addr {
 		if c == ':' {
 			colons++
-			rbrack = addr[i-1] == ']'
+			rbrack = i > 0 && addr[i-1] == ']'
 		}
 	}
 
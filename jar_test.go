@@ -0,0 +1,1857 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPSL is a simplistic public suffix list, treating every top-level domain
+// as a public suffix.
+type testPSL struct{}
+
+func (testPSL) PublicSuffix(domain string) string {
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+var testNow = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestChangesSince(t *testing.T) {
+	src := NewJar(testPSL{})
+	dst := NewJar(testPSL{})
+
+	set := func(host string, c *Cookie) {
+		if err := src.SetCookie("http", host, "/", c, testNow); err != nil {
+			t.Fatalf("SetCookie(%q, %+v): %v", host, c, err)
+		}
+	}
+
+	// The revisions of the two jars differ, so the source's revision must be
+	// tracked separately.
+	var rev uint64
+	sync := func() {
+		changes, next := src.ChangesSince(rev)
+		if err := dst.Apply(changes); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		rev = next
+	}
+
+	set("www.example.com", &Cookie{Name: "a", Value: "1"})
+	set("www.example.com", &Cookie{Name: "b", Value: "2", Domain: "example.com"})
+	sync()
+
+	if changes, rev := src.ChangesSince(src.Rev()); len(changes) != 0 || rev != src.Rev() {
+		t.Errorf("ChangesSince(%d) = %+v, %d", src.Rev(), changes, rev)
+	}
+
+	set("www.example.com", &Cookie{Name: "a", MaxAge: -1})
+	set("www.example.com", &Cookie{Name: "c", Value: "3"})
+	sync()
+
+	want, _ := src.Cookies("http", "www.example.com", "/", testNow)
+	got, _ := dst.Cookies("http", "www.example.com", "/", testNow)
+
+	sortCookies(want)
+	sortCookies(got)
+
+	if len(want) != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("synced jar:")
+		t.Errorf("  got  %+v", got)
+		t.Errorf("  want %+v", want)
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	j := NewJar(testPSL{})
+
+	names := make([]string, 3*maxTombstones)
+	for i := range names {
+		names[i] = "c" + strconv.Itoa(i)
+		j.SetCookie("http", "example.com", "/", &Cookie{Name: names[i], Value: "1"}, testNow)
+	}
+	start := j.Rev()
+	for _, name := range names {
+		j.SetCookie("http", "example.com", "/", &Cookie{Name: name, MaxAge: -1}, testNow)
+	}
+
+	if n := len(j.dead); n > 2*maxTombstones {
+		t.Errorf("jar remembers %d removals", n)
+	}
+
+	horizon := j.Horizon()
+	if horizon <= start || horizon >= j.Rev() {
+		t.Fatalf("Horizon() = %d, want between %d and %d", horizon, start, j.Rev())
+	}
+
+	// All removals after the horizon are still reported.
+	changes, _ := j.ChangesSince(horizon)
+	if len(changes) != int(j.Rev()-horizon) {
+		t.Errorf("ChangesSince(%d) returned %d changes, want %d", horizon, len(changes), j.Rev()-horizon)
+	}
+	for _, c := range changes {
+		if !c.Removed {
+			t.Errorf("ChangesSince(%d) returned %+v", horizon, c)
+		}
+	}
+}
+
+// sortCookies sorts a slice of cookies by name.
+func sortCookies(cookies []*Cookie) {
+	for i := 1; i < len(cookies); i++ {
+		for j := i; j > 0 && cookies[j].Name < cookies[j-1].Name; j-- {
+			cookies[j], cookies[j-1] = cookies[j-1], cookies[j]
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	j := NewJar(testPSL{})
+	v := j.ReadOnly()
+
+	if err := j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1", MaxAge: 60}, testNow); err != nil {
+		t.Fatalf("SetCookie: %v", err)
+	}
+
+	if err := v.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "2"}, testNow); err != ErrReadOnly {
+		t.Errorf("View.SetCookie returned %v, want ErrReadOnly", err)
+	}
+
+	// Reading through the view mustn't sweep expired entries.
+	later := testNow.Add(time.Hour)
+	if cookies, _ := v.Cookies("http", "example.com", "/", later); len(cookies) != 0 {
+		t.Errorf("View.Cookies returned expired cookies: %+v", cookies)
+	}
+	if _, rev := j.ChangesSince(0); rev != 1 {
+		t.Errorf("View.Cookies modified the jar")
+	}
+
+	if cookies, _ := v.Cookies("http", "example.com", "/", testNow); len(cookies) != 1 {
+		t.Errorf("View.Cookies returned %+v, want one cookie", cookies)
+	}
+}
+
+func TestHAR(t *testing.T) {
+	src := NewJar(testPSL{})
+	expires := testNow.Add(time.Hour)
+
+	src.SetCookie("https", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Secure: true, Unparsed: []string{"samesite=none"}}, testNow)
+	src.SetCookie("https", "www.example.com", "/", &Cookie{Name: "b", Value: "2", Domain: "example.com", Path: "/x", Expires: expires}, testNow)
+
+	har := src.ExportHAR(testNow)
+	if len(har) != 2 {
+		t.Fatalf("ExportHAR returned %d cookies, want 2", len(har))
+	}
+
+	dst := NewJar(testPSL{})
+	if err := dst.ImportHAR(har, testNow); err != nil {
+		t.Fatalf("ImportHAR: %v", err)
+	}
+
+	want, _ := src.ChangesSince(0)
+	got, _ := dst.ChangesSince(0)
+
+	if len(got) != len(want) {
+		t.Fatalf("ImportHAR produced %d entries, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		found := false
+		for j := range got {
+			found = found || reflect.DeepEqual(got[j].Entry, want[i].Entry)
+		}
+		if !found {
+			t.Errorf("entry missing after HAR round trip: %+v", want[i].Entry)
+		}
+	}
+
+	// HAR cookies are validated like those set by their domain.
+	invalid := [][]HARCookie{
+		{{Name: "a", Value: "1", Domain: ".com"}},
+		{{Name: "a", Value: "1;", Domain: "example.com"}},
+		{{Name: "__Host-a", Value: "1", Domain: ".example.com", Secure: true}},
+	}
+	for _, har := range invalid {
+		if err := NewJar(testPSL{}).ImportHAR(har, testNow); err == nil {
+			t.Errorf("ImportHAR(%+v) succeeded", har)
+		}
+	}
+}
+
+func TestNetscape(t *testing.T) {
+	clock := func() time.Time { return testNow }
+	expires := testNow.Add(time.Hour)
+
+	src := NewJar(testPSL{})
+	src.SetClock(clock)
+	src.SetCookie("https", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Secure: true, HttpOnly: true}, testNow)
+	src.SetCookie("https", "www.example.com", "/", &Cookie{Name: "b", Value: "2", Domain: "example.com", Path: "/x", Expires: expires}, testNow)
+	src.SetCookie("https", "www.example.com", "/", &Cookie{Name: "c", Value: "3", Expires: testNow.Add(-time.Hour)}, testNow)
+
+	var b bytes.Buffer
+	if err := src.ExportNetscape(&b); err != nil {
+		t.Fatalf("ExportNetscape: %v", err)
+	}
+
+	want := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/x\tFALSE\t" + strconv.FormatInt(expires.Unix(), 10) + "\tb\t2\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tTRUE\t0\ta\t1\n"
+	if b.String() != want {
+		t.Errorf("ExportNetscape wrote:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	dst := NewJar(testPSL{})
+	dst.SetClock(clock)
+	if err := dst.ImportNetscape(strings.NewReader(b.String() + "\n# comment\r\nexample.org\tFALSE\t/\tFALSE\t0\tempty\n")); err != nil {
+		t.Fatalf("ImportNetscape: %v", err)
+	}
+
+	if header, _ := dst.CookieHeader("https", "www.example.com", "/x", testNow); header != "b=2; a=1" {
+		t.Errorf("after ImportNetscape: CookieHeader = %#q", header)
+	}
+	for _, e := range dst.Entries() {
+		if e.Name == "a" && (!e.HttpOnly || !e.Secure || !e.HostOnly) {
+			t.Errorf("after ImportNetscape: entry a = %+v", e)
+		}
+		if e.Name == "b" && !e.Expires.Equal(expires) {
+			t.Errorf("after ImportNetscape: entry b expires at %v, want %v", e.Expires, expires)
+		}
+	}
+	if n := len(dst.Entries()); n != 3 {
+		t.Errorf("after ImportNetscape: %d entries, want 3", n)
+	}
+
+	// Invalid files are rejected as a whole.
+	bad := NewJar(testPSL{})
+	if err := bad.ImportNetscape(strings.NewReader("example.com\tTRUE\t/\tFALSE\t0\ta\t1\nexample.com\tMAYBE\t/\tFALSE\t0\tb\t2\n")); err == nil {
+		t.Errorf("ImportNetscape accepted invalid line")
+	}
+	if err := bad.ImportNetscape(strings.NewReader("example.com\tTRUE\t/\tFALSE\t0\ta\t1\n.com\tTRUE\t/\tFALSE\t0\tb\t2\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ImportNetscape of public suffix domain returned %v", err)
+	}
+	if n := len(bad.Entries()); n != 0 {
+		t.Errorf("failed ImportNetscape added %d entries", n)
+	}
+}
+
+func TestRetentionRules(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.AddRetentionRule(RetentionRule{Host: "*.ads.example", MaxTTL: time.Hour})
+	j.AddRetentionRule(RetentionRule{Host: "login.example", SessionTTL: 24 * time.Hour})
+
+	j.SetCookie("http", "x.ads.example", "/", &Cookie{Name: "a", Value: "1", MaxAge: 86400}, testNow)
+	j.SetCookie("http", "login.example", "/", &Cookie{Name: "b", Value: "2"}, testNow)
+	j.SetCookie("http", "www.example", "/", &Cookie{Name: "c", Value: "3"}, testNow)
+
+	tests := []struct {
+		host  string
+		after time.Duration
+		n     int
+	}{
+		{"x.ads.example", 30 * time.Minute, 1},
+		{"x.ads.example", 2 * time.Hour, 0},
+		{"login.example", 12 * time.Hour, 1},
+		{"login.example", 48 * time.Hour, 0},
+		{"www.example", 48 * time.Hour, 1},
+	}
+
+	for _, test := range tests {
+		cookies, _ := j.ReadOnly().Cookies("http", test.host, "/", testNow.Add(test.after))
+		if len(cookies) != test.n {
+			t.Errorf("Cookies(%q) after %s returned %d cookies, want %d", test.host, test.after, len(cookies), test.n)
+		}
+	}
+}
+
+func TestCookiesAt(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.EnableHistory()
+
+	t1 := testNow
+	t2 := testNow.Add(time.Minute)
+	t3 := testNow.Add(2 * time.Minute)
+
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, t1)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "2"}, t2)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", MaxAge: -1}, t3)
+
+	tests := []struct {
+		at  time.Time
+		out []*Cookie
+	}{
+		{t1.Add(-time.Second), nil},
+		{t1, []*Cookie{{Name: "a", Value: "1"}}},
+		{t2.Add(time.Second), []*Cookie{{Name: "a", Value: "2"}}},
+		{t3, nil},
+	}
+
+	for _, test := range tests {
+		out, err := j.CookiesAt("http", "example.com", "/", test.at)
+		if !reflect.DeepEqual(out, test.out) || err != nil {
+			t.Errorf("CookiesAt(%s):", test.at)
+			t.Errorf("  got  %+v, %+v", out, err)
+			t.Errorf("  want %+v, %+v", test.out, nil)
+		}
+	}
+
+	// The history is bounded, but entries set by pruned operations remain
+	// visible to later queries.
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "keep", Value: "1"}, t3)
+	for i := 0; i < maxHistory; i++ {
+		j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: strconv.Itoa(i)}, t3.Add(time.Duration(i)*time.Second))
+	}
+	if len(j.history) > maxHistory {
+		t.Errorf("history holds %d records, want at most %d", len(j.history), maxHistory)
+	}
+	if _, err := j.CookiesAt("http", "example.com", "/", t1); err != errHistoryPruned {
+		t.Errorf("CookiesAt before pruned records returned %v, want %v", err, errHistoryPruned)
+	}
+	last := t3.Add(time.Duration(maxHistory-1) * time.Second)
+	want := []*Cookie{{Name: "b", Value: strconv.Itoa(maxHistory - 1)}, {Name: "keep", Value: "1"}}
+	out, err := j.CookiesAt("http", "example.com", "/", last)
+	if sortCookies(out); !reflect.DeepEqual(out, want) || err != nil {
+		t.Errorf("CookiesAt(%s) = %+v, %v, want %+v", last, out, err, want)
+	}
+}
+
+func TestCookiesAtRemovals(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.EnableHistory()
+	j.SetLimits(Limits{PerRoot: 2})
+
+	t1 := testNow
+	t2 := testNow.Add(time.Minute)
+	t3 := testNow.Add(2 * time.Minute)
+	t4 := testNow.Add(3 * time.Minute)
+
+	// Eviction.
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, t1)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "1"}, t1)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "c", Value: "1"}, t2)
+
+	// Removal and relabeling.
+	j.SetClock(func() time.Time { return t3 })
+	j.Remove("example.com", "/", "b")
+	j.Label("example.com", "/", "c", "k", "v")
+
+	// Clearing.
+	j.SetClock(func() time.Time { return t4 })
+	j.Clear()
+
+	tests := []struct {
+		at  time.Time
+		out []*Cookie
+	}{
+		{t1, []*Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "1"}}},
+		{t2, []*Cookie{{Name: "b", Value: "1"}, {Name: "c", Value: "1"}}},
+		{t3, []*Cookie{{Name: "c", Value: "1"}}},
+		{t4, nil},
+	}
+
+	for _, test := range tests {
+		out, err := j.CookiesAt("http", "example.com", "/", test.at)
+		if sortCookies(out); !reflect.DeepEqual(out, test.out) || err != nil {
+			t.Errorf("CookiesAt(%s):", test.at)
+			t.Errorf("  got  %+v, %+v", out, err)
+			t.Errorf("  want %+v, %+v", test.out, nil)
+		}
+	}
+}
+
+func TestTx(t *testing.T) {
+	j := NewJar(testPSL{})
+
+	var calls, changes int
+	j.Observe(func(c []Change) {
+		calls++
+		changes += len(c)
+	})
+
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "old", Value: "1"}, testNow)
+
+	// A failing operation should prevent all others from being applied.
+	tx := j.Begin()
+	tx.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	tx.SetCookie("ftp", "example.com", "/", &Cookie{Name: "b", Value: "2"}, testNow)
+	if err := tx.Commit(); err == nil {
+		t.Errorf("Commit succeeded despite invalid operation")
+	}
+	if cookies, _ := j.Cookies("http", "example.com", "/", testNow); len(cookies) != 1 {
+		t.Errorf("failed transaction modified the jar: %+v", cookies)
+	}
+
+	tx = j.Begin()
+	tx.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	tx.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "2"}, testNow)
+	tx.Remove("example.com", "/", "old", testNow)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	cookies, _ := j.Cookies("http", "example.com", "/", testNow)
+	sortCookies(cookies)
+
+	want := []*Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Errorf("after Commit:")
+		t.Errorf("  got  %+v", cookies)
+		t.Errorf("  want %+v", want)
+	}
+
+	if calls != 2 || changes != 4 {
+		t.Errorf("observers called %d times with %d changes, want 2 and 4", calls, changes)
+	}
+}
+
+func TestPathIndex(t *testing.T) {
+	plain := NewJar(testPSL{})
+	indexed := NewJar(testPSL{})
+	indexed.EnablePathIndex()
+
+	paths := []string{"/", "/a", "/a/", "/a/b", "/ab", "/a/b/c/", "/x"}
+
+	for i, p := range paths {
+		c := &Cookie{Name: "c" + strconv.Itoa(i), Value: "1", Path: p}
+		plain.SetCookie("http", "example.com", "/", c, testNow)
+		indexed.SetCookie("http", "example.com", "/", c, testNow)
+	}
+
+	for _, p := range []string{"/", "/a", "/a/", "/a/b", "/a/bc", "/a/b/c/d", "/ab/c", "/y"} {
+		want, _ := plain.Cookies("http", "example.com", p, testNow)
+		got, _ := indexed.Cookies("http", "example.com", p, testNow)
+
+		sortCookies(want)
+		sortCookies(got)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Cookies(%q) with path index:", p)
+			t.Errorf("  got  %+v", got)
+			t.Errorf("  want %+v", want)
+		}
+	}
+}
+
+// deepPathJar returns a jar holding cookies scoped to n different paths,
+// nested up to ten levels deep.
+func deepPathJar(n int) *Jar {
+	j := NewJar(testPSL{})
+
+	for i := 0; i < n; i++ {
+		path := ""
+		for d := 0; d <= i%10; d++ {
+			path += "/d" + strconv.Itoa((i/10+d)%7)
+		}
+		c := &Cookie{Name: "c" + strconv.Itoa(i), Value: "1", Path: path}
+		j.SetCookie("http", "example.com", "/", c, testNow)
+	}
+
+	return j
+}
+
+func BenchmarkCookiesDeepPaths(b *testing.B) {
+	j := deepPathJar(500)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		j.Cookies("http", "example.com", "/d1/d2/d3/d4/d5/d6", testNow)
+	}
+}
+
+func BenchmarkCookiesDeepPathsIndexed(b *testing.B) {
+	j := deepPathJar(500)
+	j.EnablePathIndex()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		j.Cookies("http", "example.com", "/d1/d2/d3/d4/d5/d6", testNow)
+	}
+}
+
+// slowPSL is a public suffix list implementation with the cost profile of a
+// real one, for the benefit of benchmarks.
+type slowPSL struct{}
+
+func (slowPSL) PublicSuffix(domain string) string {
+	labels := strings.Split(domain, ".")
+	return strings.Join(labels[len(labels)-1:], ".")
+}
+
+func TestSetPublicSuffixList(t *testing.T) {
+	j := NewJar(nil)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+
+	j.SetPublicSuffixList(testPSL{})
+
+	if cookies, _ := j.Cookies("http", "www.example.com", "/", testNow); len(cookies) != 1 {
+		t.Errorf("Cookies returned %+v after SetPublicSuffixList, want one cookie", cookies)
+	}
+	if root := j.ent["example.com"]; len(root) != 1 {
+		t.Errorf("entry wasn't moved to its new bucket")
+	}
+}
+
+func TestRescope(t *testing.T) {
+	// A list on which example.com has become a public suffix.
+	psl := NewPublicSuffixList(func(domain string) string {
+		if domain == "example.com" || strings.HasSuffix(domain, ".example.com") {
+			return "example.com"
+		}
+		return testPSL{}.PublicSuffix(domain)
+	})
+
+	for _, remove := range []bool{false, true} {
+		j := NewJar(testPSL{})
+		j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+		j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "b", Value: "1", Domain: "example.com"}, testNow)
+		j.SetCookie("http", "www.example.org", "/", &Cookie{Name: "c", Value: "1"}, testNow)
+
+		var removed []string
+		j.Observe(func(changes []Change) {
+			for _, c := range changes {
+				if c.Removed {
+					removed = append(removed, c.Entry.Name)
+				}
+			}
+		})
+
+		rescoped := j.SetPublicSuffixListWithOptions(psl, &PSLOptions{RemoveCrossSite: remove})
+
+		var got []string
+		for _, r := range rescoped {
+			got = append(got, fmt.Sprintf("%s %s->%s %v %v", r.Entry.Name, r.OldRoot, r.NewRoot, r.CrossSite, r.Removed))
+		}
+		wantRescoped := []string{
+			fmt.Sprintf("b example.com->example.com true %v", remove),
+			"a example.com->www.example.com false false",
+		}
+		if !reflect.DeepEqual(got, wantRescoped) {
+			t.Errorf("SetPublicSuffixListWithOptions(RemoveCrossSite: %v):", remove)
+			t.Errorf("  got  %q", got)
+			t.Errorf("  want %q", wantRescoped)
+		}
+
+		want := 3
+		if remove {
+			want = 2
+		}
+		if n := j.len(); n != want {
+			t.Errorf("jar holds %d entries with RemoveCrossSite: %v, want %d", n, remove, want)
+		}
+		if remove != reflect.DeepEqual(removed, []string{"b"}) {
+			t.Errorf("observers saw removals %q with RemoveCrossSite: %v", removed, remove)
+		}
+	}
+}
+
+// BenchmarkCookies measures the benefit of caching domain roots, by comparing
+// against a jar whose cache is emptied before every call.
+func BenchmarkCookies(b *testing.B) {
+	j := NewJar(slowPSL{})
+	for i := 0; i < 20; i++ {
+		c := &Cookie{Name: "c" + strconv.Itoa(i), Value: "1"}
+		j.SetCookie("http", "www.example.com", "/", c, testNow)
+	}
+
+	for _, cached := range []bool{true, false} {
+		name := "Cached"
+		if !cached {
+			name = "Uncached"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !cached {
+					clear(j.roots)
+				}
+				j.Cookies("http", "www.example.com", "/", testNow)
+			}
+		})
+	}
+}
+
+var expiryTests = []struct {
+	maxAge  int
+	expires time.Time
+	out     time.Time
+	remove  bool
+}{
+	{0, time.Time{}, time.Time{}, false},
+	{-1, time.Time{}, time.Time{}, true},
+	{-1, testNow.Add(time.Hour), time.Time{}, true},
+	{60, time.Time{}, testNow.Add(time.Minute), false},
+	{60, testNow.Add(-time.Hour), testNow.Add(time.Minute), false},
+	{60, testNow.Add(time.Hour), testNow.Add(time.Minute), false},
+	{0, testNow.Add(time.Hour), testNow.Add(time.Hour), false},
+	{0, testNow, time.Time{}, true},
+	{0, testNow.Add(-time.Hour), time.Time{}, true},
+	{0, time.Unix(0, 0), time.Time{}, true},
+}
+
+func TestExpiry(t *testing.T) {
+	for _, test := range expiryTests {
+		c := &Cookie{Name: "a", Value: "b", MaxAge: test.maxAge, Expires: test.expires}
+
+		out, remove := Expiry(c, testNow)
+		if !out.Equal(test.out) || remove != test.remove {
+			t.Errorf("Expiry(MaxAge=%d, Expires=%s):", test.maxAge, test.expires)
+			t.Errorf("  got  %s, %v", out, remove)
+			t.Errorf("  want %s, %v", test.out, test.remove)
+		}
+
+		// The jar should agree.
+		j := NewJar(nil)
+		j.SetCookie("http", "example.com", "/", c, testNow)
+		if cookies, _ := j.Cookies("http", "example.com", "/", testNow); (len(cookies) == 0) != test.remove {
+			t.Errorf("SetCookie(MaxAge=%d, Expires=%s) stored %d cookies", test.maxAge, test.expires, len(cookies))
+		}
+	}
+
+	// Absurd Max-Age values shouldn't wrap around.
+	if out, _ := Expiry(&Cookie{MaxAge: int(maxInt)}, testNow); !out.After(testNow) {
+		t.Errorf("Expiry with huge MaxAge returned %s", out)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetLimits(Limits{PerRoot: 2, Total: 3})
+
+	var evicted []string
+	j.Observe(func(changes []Change) {
+		for _, c := range changes {
+			if c.Evicted {
+				evicted = append(evicted, c.Entry.Domain+":"+c.Entry.Name)
+			}
+		}
+	})
+
+	set := func(host, name string, at time.Duration) {
+		j.SetCookie("http", host, "/", &Cookie{Name: name, Value: "x"}, testNow.Add(at))
+	}
+
+	set("a.com", "1", 0)
+	if p := j.Pressure(); p != 0.5 {
+		t.Errorf("Pressure() = %v, want 0.5", p)
+	}
+
+	set("a.com", "2", time.Second)
+	if p, _ := j.DomainPressure("www.a.com"); p != 1 {
+		t.Errorf("DomainPressure(www.a.com) = %v, want 1", p)
+	}
+	if p, _ := j.DomainPressure("b.com"); p != 2.0/3 {
+		t.Errorf("DomainPressure(b.com) = %v, want 2/3", p)
+	}
+
+	set("a.com", "3", 2*time.Second)
+	set("b.com", "4", 3*time.Second)
+	set("c.com", "5", 4*time.Second)
+
+	want := []string{"a.com:1", "a.com:2"}
+	if !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted:")
+		t.Errorf("  got  %q", evicted)
+		t.Errorf("  want %q", want)
+	}
+
+	if p := j.Pressure(); p != 1 {
+		t.Errorf("Pressure() = %v, want 1", p)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	tests := []struct {
+		evict Eviction
+		want  string
+	}{
+		{EvictOldest, "a"},
+		{EvictLeastRecentlyUsed, "b"},
+		{EvictEarliestExpiring, "c"},
+	}
+
+	// The total limit is exercised with every cookie in a domain root of its
+	// own, and the per-root limit with all of them in the same one.
+	for _, total := range []bool{false, true} {
+		for _, test := range tests {
+			limits := Limits{PerRoot: 3, Evict: test.evict}
+			host := func(string) string { return "example.com" }
+			if total {
+				limits = Limits{Total: 3, Evict: test.evict}
+				host = func(name string) string { return name + ".com" }
+			}
+
+			j := NewJar(testPSL{})
+			j.SetLimits(limits)
+
+			j.SetCookie("http", host("a"), "/", &Cookie{Name: "a", Value: "1", Path: "/a", MaxAge: 3600}, testNow)
+			j.SetCookie("http", host("b"), "/", &Cookie{Name: "b", Value: "0", Path: "/b"}, testNow.Add(time.Second))
+			j.SetCookie("http", host("c"), "/", &Cookie{Name: "c", Value: "1", Path: "/c", MaxAge: 600}, testNow.Add(2*time.Second))
+			j.SetCookie("http", host("b"), "/", &Cookie{Name: "b", Value: "1", Path: "/b"}, testNow.Add(time.Second))
+			j.Cookies("http", host("a"), "/a", testNow.Add(3*time.Second))
+
+			d := &Cookie{Name: "d", Value: "1", Path: "/d"}
+			o, _ := j.Preview("http", host("d"), "/", d, testNow.Add(4*time.Second))
+			if len(o.Evicted) != 1 || o.Evicted[0].Name != test.want {
+				t.Errorf("Preview with %+v evicts %+v, want %q", limits, o.Evicted, test.want)
+			}
+
+			j.SetCookie("http", host("d"), "/", d, testNow.Add(4*time.Second))
+			for _, e := range j.Entries() {
+				if e.Name == test.want {
+					t.Errorf("%+v did not evict %q", limits, test.want)
+				}
+			}
+		}
+	}
+}
+
+func TestCookieHeader(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "2"}, testNow.Add(time.Second))
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "c", Value: "3", Path: "/x"}, testNow.Add(2*time.Second))
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "d", Value: " 4"}, testNow.Add(3*time.Second))
+
+	tests := []struct {
+		path string
+		out  string
+	}{
+		{"/x/y", `c=3; a=1; b=2; d=" 4"`},
+		{"/", `a=1; b=2; d=" 4"`},
+	}
+
+	for _, test := range tests {
+		out, err := j.CookieHeader("http", "example.com", test.path, testNow.Add(time.Hour))
+		if out != test.out || err != nil {
+			t.Errorf("CookieHeader(%q):", test.path)
+			t.Errorf("  got  %#q, %+v", out, err)
+			t.Errorf("  want %#q, %+v", test.out, nil)
+		}
+	}
+
+	if out, err := NewJar(nil).CookieHeader("http", "example.com", "/", testNow); out != "" || err != nil {
+		t.Errorf("CookieHeader on empty jar returned %#q, %+v", out, err)
+	}
+}
+
+func TestRejectIPCookies(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		j := NewJar(testPSL{})
+		if reject {
+			j.RejectIPCookies()
+		}
+
+		for _, host := range []string{"127.0.0.1", "127.0.0.1:8080", "[::1]:80", "[::1]", "::1"} {
+			err := j.SetCookie("http", host, "/", &Cookie{Name: "a", Value: "1"}, testNow)
+			if reject && err != ErrIPHost || !reject && err != nil {
+				t.Errorf("SetCookie(%q) with reject=%v returned %v", host, reject, err)
+			}
+		}
+
+		if err := j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow); err != nil {
+			t.Errorf("SetCookie(example.com) with reject=%v returned %v", reject, err)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	j := NewJar(testPSL{})
+	for _, host := range []string{"a.example.com", "b.example.com", "x.example.org"} {
+		j.SetCookie("https", host, "/", &Cookie{Name: "s", Value: "1", Secure: true}, testNow)
+	}
+
+	if err := j.AddHSTSHost("A.Example.com"); err != nil {
+		t.Fatalf("AddHSTSHost: %v", err)
+	}
+	j.AddHSTSHost("*.example.org")
+
+	if got, want := j.HSTSHosts(), []string{"*.example.org", "a.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HSTSHosts() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"a.example.com", "s=1"},
+		{"b.example.com", ""},
+		{"x.example.org", "s=1"},
+	}
+
+	for _, test := range tests {
+		if header, _ := j.CookieHeader("http", test.host, "/", testNow); header != test.want {
+			t.Errorf("CookieHeader(http, %s) = %#q, want %#q", test.host, header, test.want)
+		}
+	}
+
+	j.RemoveHSTSHost("a.example.com")
+	if header, _ := j.CookieHeader("http", "a.example.com", "/", testNow); header != "" {
+		t.Errorf("after RemoveHSTSHost: CookieHeader = %#q", header)
+	}
+}
+
+func TestIPDomain(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		err          error // with and without RejectIPDomains
+		rejectErr    error
+	}{
+		{"127.0.0.1", "127.0.0.1", nil, ErrIPDomain},
+		{"127.0.0.1", ".127.0.0.1", nil, ErrIPDomain},
+		{"[::1]:8080", "::1", nil, ErrIPDomain},
+		{"[::1]:80", "[0:0::1]", nil, ErrIPDomain},
+		{"127.0.0.1", "127.0.0.2", ErrIPDomain, ErrIPDomain},
+		{"example.com", "93.184.216.34", ErrIPDomain, ErrIPDomain},
+		{"[::1]:80", "127.0.0.1", ErrIPDomain, ErrIPDomain},
+		{"127.0.0.1", "example.com", errNoHostname, errNoHostname},
+		{"www.example.com", "example.com", nil, nil},
+	}
+
+	for _, reject := range []bool{false, true} {
+		j := NewJar(testPSL{})
+		if reject {
+			j.RejectIPDomains()
+		}
+
+		for _, test := range tests {
+			want := test.err
+			if reject {
+				want = test.rejectErr
+			}
+
+			c := &Cookie{Name: "a", Value: "1", Domain: test.domain}
+			if err := j.SetCookie("http", test.host, "/", c, testNow); err != want {
+				t.Errorf("SetCookie(%q, Domain=%q) with reject=%v returned %v, want %v",
+					test.host, test.domain, reject, err, want)
+			}
+		}
+	}
+
+	// Accepted IP domains result in host-only cookies.
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "127.0.0.1", "/", &Cookie{Name: "a", Value: "1", Domain: "127.0.0.1"}, testNow)
+	if entries := j.Entries(); len(entries) != 1 || !entries[0].HostOnly {
+		t.Errorf("entries after setting IP domain cookie: %+v", entries)
+	}
+}
+
+func TestSameSite(t *testing.T) {
+	j := NewJar(testPSL{})
+	for _, attr := range []string{"SameSite=Strict", "SameSite=Lax", "SameSite=None", "SameSite=bogus", ""} {
+		c := &Cookie{Name: strings.ToLower(strings.TrimPrefix(attr, "SameSite=")), Value: "1"}
+		if attr != "" {
+			c.Unparsed = []string{attr}
+		} else {
+			c.Name = "absent"
+		}
+		j.SetCookie("https", "example.com", "/", c, testNow)
+	}
+
+	tests := []struct {
+		def   SameSite
+		ctx   RequestContext
+		names string
+	}{
+		{SameSiteNone, RequestContext{}, "absent bogus lax none strict"},
+		{SameSiteNone, RequestContext{CrossSite: true}, "absent bogus none"},
+		{SameSiteNone, RequestContext{CrossSite: true, TopLevel: true}, "absent bogus lax none"},
+		{SameSiteNone, RequestContext{CrossSite: true, TopLevel: true, Method: "POST"}, "absent bogus none"},
+		{SameSiteLax, RequestContext{CrossSite: true}, "none"},
+		{SameSiteLax, RequestContext{CrossSite: true, TopLevel: true}, "absent bogus lax none"},
+		{SameSiteLax, RequestContext{}, "absent bogus lax none strict"},
+	}
+
+	for _, test := range tests {
+		j.SetDefaultSameSite(test.def)
+
+		cookies, err := j.CookiesInContext("https", "example.com", "/", test.ctx, testNow)
+		sortCookies(cookies)
+
+		var names []string
+		for _, c := range cookies {
+			names = append(names, c.Name)
+		}
+
+		if got := strings.Join(names, " "); got != test.names || err != nil {
+			t.Errorf("CookiesInContext(%+v) with default %v:", test.ctx, test.def)
+			t.Errorf("  got  %s, %+v", got, err)
+			t.Errorf("  want %s, %+v", test.names, nil)
+		}
+	}
+}
+
+func TestExportOrder(t *testing.T) {
+	cookies := []struct{ host, name, path string }{
+		{"b.example.com", "z", "/"},
+		{"a.example.com", "y", "/x"},
+		{"a.example.com", "y", "/"},
+		{"a.example.com", "x", "/"},
+		{"other.org", "a", "/"},
+	}
+
+	fill := func(reverse bool) *Jar {
+		j := NewJar(testPSL{})
+		for i := range cookies {
+			c := cookies[i]
+			if reverse {
+				c = cookies[len(cookies)-1-i]
+			}
+			j.SetCookie("http", c.host, "/", &Cookie{Name: c.name, Value: "1", Path: c.path}, testNow)
+		}
+		return j
+	}
+
+	a, b := fill(false), fill(true)
+
+	var names []string
+	for _, h := range a.ExportHAR(testNow) {
+		names = append(names, h.Domain+h.Path+h.Name)
+	}
+
+	want := []string{"a.example.com/x", "a.example.com/y", "a.example.com/xy", "b.example.com/z", "other.org/a"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("ExportHAR order:")
+		t.Errorf("  got  %q", names)
+		t.Errorf("  want %q", want)
+	}
+
+	if !reflect.DeepEqual(a.ExportHAR(testNow), b.ExportHAR(testNow)) {
+		t.Errorf("ExportHAR depends on insertion order")
+	}
+
+	var bufA, bufB bytes.Buffer
+	a.WriteIndex(&bufA)
+	b.WriteIndex(&bufB)
+	if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+		t.Errorf("WriteIndex depends on insertion order")
+	}
+}
+
+func TestConflictPolicy(t *testing.T) {
+	setBoth := func(j *Jar) {
+		j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "host"}, testNow)
+		j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "domain", Domain: "example.com"}, testNow.Add(time.Second))
+	}
+
+	tests := []struct {
+		policy ConflictPolicy
+		host   string
+		header string
+	}{
+		// RFC 6265: the domain cookie replaces the host-only one.
+		{ConflictReplace, "example.com", "sid=domain"},
+		{ConflictReplace, "www.example.com", "sid=domain"},
+
+		// Chrome: both are kept, and sent in order of creation.
+		{ConflictKeepBoth, "example.com", "sid=host; sid=domain"},
+		{ConflictKeepBoth, "www.example.com", "sid=domain"},
+	}
+
+	for _, test := range tests {
+		j := NewJar(testPSL{})
+		j.SetConflictPolicy(test.policy)
+		setBoth(j)
+
+		if header, _ := j.CookieHeader("http", test.host, "/", testNow); header != test.header {
+			t.Errorf("CookieHeader(%q) with policy %d = %#q, want %#q", test.host, test.policy, header, test.header)
+		}
+	}
+
+	// Removing the cookie should remove both copies.
+	j := NewJar(testPSL{})
+	j.SetConflictPolicy(ConflictKeepBoth)
+	setBoth(j)
+
+	tx := j.Begin()
+	tx.Remove("example.com", "/", "sid", testNow)
+	tx.Commit()
+
+	if header, _ := j.CookieHeader("http", "example.com", "/", testNow); header != "" {
+		t.Errorf("after Remove: CookieHeader = %#q", header)
+	}
+
+	// Switching back to replacement keeps the newest copy.
+	setBoth(j)
+	j.SetConflictPolicy(ConflictReplace)
+
+	if header, _ := j.CookieHeader("http", "example.com", "/", testNow); header != "sid=domain" {
+		t.Errorf("after switching policy: CookieHeader = %#q", header)
+	}
+
+	// Switching policy during Range leaves the snapshot alone, including
+	// when the newer copy is the one moved.
+	j = NewJar(testPSL{})
+	j.SetConflictPolicy(ConflictKeepBoth)
+	j.SetCookie("http", "a.org", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "domain", Domain: "example.com"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "host"}, testNow.Add(time.Second))
+
+	var values []string
+	j.Range(func(e Entry) bool {
+		if e.Domain == "a.org" {
+			j.SetConflictPolicy(ConflictReplace)
+		}
+		values = append(values, e.Value)
+		return true
+	})
+	if len(values) != 3 {
+		t.Errorf("Range while switching policy returned %q", values)
+	}
+}
+
+var importTests = []struct {
+	in  Entry
+	out string // Cookie header for https://www.example.com/
+	err bool
+}{
+	{Entry{Name: "a", Value: "1", Domain: ".Example.COM"}, "a=1", false},
+	{Entry{Name: "a", Value: "1", Domain: "www.example.com", HostOnly: true}, "a=1", false},
+	{Entry{Name: "a", Value: "1", Domain: "example.com", Expires: testNow}, "", false},
+	{Entry{Name: "a", Value: "1", Domain: "com"}, "", true},
+	{Entry{Name: "a", Value: "1", Domain: "com", HostOnly: true}, "", false},
+	{Entry{Name: "a b", Value: "1", Domain: "example.com"}, "", true},
+	{Entry{Name: "a", Value: "1;2", Domain: "example.com"}, "", true},
+	{Entry{Name: "__Host-a", Value: "1", Domain: "example.com", Secure: true}, "", true},
+}
+
+func TestImport(t *testing.T) {
+	for _, test := range importTests {
+		j := NewJar(testPSL{})
+		j.SetClock(func() time.Time { return testNow })
+
+		_, err := j.Import([]Entry{test.in}, nil)
+		header, _ := j.CookieHeader("https", "www.example.com", "/", testNow)
+		if header != test.out || (err != nil) != test.err {
+			t.Errorf("Import(%+v):", test.in)
+			t.Errorf("  got  %#q, %v", header, err)
+			t.Errorf("  want %#q, error %v", test.out, test.err)
+		}
+	}
+}
+
+func TestImportDedupe(t *testing.T) {
+	at := func(h int) time.Time { return testNow.Add(time.Duration(h) * time.Hour) }
+
+	entries := []Entry{
+		{Name: "a", Value: "1", Domain: "example.com", Path: "/", Created: at(2), Expires: at(10)},
+		{Name: "a", Value: "2", Domain: "example.com", Path: "/", Created: at(1), Expires: at(20)},
+		{Name: "a", Value: "3", Domain: "example.com", Path: "/", Created: at(0)},
+		{Name: "b", Value: "4", Domain: "example.com", Path: "/", Created: at(3)},
+	}
+
+	tests := []struct {
+		policy  DedupePolicy
+		kept    string
+		dropped []string
+	}{
+		{DedupeLast, "a=3; b=4", []string{"1", "2"}},
+		{DedupeLatestCreated, "a=1; b=4", []string{"2", "3"}},
+		{DedupeLatestExpires, "a=2; b=4", []string{"1", "3"}},
+	}
+
+	for _, test := range tests {
+		j := NewJar(testPSL{})
+		j.SetClock(func() time.Time { return testNow })
+
+		dropped, err := j.Import(entries, &ImportOptions{Dedupe: test.policy})
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+
+		var values []string
+		for _, e := range dropped {
+			values = append(values, e.Value)
+		}
+		sort.Strings(values)
+
+		header, _ := j.CookieHeader("http", "example.com", "/", testNow)
+		if header != test.kept || !reflect.DeepEqual(values, test.dropped) {
+			t.Errorf("Import with policy %d:", test.policy)
+			t.Errorf("  got  %#q, dropped %q", header, values)
+			t.Errorf("  want %#q, dropped %q", test.kept, test.dropped)
+		}
+	}
+
+	if _, err := NewJar(nil).Import([]Entry{{Name: "a"}}, nil); err == nil {
+		t.Errorf("Import accepted entry without domain")
+	}
+
+	// HAR imports are deduplicated too.
+	har := []HARCookie{
+		{Name: "a", Value: "1", Domain: "example.com", Expires: at(2).Format(time.RFC3339)},
+		{Name: "a", Value: "2", Domain: "example.com", Expires: at(1).Format(time.RFC3339)},
+	}
+
+	j := NewJar(testPSL{})
+	dropped, err := j.ImportHARWithOptions(har, testNow, &ImportOptions{Dedupe: DedupeLatestExpires})
+	if header, _ := j.CookieHeader("http", "example.com", "/", testNow); header != "a=1" || len(dropped) != 1 || err != nil {
+		t.Errorf("ImportHARWithOptions: %#q, dropped %+v, %v", header, dropped, err)
+	}
+}
+
+func TestReplaceFrom(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "keep", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "change", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "drop", Value: "1"}, testNow)
+
+	var batches [][]Change
+	j.Observe(func(c []Change) { batches = append(batches, c) })
+
+	snapshot := NewJar(testPSL{})
+	snapshot.SetCookie("http", "example.com", "/", &Cookie{Name: "keep", Value: "1"}, testNow)
+	snapshot.SetCookie("http", "example.com", "/", &Cookie{Name: "change", Value: "2"}, testNow)
+	snapshot.SetCookie("http", "other.org", "/", &Cookie{Name: "new", Value: "1"}, testNow)
+
+	j.ReplaceFrom(snapshot)
+
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("observers received %+v, want a single batch of 3 changes", batches)
+	}
+
+	if header, _ := j.CookieHeader("http", "example.com", "/", testNow); header != "change=2; keep=1" && header != "keep=1; change=2" {
+		t.Errorf("after ReplaceFrom: CookieHeader(example.com) = %#q", header)
+	}
+	if header, _ := j.CookieHeader("http", "other.org", "/", testNow); header != "new=1" {
+		t.Errorf("after ReplaceFrom: CookieHeader(other.org) = %#q", header)
+	}
+
+	// The snapshot must not share entries with the jar.
+	snapshot.SetCookie("http", "other.org", "/", &Cookie{Name: "new", Value: "2"}, testNow)
+	if header, _ := j.CookieHeader("http", "other.org", "/", testNow); header != "new=1" {
+		t.Errorf("jar affected by changes to snapshot: %#q", header)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "v1.a"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "v1.b"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "c", Value: "plain"}, testNow)
+	j.Label("example.com", "/", "a", "k", "v")
+
+	values := func() map[string]string {
+		m := make(map[string]string)
+		for _, e := range j.Entries() {
+			m[e.Name] = e.Value
+		}
+		return m
+	}
+
+	var batches [][]Change
+	j.Observe(func(c []Change) { batches = append(batches, c) })
+
+	// A failing transformation leaves every entry untouched.
+	err := j.Rewrap(func(old string) (string, error) {
+		switch {
+		case old == "plain":
+			return "not valid;", nil
+		case old == "v1.b":
+			return "", errors.New("bad key")
+		}
+		return "v2" + old[2:], nil
+	})
+
+	rerr, ok := err.(*RewrapError)
+	if !ok || len(rerr.Failures) != 2 || rerr.Failures[0].Entry.Name != "b" || rerr.Failures[1].Entry.Name != "c" {
+		t.Fatalf("Rewrap returned %v, want failures for b and c", err)
+	}
+	if got, want := values(), map[string]string{"a": "v1.a", "b": "v1.b", "c": "plain"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after failed Rewrap: values = %v, want %v", got, want)
+	}
+	if len(batches) != 0 {
+		t.Errorf("observers notified of failed Rewrap: %+v", batches)
+	}
+
+	// Unchanged values aren't reported, and labels survive.
+	err = j.Rewrap(func(old string) (string, error) {
+		if strings.HasPrefix(old, "v1.") {
+			return "v2" + old[2:], nil
+		}
+		return old, nil
+	})
+	if err != nil {
+		t.Fatalf("Rewrap returned %v", err)
+	}
+	if got, want := values(), map[string]string{"a": "v2.a", "b": "v2.b", "c": "plain"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after Rewrap: values = %v, want %v", got, want)
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("observers received %+v, want a single batch of 2 changes", batches)
+	}
+	if got := j.Labels("example.com", "/", "a"); got["k"] != "v" {
+		t.Errorf("after Rewrap: Labels = %v", got)
+	}
+}
+
+func TestSharedValues(t *testing.T) {
+	j := NewJar(testPSL{})
+	set := func(host, name, value string) {
+		if err := j.SetCookie("https", host, "/", &Cookie{Name: name, Value: value}, testNow); err != nil {
+			t.Fatalf("SetCookie(%s, %s=%s): %v", host, name, value, err)
+		}
+	}
+
+	set("www.shop.com", "_ga", "GA1.2.1234567890123")
+	set("news.org", "uid", "uid=1234567890123")
+	set("blog.net", "id", "1234567890123")
+	set("a.shop.com", "sync", "7c9e6679-7425-40de-944b")
+	set("b.news.org", "sync", "7c9e6679-7425-40de-944b")
+	set("shop.com", "same", "abcdef0123456789")
+	set("www.shop.com", "same", "abcdef0123456789")
+	set("news.org", "word", "authenticated")
+	set("blog.net", "word", "authenticated")
+
+	shared := j.SharedValues(testNow, nil)
+	if len(shared) != 2 {
+		t.Fatalf("SharedValues returned %d findings, want 2: %+v", len(shared), shared)
+	}
+
+	if s := shared[0]; s.Value != "1234567890123" || s.Identical || len(s.Entries) != 3 ||
+		!reflect.DeepEqual(s.Roots, []string{"blog.net", "news.org", "shop.com"}) {
+		t.Errorf("first finding = %+v", s)
+	}
+	if s := shared[1]; s.Value != "7c9e6679-7425-40de-944b" || !s.Identical || len(s.Entries) != 2 {
+		t.Errorf("second finding = %+v", s)
+	}
+
+	// Longer minimum lengths ignore shorter tokens.
+	if shared := j.SharedValues(testNow, &SharedValueOptions{MinLength: 20}); len(shared) != 1 {
+		t.Errorf("SharedValues(MinLength: 20) returned %+v", shared)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetConflictPolicy(ConflictKeepBoth)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "2", Domain: "example.com"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "1"}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "c", Value: "1"}, testNow)
+	j.SetCookie("http", "other.org", "/", &Cookie{Name: "d", Value: "1"}, testNow)
+
+	var batches [][]Change
+	j.Observe(func(c []Change) { batches = append(batches, c) })
+
+	names := func() []string {
+		var names []string
+		for _, e := range j.Entries() {
+			names = append(names, e.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if n := j.Remove(".Example.com", "/", "a"); n != 2 {
+		t.Errorf("Remove removed %d entries, want 2", n)
+	}
+	if got, want := names(), []string{"b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after Remove: entries %v, want %v", got, want)
+	}
+
+	if n := j.RemoveByDomain("example.com"); n != 2 {
+		t.Errorf("RemoveByDomain removed %d entries, want 2", n)
+	}
+	if got, want := names(), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after RemoveByDomain: entries %v, want %v", got, want)
+	}
+
+	if n := j.Clear(); n != 1 || len(j.Entries()) != 0 {
+		t.Errorf("Clear removed %d entries, leaving %d", n, len(j.Entries()))
+	}
+
+	if len(batches) != 3 {
+		t.Errorf("observers received %d batches, want 3", len(batches))
+	}
+	if changes, _ := j.ChangesSince(0); len(changes) != 0 {
+		t.Errorf("ChangesSince(0) after Clear = %+v", changes)
+	}
+
+	// Internationalized domain names may be given in Unicode.
+	j.SetCookie("http", "bücher.example", "/", &Cookie{Name: "e", Value: "1"}, testNow)
+	j.SetCookie("http", "www.bücher.example", "/", &Cookie{Name: "f", Value: "1"}, testNow)
+	if n := j.Remove("Bücher.example", "/", "e"); n != 1 {
+		t.Errorf("Remove(Bücher.example) removed %d entries, want 1", n)
+	}
+	if n := j.RemoveByDomain(".bücher.example"); n != 1 {
+		t.Errorf("RemoveByDomain(.bücher.example) removed %d entries, want 1", n)
+	}
+}
+
+func TestEntriesFor(t *testing.T) {
+	j := NewJar(testPSL{})
+	expires := testNow.Add(time.Hour)
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Secure: true, HttpOnly: true}, testNow)
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "b", Value: "2", Domain: "example.com", Path: "/x", Expires: expires}, testNow)
+	j.SetCookie("https", "other.example.com", "/", &Cookie{Name: "c", Value: "3"}, testNow)
+
+	entries, err := j.ReadOnly().EntriesFor("https", "www.example.com", "/x/y", testNow)
+	if err != nil {
+		t.Fatalf("EntriesFor: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("EntriesFor returned %d entries, want 2", len(entries))
+	}
+
+	if e := entries[0]; e.Name != "b" || e.Domain != "example.com" || e.Path != "/x" || e.HostOnly || !e.Expires.Equal(expires) {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[1]; e.Name != "a" || e.Domain != "www.example.com" || !e.HostOnly || !e.Secure || !e.HttpOnly {
+		t.Errorf("second entry = %+v", e)
+	}
+
+	// Inspecting entries doesn't count as sending them.
+	if got := j.Stats(testNow).Unsent; got != 3 {
+		t.Errorf("Stats().Unsent = %d, want 3", got)
+	}
+
+	if _, err := j.EntriesFor("ftp", "www.example.com", "/", testNow); err == nil {
+		t.Errorf("EntriesFor accepted an invalid scheme")
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetClock(func() time.Time { return testNow })
+	j.SetLimits(Limits{PerRoot: 4, Evict: EvictLeastRecentlyUsed})
+
+	for i := 0; i < 5; i++ {
+		j.SetCookie("https", "example.com", "/", &Cookie{Name: "a" + strconv.Itoa(i), Value: "1"}, testNow)
+	}
+	j.SetCookie("https", "example.org", "/", &Cookie{Name: "b", Value: "2", Expires: testNow.Add(time.Minute)}, testNow)
+	j.SetCookie("https", "example.org", "/", &Cookie{Name: "c", Value: "3", Domain: "example.net"}, testNow)
+
+	j.Cookies("https", "example.org", "/", testNow.Add(time.Hour))
+	j.Begin().SetCookie("https", "example.com", "/", &Cookie{Name: "t", Value: "1", Domain: "example.net"}, testNow)
+
+	w := httptest.NewRecorder()
+	j.DebugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/cookies", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var d debugSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, w.Body)
+	}
+
+	if d.Stats.Total != 4 || d.Stats.Session != 4 || d.Roots != 1 {
+		t.Errorf("stats = %+v, roots = %d", d.Stats, d.Roots)
+	}
+	if d.Limits.PerRoot != 4 || d.Limits.Evict != "EvictLeastRecentlyUsed" || d.Limits.Pressure != 1 {
+		t.Errorf("limits = %+v", d.Limits)
+	}
+	if !reflect.DeepEqual(d.Buckets, []debugBucket{{"example.com", 4}}) {
+		t.Errorf("buckets = %+v", d.Buckets)
+	}
+	if len(d.Rejections) != 2 || d.Rejections[0].Host != "example.org" || d.Rejections[0].Name != "c" || d.Rejections[0].Reason == "" {
+		t.Errorf("rejections = %+v", d.Rejections)
+	} else if d.Rejections[1].Name != "t" {
+		t.Errorf("transaction rejection not recorded: %+v", d.Rejections[1])
+	}
+	if d.GC.Evicted != 1 || d.GC.Swept != 1 {
+		t.Errorf("gc = %+v", d.GC)
+	}
+
+	// Only the most recent rejections are kept.
+	for i := 0; i < maxRejections+5; i++ {
+		j.SetCookie("ftp", "example.com", "/", &Cookie{Name: "d" + strconv.Itoa(i), Value: "4"}, testNow)
+	}
+	if d := j.debugSummary(); len(d.Rejections) != maxRejections || d.Rejections[maxRejections-1].Name != "d"+strconv.Itoa(maxRejections+4) {
+		t.Errorf("kept %d rejections", len(d.Rejections))
+	}
+}
+
+func TestRange(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("https", "a.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("https", "b.com", "/", &Cookie{Name: "b1", Value: "1"}, testNow)
+	j.SetCookie("https", "b.com", "/", &Cookie{Name: "b2", Value: "1"}, testNow)
+	j.SetCookie("https", "c.com", "/", &Cookie{Name: "c", Value: "1"}, testNow)
+
+	// Changes made while ranging don't show through, even to buckets which
+	// have yet to be visited, and calling into the jar doesn't deadlock.
+	var got []string
+	j.Range(func(e Entry) bool {
+		got = append(got, e.Name+"="+e.Value)
+		if e.Name == "a" {
+			j.SetCookie("https", "b.com", "/", &Cookie{Name: "b1", Value: "2"}, testNow)
+			j.Remove("b.com", "/", "b2")
+			j.RemoveByDomain("c.com")
+			j.SetCookie("https", "d.com", "/", &Cookie{Name: "d", Value: "1"}, testNow)
+			j.Entries()
+
+			// Nested iterations see the current state.
+			var nested []string
+			j.Range(func(e Entry) bool {
+				nested = append(nested, e.Name+"="+e.Value)
+				return true
+			})
+			if want := []string{"a=1", "b1=2", "d=1"}; !reflect.DeepEqual(nested, want) {
+				t.Errorf("nested Range visited %v, want %v", nested, want)
+			}
+		}
+		return true
+	})
+
+	if want := []string{"a=1", "b1=1", "b2=1", "c=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Range visited %v, want %v", got, want)
+	}
+
+	// Iterations can be stopped early, and leave nothing behind.
+	var n int
+	j.Range(func(Entry) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Range continued after fn returned false")
+	}
+	if j.iters != nil || j.preserved != nil {
+		t.Errorf("Range left iteration state behind")
+	}
+
+	// Entries moving between buckets are visited once.
+	j = NewJar(PublicSuffixFunc(func(domain string) string {
+		if strings.HasSuffix(domain, ".example.com") {
+			return "example.com"
+		}
+		return testPSL{}.PublicSuffix(domain)
+	}))
+	j.SetCookie("https", "x.example.com", "/", &Cookie{Name: "x", Value: "1"}, testNow)
+	j.SetCookie("https", "y.example.com", "/", &Cookie{Name: "y", Value: "1"}, testNow)
+
+	got = nil
+	j.ReadOnly().Range(func(e Entry) bool {
+		got = append(got, e.Name)
+		j.SetPublicSuffixList(testPSL{})
+		return true
+	})
+	if want := []string{"x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Range visited %v across a public suffix list change, want %v", got, want)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Domain: "example.com"}, testNow)
+
+	if err := j.Label("example.com", "/", "missing", "k", "v"); err == nil {
+		t.Errorf("Label succeeded for missing entry")
+	}
+
+	j.Label(".example.com", "/", "a", "account", "alice")
+	j.Label("example.com", "/", "a", "source", "manual")
+	j.Label("example.com", "/", "a", "source", "")
+
+	want := map[string]string{"account": "alice"}
+	if got := j.Labels("example.com", "/", "a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Labels = %v, want %v", got, want)
+	}
+
+	// Labels survive the cookie being overwritten, and replication.
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "2", Domain: "example.com"}, testNow)
+
+	replica := NewJar(testPSL{})
+	changes, _ := j.ChangesSince(0)
+	replica.Apply(changes)
+
+	if got := replica.Labels("example.com", "/", "a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("replicated Labels = %v, want %v", got, want)
+	}
+
+	if labels, err := decodeLabels(encodeLabels(want)); !reflect.DeepEqual(labels, want) || err != nil {
+		t.Errorf("label encoding round trip returned %v, %v", labels, err)
+	}
+}
+
+func TestEntriesAreCopies(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.KeepUnparsed()
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1", Unparsed: []string{"Priority=High"}}, testNow)
+	j.Label("example.com", "/", "a", "k", "v")
+
+	scribble := func(e Entry) {
+		e.Labels["k"] = "x"
+		e.Unparsed[0] = "x"
+	}
+
+	v := j.ReadOnly()
+	for _, e := range v.Entries() {
+		scribble(e)
+	}
+	entries, _ := v.EntriesFor("http", "example.com", "/", testNow)
+	for _, e := range entries {
+		scribble(e)
+	}
+	changes, _ := j.ChangesSince(0)
+	for _, c := range changes {
+		scribble(c.Entry)
+	}
+
+	e := j.Entries()[0]
+	if e.Labels["k"] != "v" || e.Unparsed[0] != "Priority=High" {
+		t.Errorf("modifying returned entries changed the jar: %+v", e)
+	}
+}
+
+func TestStats(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "s", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "p", Value: "1", MaxAge: 60}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "q", Value: "1", MaxAge: 120}, testNow)
+
+	// Overwriting an entry must not count it twice.
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "p", Value: "2", MaxAge: 30}, testNow)
+
+	tests := []struct {
+		now  time.Time
+		want Stats
+	}{
+		{testNow, Stats{Session: 1, Persistent: 2, Unsent: 3}},
+		{testNow.Add(time.Minute), Stats{Session: 1, Persistent: 1, Expired: 1, Unsent: 3}},
+		{testNow.Add(time.Hour), Stats{Session: 1, Expired: 2, Unsent: 3}},
+	}
+
+	for _, test := range tests {
+		if got := j.ReadOnly().Stats(test.now); got != test.want {
+			t.Errorf("Stats(%v) = %+v, want %+v", test.now, got, test.want)
+		}
+	}
+
+	// Earlier times are answered without disturbing the running counts.
+	for i := len(tests) - 1; i >= 0; i-- {
+		if got := j.ReadOnly().Stats(tests[i].now); got != tests[i].want {
+			t.Errorf("Stats(%v) after later call = %+v, want %+v", tests[i].now, got, tests[i].want)
+		}
+	}
+
+	// Sweeping removes the expired entries.
+	j.Cookies("http", "example.com", "/", testNow.Add(time.Hour))
+	if got, want := j.Stats(testNow.Add(time.Hour)), (Stats{Session: 1}); got != want {
+		t.Errorf("after sweep: Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestSends(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "1", Path: "/admin"}, testNow)
+
+	j.Cookies("http", "example.com", "/", testNow)
+	j.CookieHeader("http", "example.com", "/", testNow)
+	j.CookiesInContext("http", "example.com", "/", RequestContext{}, testNow)
+
+	// Reads through a view don't count, and overwriting keeps the count.
+	j.ReadOnly().Cookies("http", "example.com", "/", testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "2"}, testNow)
+
+	sends := make(map[string]uint64)
+	for _, e := range j.Entries() {
+		sends[e.Name] = e.Sends
+	}
+	if want := map[string]uint64{"a": 3, "b": 0}; !reflect.DeepEqual(sends, want) {
+		t.Errorf("Sends = %v, want %v", sends, want)
+	}
+
+	if got := j.Stats(testNow).Unsent; got != 1 {
+		t.Errorf("Stats().Unsent = %d, want 1", got)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	j := NewJar(testPSL{})
+	j.SetLimits(Limits{PerRoot: 2})
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "b", Value: "1"}, testNow.Add(time.Second))
+
+	before, _ := j.ChangesSince(0)
+
+	tests := []struct {
+		c       *Cookie
+		action  SetAction
+		domain  string
+		evicted []string
+		err     bool
+	}{
+		{&Cookie{Name: "c", Value: "1"}, SetStored, "www.example.com", []string{"a"}, false},
+		{&Cookie{Name: "c", Value: "1", Domain: "example.com"}, SetStored, "example.com", []string{"a"}, false},
+		{&Cookie{Name: "a", Value: "2"}, SetReplaced, "www.example.com", nil, false},
+		{&Cookie{Name: "a", Value: "2", MaxAge: -1}, SetRemoved, "www.example.com", nil, false},
+		{&Cookie{Name: "c", Value: "2", MaxAge: -1}, SetIgnored, "www.example.com", nil, false},
+		{&Cookie{Name: "c", Value: "1", Domain: "other.org"}, SetRejected, "", nil, true},
+	}
+
+	for _, test := range tests {
+		o, err := j.Preview("http", "www.example.com", "/", test.c, testNow.Add(time.Minute))
+		if (err != nil) != test.err {
+			t.Errorf("Preview(%+v) returned error %v", test.c, err)
+		}
+
+		var evicted []string
+		for _, e := range o.Evicted {
+			evicted = append(evicted, e.Name)
+		}
+
+		if o.Action != test.action || o.Entry.Domain != test.domain || !reflect.DeepEqual(evicted, test.evicted) {
+			t.Errorf("Preview(%+v) = %+v, want action %d, domain %q, evicted %q",
+				test.c, o, test.action, test.domain, test.evicted)
+		}
+	}
+
+	if after, _ := j.ChangesSince(0); !reflect.DeepEqual(before, after) {
+		t.Errorf("Preview modified the jar")
+	}
+}
+
+func TestJarRouter(t *testing.T) {
+	persistent := NewJar(testPSL{})
+	ephemeral := NewJar(testPSL{})
+
+	r := NewJarRouter(testPSL{}, ephemeral)
+	r.Route(".Example.com", persistent)
+
+	var _ CookieJar = r
+	var _ CookieJar = persistent.ReadOnly()
+
+	r.SetCookie("http", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Domain: "example.com"}, testNow)
+	r.SetCookie("http", "other.org", "/", &Cookie{Name: "b", Value: "1"}, testNow)
+
+	if n := len(persistent.Entries()); n != 1 {
+		t.Errorf("persistent jar holds %d entries, want 1", n)
+	}
+	if n := len(ephemeral.Entries()); n != 1 {
+		t.Errorf("ephemeral jar holds %d entries, want 1", n)
+	}
+
+	// Cookies set by one host under a root are visible to its siblings.
+	cookies, err := r.Cookies("http", "API.example.com:8080", "/", testNow)
+	if err != nil || len(cookies) != 1 || cookies[0].Name != "a" {
+		t.Errorf("Cookies(api.example.com) = %v, %v", cookies, err)
+	}
+
+	if _, err := r.Cookies("http", "", "/", testNow); err != ErrEmptyHost {
+		t.Errorf("Cookies with empty host returned error %v, want %v", err, ErrEmptyHost)
+	}
+}
+
+func TestClock(t *testing.T) {
+	now := testNow
+
+	j := NewJar(testPSL{})
+	j.SetClock(func() time.Time { return now })
+	j.SetCookieNow("http", "example.com", "/", &Cookie{Name: "a", Value: "1", MaxAge: 60})
+
+	if e := j.Entries()[0]; !e.Created.Equal(testNow) {
+		t.Errorf("entry created at %v, want %v", e.Created, testNow)
+	}
+	if h, _ := j.CookieHeaderNow("http", "example.com", "/"); h != "a=1" {
+		t.Errorf("CookieHeaderNow = %q, want %q", h, "a=1")
+	}
+
+	now = now.Add(time.Minute)
+	if cookies, _ := j.CookiesNow("http", "example.com", "/"); len(cookies) != 0 {
+		t.Errorf("CookiesNow returned %+v after expiry", cookies)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	run := func(seed int64) (names string, changes []Change) {
+		j := NewJar(testPSL{})
+		j.SetSeed(seed)
+		for i := 0; i < 20; i++ {
+			j.SetCookie("http", "example.com", "/", &Cookie{Name: "c" + strconv.Itoa(i), Value: "1", MaxAge: 1 + i%2}, testNow)
+		}
+
+		cookies, _ := j.Cookies("http", "example.com", "/", testNow)
+		for _, c := range cookies {
+			names += c.Name + " "
+		}
+
+		// Sweep the entries which expire after a second.
+		j.Cookies("http", "example.com", "/", testNow.Add(1500*time.Millisecond))
+		changes, _ = j.ChangesSince(20)
+		return names, changes
+	}
+
+	names, changes := run(1)
+	for i := 0; i < 10; i++ {
+		if n, c := run(1); n != names || !reflect.DeepEqual(c, changes) {
+			t.Fatalf("seeded jar not deterministic:\n%s\n%s", n, names)
+		}
+	}
+	if len(changes) != 10 {
+		t.Errorf("sweep produced %d changes, want 10", len(changes))
+	}
+
+	if n, _ := run(2); n == names {
+		t.Errorf("seeds 1 and 2 produced the same order: %s", n)
+	}
+}
+
+func TestPartitioned(t *testing.T) {
+	j := NewJar(testPSL{})
+
+	c := &Cookie{Name: "p", Value: "1", Secure: true, Partitioned: true}
+	embed := RequestContext{CrossSite: true, TopLevelSite: "https://www.news.com/article"}
+
+	if err := j.SetCookieInContext("https", "widget.example.com", "/", embed, c, testNow); err != nil {
+		t.Fatalf("SetCookieInContext: %v", err)
+	}
+	if err := j.SetCookie("https", "widget.example.com", "/", &Cookie{Name: "p", Value: "2", Secure: true, Partitioned: true}, testNow); err != nil {
+		t.Fatalf("SetCookie: %v", err)
+	}
+	if err := j.SetCookie("https", "widget.example.com", "/", &Cookie{Name: "p", Value: "x", Partitioned: true}, testNow); err == nil {
+		t.Errorf("SetCookie accepted a partitioned cookie without Secure")
+	}
+
+	tests := []struct {
+		site string
+		out  string
+	}{
+		{"https://news.com", "p=1"},
+		{"https://blog.news.com:8443", "p=1"},
+		{"http://news.com", ""},
+		{"https://other.org", ""},
+		{"", "p=2"},
+	}
+
+	for _, test := range tests {
+		ctx := RequestContext{TopLevelSite: test.site}
+		cookies, _ := j.CookiesInContext("https", "widget.example.com", "/", ctx, testNow)
+
+		var got []string
+		for _, c := range cookies {
+			got = append(got, c.Name+"="+c.Value)
+		}
+		if out := strings.Join(got, "; "); out != test.out {
+			t.Errorf("CookiesInContext(TopLevelSite: %q) = %q, want %q", test.site, out, test.out)
+		}
+	}
+
+	if h, _ := j.CookieHeader("https", "widget.example.com", "/", testNow); h != "p=2" {
+		t.Errorf("CookieHeader = %q, want %q", h, "p=2")
+	}
+
+	for _, e := range j.Entries() {
+		if want := "https://" + map[string]string{"1": "news.com", "2": "example.com"}[e.Value]; e.Partition != want {
+			t.Errorf("entry %s=%s has partition %q, want %q", e.Name, e.Value, e.Partition, want)
+		}
+		if !e.Cookie().Partitioned {
+			t.Errorf("Cookie() of entry %s=%s isn't partitioned", e.Name, e.Value)
+		}
+	}
+
+	// Previews take the partition into account.
+	update := &Cookie{Name: "p", Value: "3", Secure: true, Partitioned: true}
+	o, err := j.PreviewInContext("https", "widget.example.com", "/", embed, update, testNow)
+	if err != nil || o.Action != SetReplaced || o.Previous == nil || o.Previous.Value != "1" || o.Entry.Partition != "https://news.com" {
+		t.Errorf("PreviewInContext = %+v, %v, want replacement of p=1", o, err)
+	}
+	o, err = j.PreviewInContext("https", "widget.example.com", "/", RequestContext{TopLevelSite: "https://other.org"}, update, testNow)
+	if err != nil || o.Action != SetStored {
+		t.Errorf("PreviewInContext in a new partition = %+v, %v, want %v", o, err, SetStored)
+	}
+
+	// Transactions remove entries of every partition, including those stored
+	// after the removal was queued.
+	tx := j.Begin()
+	tx.Remove("widget.example.com", "/", "p", testNow)
+	j.SetCookieInContext("https", "widget.example.com", "/", RequestContext{TopLevelSite: "https://other.org"}, update, testNow)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if entries := j.Entries(); len(entries) != 0 {
+		t.Errorf("entries left after Tx.Remove: %+v", entries)
+	}
+}
+
+func TestKeepUnparsed(t *testing.T) {
+	c, _ := Parse("a=1; Path=/; Foo=bar; Flag")
+
+	j := NewJar(testPSL{})
+	j.SetCookie("http", "www.example.com", "/", c, testNow)
+	if e := j.Entries()[0]; e.Unparsed != nil {
+		t.Errorf("entry kept unparsed attributes %q by default", e.Unparsed)
+	}
+
+	j.KeepUnparsed()
+	j.SetCookie("http", "www.example.com", "/", c, testNow)
+
+	e := j.Entries()[0]
+	if want := []string{"Foo=bar", "Flag"}; !reflect.DeepEqual(e.Unparsed, want) {
+		t.Errorf("Unparsed = %q, want %q", e.Unparsed, want)
+	}
+	if out, _ := e.Cookie().Marshal(true); out != "a=1; Path=/; Foo=bar; Flag" {
+		t.Errorf("Cookie().Marshal(true) = %q", out)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return testNow }
+
+	j := NewJar(testPSL{})
+	j.KeepUnparsed()
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Domain: "example.com", MaxAge: 3600, Secure: true, SameSite: SameSiteStrict}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "b", Value: "1", Path: "/x", MaxAge: 60, HttpOnly: true, Unparsed: []string{"Foo=bar"}}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "session", Value: "1"}, testNow)
+	j.Label("example.com", "/", "a", "account", "alice")
+	j.Cookies("https", "www.example.com", "/", testNow.Add(time.Minute))
+
+	var b bytes.Buffer
+	if err := j.Save(&b); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saved := b.String()
+
+	loaded, err := LoadJar(&b, testPSL{})
+	if err != nil {
+		t.Fatalf("LoadJar: %v", err)
+	}
+
+	// Send counts aren't saved, but the time of the last send is.
+	var want []Entry
+	for _, e := range j.Entries() {
+		if e.Name != "session" {
+			e.Sends = 0
+			want = append(want, e)
+		}
+	}
+	if want[0].LastUsed.IsZero() {
+		t.Errorf("entry %q wasn't marked as sent", want[0].Name)
+	}
+	if got := loaded.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded entries:")
+		t.Errorf("  got  %+v", got)
+		t.Errorf("  want %+v", want)
+	}
+
+	// Saving is reproducible.
+	b.Reset()
+	loaded.Save(&b)
+	if b.String() != saved {
+		t.Errorf("saving loaded jar produced different output:\n%s\nvs\n%s", b.String(), saved)
+	}
+
+	// Entries which expired in the meantime are dropped.
+	Now = func() time.Time { return testNow.Add(time.Hour / 2) }
+	if loaded, err := LoadJar(strings.NewReader(saved), testPSL{}); err != nil {
+		t.Errorf("LoadJar after expiry: %v", err)
+	} else if n := len(loaded.Entries()); n != 1 {
+		t.Errorf("LoadJar after expiry loaded %d entries, want 1", n)
+	}
+
+	if _, err := LoadJar(strings.NewReader(`{"version":2}`), testPSL{}); err == nil {
+		t.Errorf("LoadJar succeeded for unsupported version")
+	}
+}
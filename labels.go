@@ -0,0 +1,120 @@
+package cookie
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	errNoEntry = errors.New("no such entry")
+)
+
+// Label attaches a label to the entry identified by domain, path and name,
+// replacing any previous value for the key. An empty value removes the label.
+// Labels are meant for bookkeeping by the application (e.g. "account=alice"),
+// are never sent to servers, and survive both SetCookie calls overwriting the
+// entry and persistence through ChangesSince, Apply and SQLStore. If the jar
+// keeps host-only and domain cookies separate, both are labeled.
+func (j *Jar) Label(domain, path, name, key, value string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := j.lookup(domain, path, name)
+	if len(entries) == 0 {
+		return errNoEntry
+	}
+
+	now := j.clockNow()
+
+	for _, prev := range entries {
+		labels := make(map[string]string, len(prev.Labels)+1)
+		for k, v := range prev.Labels {
+			labels[k] = v
+		}
+
+		if value == "" {
+			delete(labels, key)
+		} else {
+			labels[key] = value
+		}
+		if len(labels) == 0 {
+			labels = nil
+		}
+
+		// Entries are shared with history records, so make a copy.
+		entry := *prev
+		entry.Labels = labels
+		j.set(&entry, now)
+	}
+
+	return nil
+}
+
+// Labels returns the labels of the entry identified by domain, path and name.
+// The returned map must not be modified.
+func (j *Jar) Labels(domain, path, name string) map[string]string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	for _, entry := range j.lookup(domain, path, name) {
+		return entry.Labels
+	}
+	return nil
+}
+
+// lookup returns the entries identified by domain, path and name.
+func (j *Jar) lookup(domain, path, name string) []*jarEntry {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	bucket := j.ent[j.root(domain)]
+
+	var entries []*jarEntry
+	for _, hostOnly := range []bool{false, true} {
+		if entry, ok := bucket[j.key(domain, path, name, hostOnly)]; ok {
+			entries = append(entries, entry)
+		}
+		if j.conflict != ConflictKeepBoth {
+			break
+		}
+	}
+
+	return entries
+}
+
+// encodeLabels serializes labels for storage.
+func encodeLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	v := make(url.Values, len(labels))
+	for _, k := range keys {
+		v.Set(k, labels[k])
+	}
+	return v.Encode()
+}
+
+// decodeLabels reverses encodeLabels.
+func decodeLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string, len(v))
+	for k := range v {
+		labels[k] = v.Get(k)
+	}
+	return labels, nil
+}
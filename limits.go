@@ -0,0 +1,260 @@
+package cookie
+
+import (
+	"container/heap"
+	"strconv"
+	"time"
+)
+
+// Limits caps the number of entries held by a jar. RFC 6265, section 6.1,
+// suggests supporting at least 50 cookies per domain and 3000 in total.
+type Limits struct {
+	// Maximum number of entries per domain root. Zero means no limit.
+	PerRoot int
+
+	// Maximum number of entries in the jar. Zero means no limit.
+	Total int
+
+	// Which entries are evicted first when a limit is exceeded.
+	Evict Eviction
+}
+
+// An Eviction determines the order in which a jar evicts entries to stay
+// within its limits.
+type Eviction int
+
+const (
+	// EvictOldest evicts the entries created the longest ago first. This is
+	// the default.
+	EvictOldest Eviction = iota
+
+	// EvictLeastRecentlyUsed evicts the entries sent the longest ago first,
+	// treating entries never sent as last used when they were created.
+	EvictLeastRecentlyUsed
+
+	// EvictEarliestExpiring evicts the entries closest to expiring first,
+	// followed by session cookies, oldest first.
+	EvictEarliestExpiring
+)
+
+// String returns the name of the eviction order, such as "EvictOldest".
+func (e Eviction) String() string {
+	switch e {
+	case EvictOldest:
+		return "EvictOldest"
+	case EvictLeastRecentlyUsed:
+		return "EvictLeastRecentlyUsed"
+	case EvictEarliestExpiring:
+		return "EvictEarliestExpiring"
+	}
+	return "Eviction(" + strconv.Itoa(int(e)) + ")"
+}
+
+// SetLimits limits the number of entries held by the jar. Whenever a new
+// entry would exceed a limit, an entry in the affected domain root (or the
+// entire jar) is chosen according to limits.Evict, evicted, and reported to
+// observers as a removal with its Evicted field set.
+//
+// Existing entries are not evicted until the next entry is stored.
+func (j *Jar) SetLimits(limits Limits) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.limits = limits
+
+	// The eviction heap is only needed to enforce the total limit, and its
+	// order depends on limits.Evict, so rebuild it from scratch.
+	j.evictions = nil
+	if limits.Total > 0 {
+		j.evictions = &evictionHeap{order: limits.Evict, entries: j.sorted()}
+		for i, entry := range j.evictions.entries {
+			entry.evictIndex = i
+		}
+		heap.Init(j.evictions)
+	}
+}
+
+// Pressure returns how close the jar is to its limits, as the highest ratio
+// between the number of entries and the corresponding limit over the jar as a
+// whole and each of its domain roots. A value of 1 means that storing further
+// cookies will cause evictions. Pressure returns 0 if the jar is unlimited.
+func (j *Jar) Pressure() float64 {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.pressure()
+}
+
+// pressure implements Pressure.
+func (j *Jar) pressure() float64 {
+	var p float64
+
+	if j.limits.Total > 0 {
+		p = ratio(j.len(), j.limits.Total)
+	}
+
+	if j.limits.PerRoot > 0 {
+		for _, bucket := range j.ent {
+			if r := ratio(len(bucket), j.limits.PerRoot); r > p {
+				p = r
+			}
+		}
+	}
+
+	return p
+}
+
+// DomainPressure is like Pressure, but only considers the domain root of
+// host, along with the jar as a whole.
+func (j *Jar) DomainPressure(host string) (float64, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return 0, err
+	}
+
+	var p float64
+
+	if j.limits.Total > 0 {
+		p = ratio(j.len(), j.limits.Total)
+	}
+
+	if j.limits.PerRoot > 0 {
+		if r := ratio(len(j.ent[j.root(host)]), j.limits.PerRoot); r > p {
+			p = r
+		}
+	}
+
+	return p, nil
+}
+
+// enforceLimits evicts entries until the jar is within its limits, sparing
+// the entry that was just stored.
+func (j *Jar) enforceLimits(keep *jarEntry, now time.Time) {
+	if j.limits.PerRoot > 0 {
+		for len(j.ent[keep.Root]) > j.limits.PerRoot {
+			j.drop(j.victim(j.ent[keep.Root], keep), true, now)
+		}
+	}
+
+	if j.evictions != nil {
+		for j.len() > j.limits.Total {
+			victim := j.evictions.first(keep)
+			if victim == nil {
+				break
+			}
+			j.drop(victim, true, now)
+		}
+	}
+}
+
+// victim returns the entry in a bucket other than skip which should be
+// evicted first, or nil if there is none.
+func (j *Jar) victim(bucket map[string]*jarEntry, skip *jarEntry) *jarEntry {
+	var v *jarEntry
+	for _, e := range bucket {
+		if e != skip && (v == nil || j.evictsBefore(e, v)) {
+			v = e
+		}
+	}
+	return v
+}
+
+// evictsBefore returns true if a should be evicted before b, according to
+// the jar's eviction order. Ties are broken by age.
+func (j *Jar) evictsBefore(a, b *jarEntry) bool {
+	return evictsBefore(j.limits.Evict, a, b)
+}
+
+// evictsBefore returns true if a should be evicted before b, according to
+// the eviction order. Ties are broken by age.
+func evictsBefore(order Eviction, a, b *jarEntry) bool {
+	switch order {
+	case EvictLeastRecentlyUsed:
+		if ua, ub := a.lastUsed(), b.lastUsed(); !ua.Equal(ub) {
+			return ua.Before(ub)
+		}
+
+	case EvictEarliestExpiring:
+		if !a.Expires.Equal(b.Expires) {
+			switch {
+			case a.Expires.IsZero():
+				return false
+			case b.Expires.IsZero():
+				return true
+			}
+			return a.Expires.Before(b.Expires)
+		}
+	}
+
+	return older(a, b)
+}
+
+// older orders entries by creation time, breaking ties by revision so that
+// evictions are deterministic.
+func older(a, b *jarEntry) bool {
+	if !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	return a.Rev < b.Rev
+}
+
+// evictionHeap implements heap.Interface, ordering all of a jar's entries by
+// the jar's eviction order, so that the total limit can be enforced without
+// visiting every entry. Entries track their position in evictIndex.
+type evictionHeap struct {
+	order   Eviction
+	entries []*jarEntry
+}
+
+func (h *evictionHeap) Len() int { return len(h.entries) }
+func (h *evictionHeap) Less(i, j int) bool {
+	return evictsBefore(h.order, h.entries[i], h.entries[j])
+}
+
+func (h *evictionHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].evictIndex = i
+	h.entries[j].evictIndex = j
+}
+
+func (h *evictionHeap) Push(x interface{}) {
+	entry := x.(*jarEntry)
+	entry.evictIndex = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+func (h *evictionHeap) Pop() interface{} {
+	old := h.entries
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	h.entries = old[:len(old)-1]
+	return entry
+}
+
+// first returns the entry other than skip which should be evicted first, or
+// nil if there is none. If skip is at the top of the heap, the next entry is
+// one of its children.
+func (h *evictionHeap) first(skip *jarEntry) *jarEntry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	if h.entries[0] != skip {
+		return h.entries[0]
+	}
+
+	var v *jarEntry
+	for i := 1; i < len(h.entries) && i <= 2; i++ {
+		if v == nil || h.Less(i, v.evictIndex) {
+			v = h.entries[i]
+		}
+	}
+	return v
+}
+
+// ratio returns n/limit as a float64.
+func ratio(n, limit int) float64 {
+	return float64(n) / float64(limit)
+}
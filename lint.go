@@ -0,0 +1,49 @@
+package cookie
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lintPlaceholders returns an error if the cookie's value, domain or path
+// appears to contain an unsubstituted template placeholder.
+func lintPlaceholders(c *Cookie) error {
+	if hasPlaceholder(c.Value) {
+		return fmt.Errorf("cookie.Marshal: placeholder in cookie value: %q", c.Value)
+	}
+	if hasPlaceholder(c.Domain) {
+		return fmt.Errorf("cookie.Marshal: placeholder in Domain value: %q", c.Domain)
+	}
+	if hasPlaceholder(c.Path) {
+		return fmt.Errorf("cookie.Marshal: placeholder in Path value: %q", c.Path)
+	}
+	return nil
+}
+
+// hasPlaceholder returns true if s contains "${...}", "{{...}}" or a printf
+// verb. To avoid confusion with percent-encoding, "%d" is only considered a
+// verb if it isn't followed by a hexadecimal digit.
+func hasPlaceholder(s string) bool {
+	if i := strings.Index(s, "${"); i >= 0 && strings.IndexByte(s[i+2:], '}') >= 0 {
+		return true
+	}
+	if i := strings.Index(s, "{{"); i >= 0 && strings.Index(s[i+2:], "}}") >= 0 {
+		return true
+	}
+
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		switch s[i+1] {
+		case 's', 'v', 'q':
+			return true
+		case 'd':
+			if i+2 == len(s) || unhex(s[i+2]) < 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package cookie
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// A Redactor renders cookies for logging with their values hidden. Cookies
+// don't format themselves that way, since silently changing what %v prints
+// would surprise code relying on it; wrap them explicitly instead:
+//
+//	log.Printf("setting %v", redactor.Cookie(c))
+//
+// Values are replaced by an HMAC keyed with Key, so that reuse of a cookie
+// can be correlated across log lines without logging the value itself, nor
+// a plain hash of it, which could be reversed by guessing short or
+// predictable values. Anyone holding the key can still test guesses, so it
+// should be kept as secret as the values.
+type Redactor struct {
+	// Key used to hash values. If empty, values are replaced by
+	// "[redacted]" instead.
+	Key []byte
+}
+
+// NewRedactor returns a Redactor hashing values with key.
+func NewRedactor(key []byte) *Redactor {
+	return &Redactor{Key: key}
+}
+
+// HashValue returns a stable, truncated HMAC of a cookie value, such as
+// "hmac:2c26b46b68ffc68f", or "[redacted]" if the redactor has no key. The
+// empty string is returned as is.
+func (r *Redactor) HashValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(r.Key) == 0 {
+		return "[redacted]"
+	}
+
+	mac := hmac.New(sha256.New, r.Key)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Cookie wraps a cookie for logging.
+func (r *Redactor) Cookie(c *Cookie) RedactedCookie {
+	return RedactedCookie{c, r}
+}
+
+// A RedactedCookie is a cookie wrapped by Redactor.Cookie. It implements
+// fmt.Stringer and, on Go 1.21 and later, slog.LogValuer.
+type RedactedCookie struct {
+	c *Cookie
+	r *Redactor
+}
+
+// String renders the cookie in the format of a "Set-Cookie" header, but with
+// its value replaced by HashValue and its Unparsed attributes omitted. Unlike
+// Marshal, it never fails.
+func (rc RedactedCookie) String() string {
+	c := rc.c
+	if c == nil {
+		return "<nil>"
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	b.WriteString(rc.r.HashValue(c.Value))
+
+	if c.Domain != "" {
+		b.WriteString("; Domain=")
+		b.WriteString(c.Domain)
+	}
+	if c.Path != "" {
+		b.WriteString("; Path=")
+		b.WriteString(c.Path)
+	}
+	if c.Expires.Unix() > 0 {
+		b.WriteString("; Expires=")
+		b.WriteString(c.Expires.UTC().Format(time.RFC1123))
+	}
+	if c.MaxAge != 0 {
+		b.WriteString("; Max-Age=")
+		b.WriteString(strconv.Itoa(c.MaxAge))
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.SameSite != SameSiteDefault {
+		b.WriteString("; SameSite=")
+		b.WriteString(c.SameSite.String())
+	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,47 @@
+//go:build go1.21
+
+package cookie
+
+import (
+	"log/slog"
+)
+
+// LogValue implements slog.LogValuer, rendering the cookie as a group of
+// attributes with its value replaced by HashValue.
+func (rc RedactedCookie) LogValue() slog.Value {
+	c := rc.c
+	if c == nil {
+		return slog.StringValue("<nil>")
+	}
+
+	attrs := []slog.Attr{
+		slog.String("name", c.Name),
+		slog.String("value", rc.r.HashValue(c.Value)),
+	}
+	if c.Domain != "" {
+		attrs = append(attrs, slog.String("domain", c.Domain))
+	}
+	if c.Path != "" {
+		attrs = append(attrs, slog.String("path", c.Path))
+	}
+	if c.Expires.Unix() > 0 {
+		attrs = append(attrs, slog.Time("expires", c.Expires))
+	}
+	if c.MaxAge != 0 {
+		attrs = append(attrs, slog.Int("max_age", c.MaxAge))
+	}
+	if c.HttpOnly {
+		attrs = append(attrs, slog.Bool("http_only", true))
+	}
+	if c.Secure {
+		attrs = append(attrs, slog.Bool("secure", true))
+	}
+	if c.SameSite != SameSiteDefault {
+		attrs = append(attrs, slog.String("same_site", c.SameSite.String()))
+	}
+	if c.Partitioned {
+		attrs = append(attrs, slog.Bool("partitioned", true))
+	}
+
+	return slog.GroupValue(attrs...)
+}
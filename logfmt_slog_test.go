@@ -0,0 +1,35 @@
+//go:build go1.21
+
+package cookie
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogValue(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(b, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	r := NewRedactor([]byte("k"))
+	logger.Info("set", "cookie", r.Cookie(&Cookie{Name: "sid", Value: "foo", Path: "/", Secure: true}))
+
+	want := "level=INFO msg=set cookie.name=sid cookie.value=hmac:dc9652dbf73f8c8e cookie.path=/ cookie.secure=true\n"
+	if out := b.String(); out != want {
+		t.Errorf("log output:")
+		t.Errorf("  got  %#q", out)
+		t.Errorf("  want %#q", want)
+	}
+	if strings.Contains(b.String(), "foo") {
+		t.Errorf("log output contains cookie value")
+	}
+}
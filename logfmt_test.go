@@ -0,0 +1,62 @@
+package cookie
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stringTests = []struct {
+	in  *Cookie
+	out string
+}{
+	{nil, "<nil>"},
+	{&Cookie{Name: "a"}, "a="},
+	{&Cookie{Name: "a", Value: "foo"}, "a=hmac:dc9652dbf73f8c8e"},
+	{
+		&Cookie{
+			Name:     "sid",
+			Value:    "foo",
+			Domain:   "example.com",
+			Path:     "/",
+			Expires:  time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+			MaxAge:   60,
+			HttpOnly: true,
+			Secure:   true,
+			Unparsed: []string{"secret=1"},
+		},
+		"sid=hmac:dc9652dbf73f8c8e; Domain=example.com; Path=/; " +
+			"Expires=Thu, 01 Jan 2015 00:00:00 UTC; Max-Age=60; HttpOnly; Secure",
+	},
+
+	// Invalid cookies are still rendered.
+	{&Cookie{Name: "a b", Value: "\x00"}, "a b=hmac:679f05c0f197b486"},
+}
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor([]byte("k"))
+
+	for _, test := range stringTests {
+		if out := r.Cookie(test.in).String(); out != test.out {
+			t.Errorf("String():")
+			t.Errorf("  got  %#q", out)
+			t.Errorf("  want %#q", test.out)
+		}
+	}
+
+	// Redactors with different keys hash values differently, and those
+	// without one don't hash them at all.
+	c := &Cookie{Name: "a", Value: "foo"}
+	if NewRedactor([]byte("other")).HashValue(c.Value) == r.HashValue(c.Value) {
+		t.Errorf("HashValue ignored the key")
+	}
+	if out := (&Redactor{}).Cookie(c).String(); out != "a=[redacted]" {
+		t.Errorf("String() without a key = %#q", out)
+	}
+
+	// Cookies themselves format as usual.
+	if out := fmt.Sprintf("%v", c); !strings.Contains(out, "foo") {
+		t.Errorf("%%v of a cookie = %#q", out)
+	}
+}
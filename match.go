@@ -0,0 +1,81 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoCommonDomain is returned by SuggestDomain when no Domain attribute
+// covers all of the given hosts.
+var ErrNoCommonDomain = errors.New("no domain covers all hosts")
+
+// DomainHasSuffix returns true if host is equal to suffix, or is a subdomain
+// of it, comparing labels case-insensitively. Unlike a plain string suffix
+// check, "badexample.com" doesn't have the suffix "example.com". Both
+// arguments should be in ASCII (punycode) form, without trailing dots.
+func DomainHasSuffix(host, suffix string) bool {
+	if len(host) == len(suffix) {
+		return strings.EqualFold(host, suffix)
+	}
+	return len(host) > len(suffix) && host[len(host)-len(suffix)-1] == '.' &&
+		strings.EqualFold(host[len(host)-len(suffix):], suffix)
+}
+
+// DomainMatch returns true if host domain-matches domain, as defined in RFC
+// 6265, section 5.1.3: either they're identical, or domain is a suffix of a
+// host name (not an IP address) and is preceded by a dot. Unlike the RFC's
+// definition, the comparison is case-insensitive, and a single leading dot
+// in domain is ignored.
+func DomainMatch(host, domain string) bool {
+	domain = strings.TrimPrefix(domain, ".")
+	if isIP(host) {
+		return host == domain
+	}
+	return DomainHasSuffix(host, domain)
+}
+
+// SuggestDomain returns the narrowest Domain attribute with which a cookie set
+// by setterHost would be sent to each of the target hosts. An empty string is
+// returned if every target is setterHost itself, in which case the Domain
+// attribute should be omitted. ErrNoCommonDomain is returned if the hosts
+// share no domain, if that domain is a public suffix according to psl, or if
+// they are distinct IP addresses.
+func SuggestDomain(setterHost string, targets []string, psl PublicSuffixList) (string, error) {
+	setter, err := CanonicalHost(setterHost)
+	if err != nil {
+		return "", err
+	}
+
+	domain, hostOnly := setter, true
+	for _, target := range targets {
+		host, err := CanonicalHost(target)
+		if err != nil {
+			return "", err
+		}
+		if host == setter {
+			continue
+		}
+		if isIP(host) || isIP(setter) {
+			return "", ErrNoCommonDomain
+		}
+		hostOnly = false
+
+		for !DomainHasSuffix(host, domain) {
+			i := strings.IndexByte(domain, '.')
+			if i < 0 {
+				return "", ErrNoCommonDomain
+			}
+			domain = domain[i+1:]
+		}
+	}
+
+	if hostOnly {
+		return "", nil
+	}
+
+	if psl != nil && psl.PublicSuffix(domain) == domain {
+		return "", ErrNoCommonDomain
+	}
+
+	return domain, nil
+}
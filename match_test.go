@@ -0,0 +1,83 @@
+package cookie
+
+import (
+	"testing"
+)
+
+var domainMatchTests = []struct {
+	host, domain string
+	suffix       bool
+	match        bool
+}{
+	{"example.com", "example.com", true, true},
+	{"www.example.com", "example.com", true, true},
+	{"www.example.com", ".example.com", false, true},
+	{"WWW.Example.com", "example.COM", true, true},
+	{"badexample.com", "example.com", false, false},
+	{"example.com", "www.example.com", false, false},
+	{"example.com", "", false, false},
+	{"1.2.3.4", "2.3.4", true, false},
+	{"1.2.3.4", "1.2.3.4", true, true},
+	{"::1", "::1", true, true},
+}
+
+func TestDomainMatch(t *testing.T) {
+	for _, test := range domainMatchTests {
+		if got := DomainHasSuffix(test.host, test.domain); got != test.suffix {
+			t.Errorf("DomainHasSuffix(%q, %q) = %v, want %v", test.host, test.domain, got, test.suffix)
+		}
+		if got := DomainMatch(test.host, test.domain); got != test.match {
+			t.Errorf("DomainMatch(%q, %q) = %v, want %v", test.host, test.domain, got, test.match)
+		}
+	}
+}
+
+var pathMatchTests = []struct {
+	path, cookiePath string
+	match            bool
+}{
+	{"/", "/", true},
+	{"/foo", "/", true},
+	{"/foo", "/foo", true},
+	{"/foo/bar", "/foo", true},
+	{"/foo/bar", "/foo/", true},
+	{"/foobar", "/foo", false},
+	{"/fo", "/foo", false},
+	{"/", "", false},
+	{"", "", true},
+}
+
+func TestPathMatch(t *testing.T) {
+	for _, test := range pathMatchTests {
+		if got := PathMatch(test.path, test.cookiePath); got != test.match {
+			t.Errorf("PathMatch(%q, %q) = %v, want %v", test.path, test.cookiePath, got, test.match)
+		}
+	}
+}
+
+var suggestDomainTests = []struct {
+	setter  string
+	targets []string
+	domain  string
+	err     error
+}{
+	{"www.example.com", nil, "", nil},
+	{"www.example.com", []string{"WWW.example.com:8080"}, "", nil},
+	{"www.example.com", []string{"api.example.com"}, "example.com", nil},
+	{"a.b.example.com", []string{"c.b.example.com", "b.example.com"}, "b.example.com", nil},
+	{"example.com", []string{"www.example.com"}, "example.com", nil},
+	{"www.example.com", []string{"example.org"}, "", ErrNoCommonDomain},
+	{"example.com", []string{"other.com"}, "", ErrNoCommonDomain},
+	{"1.2.3.4", []string{"1.2.3.5"}, "", ErrNoCommonDomain},
+	{"www.example.com", []string{"user@example.com"}, "", ErrUserinfo},
+}
+
+func TestSuggestDomain(t *testing.T) {
+	for _, test := range suggestDomainTests {
+		domain, err := SuggestDomain(test.setter, test.targets, testPSL{})
+		if domain != test.domain || err != test.err {
+			t.Errorf("SuggestDomain(%q, %q) = %q, %v, want %q, %v",
+				test.setter, test.targets, domain, err, test.domain, test.err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package cookie
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A Namespace prefixes the names of an application's cookies, such as "sid"
+// becoming "app1__sid", so that several applications served from the same
+// domain don't clobber each other's cookies. Names keep their "__Secure-" or
+// "__Host-" prefix in front, as browsers require, so "__Host-sid" becomes
+// "__Host-app1__sid".
+//
+// A Namespace can be applied at the jar boundary, using Jar, or at the HTTP
+// boundary, using Handler.
+type Namespace struct {
+	// Prefix added to cookie names, such as "app1__".
+	Prefix string
+
+	// Warn, if non-nil, is called with a description of the problem every
+	// time a possible collision between names is detected: a name which
+	// already carries the prefix being prefixed again, or a request
+	// carrying both a namespaced cookie and an unprefixed cookie with the
+	// same name, which another application may have set.
+	Warn func(err error)
+}
+
+// NewNamespace returns a Namespace prefixing names with app followed by two
+// underscores.
+func NewNamespace(app string) *Namespace {
+	return &Namespace{Prefix: app + "__"}
+}
+
+// Name returns the namespaced form of a cookie name.
+func (ns *Namespace) Name(name string) string {
+	special, rest := splitNamePrefix(name)
+	if strings.HasPrefix(rest, ns.Prefix) {
+		ns.warn(fmt.Errorf("cookie: name %q already carries namespace prefix %q", name, ns.Prefix))
+	}
+	return special + ns.Prefix + rest
+}
+
+// Unprefix returns the name a namespaced cookie name was derived from. The
+// second return value is false if the name doesn't belong to the namespace.
+func (ns *Namespace) Unprefix(name string) (string, bool) {
+	special, rest := splitNamePrefix(name)
+	if !strings.HasPrefix(rest, ns.Prefix) {
+		return "", false
+	}
+	return special + rest[len(ns.Prefix):], true
+}
+
+// Wrap returns a copy of a cookie with its name namespaced.
+func (ns *Namespace) Wrap(c *Cookie) *Cookie {
+	w := *c
+	w.Name = ns.Name(c.Name)
+	return &w
+}
+
+// Unwrap returns a copy of a namespaced cookie with its original name. The
+// second return value is false if the cookie doesn't belong to the
+// namespace.
+func (ns *Namespace) Unwrap(c *Cookie) (*Cookie, bool) {
+	name, ok := ns.Unprefix(c.Name)
+	if !ok {
+		return nil, false
+	}
+	u := *c
+	u.Name = name
+	return &u, true
+}
+
+// Filter returns the cookies of a request which belong to the namespace,
+// with their original names, in order. Unprefixed cookies sharing a name
+// with one of them are reported through Warn.
+func (ns *Namespace) Filter(m *CookieMap) *CookieMap {
+	out := &CookieMap{}
+	for _, p := range m.pairs {
+		if name, ok := ns.Unprefix(p.name); ok {
+			out.Add(name, p.value)
+		}
+	}
+
+	if ns.Warn != nil {
+		for _, p := range m.pairs {
+			if _, ok := ns.Unprefix(p.name); !ok && out.Has(p.name) {
+				ns.warn(fmt.Errorf("cookie: unprefixed cookie %q collides with namespace %q", p.name, ns.Prefix))
+			}
+		}
+	}
+
+	return out
+}
+
+// Jar returns a CookieJar storing cookies in j under namespaced names, and
+// returning only the namespace's cookies, with their original names.
+func (ns *Namespace) Jar(j CookieJar) CookieJar {
+	return &namespacedJar{ns, j}
+}
+
+// namespacedJar implements Namespace.Jar.
+type namespacedJar struct {
+	ns *Namespace
+	j  CookieJar
+}
+
+// Cookies implements CookieJar.
+func (n *namespacedJar) Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error) {
+	cookies, err := n.j.Cookies(scheme, host, path, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Cookie
+	for _, c := range cookies {
+		if u, ok := n.ns.Unwrap(c); ok {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+// SetCookie implements CookieJar.
+func (n *namespacedJar) SetCookie(scheme, host, path string, c *Cookie, now time.Time) error {
+	return n.j.SetCookie(scheme, host, path, n.ns.Wrap(c), now)
+}
+
+// Handler returns an http.Handler which passes requests on to next with
+// their "Cookie" headers reduced to the namespace's cookies, under their
+// original names, and namespaces the names of cookies set by next through
+// "Set-Cookie" headers. The application behind it can thus use plain names.
+func (ns *Namespace) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.WithContext(r.Context())
+		r2.Header = r.Header.Clone()
+		r2.Header.Del("Cookie")
+
+		m := ParseRequestHeader(strings.Join(r.Header["Cookie"], "; "))
+		if header := ns.Filter(m).Encode(); header != "" {
+			r2.Header.Set("Cookie", header)
+		}
+
+		// Cookies parsed by WithParsed upstream no longer match the header.
+		if _, err := FromContext(r.Context()); err == nil {
+			r2 = r2.WithContext(WithParsed(r2.Context(), r2))
+		}
+
+		nw := &headerRewriter{ResponseWriter: w, rewrite: ns.rewrite}
+		next.ServeHTTP(nw, r2)
+
+		// Handlers writing nothing leave the headers to be written after
+		// they return.
+		nw.before()
+	})
+}
+
+// rewrite namespaces the names of the cookies in "Set-Cookie" headers.
+func (ns *Namespace) rewrite(header http.Header) {
+	headers := header["Set-Cookie"]
+	for i, h := range headers {
+		eq := strings.IndexByte(h, '=')
+		if eq < 0 {
+			continue
+		}
+		if name := trim(h[:eq]); isValidName(name) {
+			headers[i] = ns.Name(name) + h[eq:]
+		}
+	}
+}
+
+// warn reports a possible collision.
+func (ns *Namespace) warn(err error) {
+	if ns.Warn != nil {
+		ns.Warn(err)
+	}
+}
+
+// splitNamePrefix splits a cookie name into its "__Secure-" or "__Host-"
+// prefix, if any, and the rest of the name.
+func splitNamePrefix(name string) (string, string) {
+	for _, prefix := range []string{"__Secure-", "__Host-"} {
+		if hasPrefixFold(name, prefix) {
+			return name[:len(prefix)], name[len(prefix):]
+		}
+	}
+	return "", name
+}
@@ -0,0 +1,159 @@
+package cookie
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var namespaceTests = []struct {
+	in  string
+	out string
+}{
+	{"sid", "app1__sid"},
+	{"__Host-sid", "__Host-app1__sid"},
+	{"__secure-sid", "__secure-app1__sid"},
+}
+
+func TestNamespace(t *testing.T) {
+	var warnings int
+	ns := NewNamespace("app1")
+	ns.Warn = func(error) { warnings++ }
+
+	for _, test := range namespaceTests {
+		if out := ns.Name(test.in); out != test.out {
+			t.Errorf("Name(%q) = %q, want %q", test.in, out, test.out)
+		}
+		if in, ok := ns.Unprefix(test.out); in != test.in || !ok {
+			t.Errorf("Unprefix(%q) = %q, %v, want %q, true", test.out, in, ok, test.in)
+		}
+	}
+	if _, ok := ns.Unprefix("app2__sid"); ok {
+		t.Errorf("Unprefix accepted a name from another namespace")
+	}
+	if warnings != 0 {
+		t.Errorf("%d unexpected warnings", warnings)
+	}
+
+	ns.Name("app1__sid")
+	if warnings != 1 {
+		t.Errorf("prefixing a prefixed name caused %d warnings, want 1", warnings)
+	}
+
+	m := ParseRequestHeader("app1__sid=1; app2__sid=2; sid=3; app1__theme=dark")
+	if got, want := ns.Filter(m).Encode(), "sid=1; theme=dark"; got != want {
+		t.Errorf("Filter = %#q, want %#q", got, want)
+	}
+	if warnings != 2 {
+		t.Errorf("colliding unprefixed cookie caused %d warnings, want 1", warnings-1)
+	}
+}
+
+func TestNamespaceJar(t *testing.T) {
+	j := NewJar(testPSL{})
+	app1 := NewNamespace("app1").Jar(j)
+	app2 := NewNamespace("app2").Jar(j)
+
+	app1.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "1"}, testNow)
+	app2.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "2"}, testNow)
+
+	cookies, _ := app2.Cookies("http", "example.com", "/", testNow)
+	if want := []*Cookie{{Name: "sid", Value: "2"}}; !reflect.DeepEqual(cookies, want) {
+		t.Errorf("app2 Cookies = %+v, want %+v", cookies, want)
+	}
+	if header, _ := j.CookieHeader("http", "example.com", "/", testNow); header != "app1__sid=1; app2__sid=2" {
+		t.Errorf("CookieHeader = %#q", header)
+	}
+}
+
+func TestNamespaceHandler(t *testing.T) {
+	h := NewNamespace("app1").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Cookie"); got != "sid=1" {
+			t.Errorf("handler received Cookie header %#q", got)
+		}
+		w.Header().Add("Set-Cookie", "sid=2; Path=/")
+		w.Header().Add("Set-Cookie", "__Host-csrf=x; Secure; Path=/")
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "app1__sid=1; app2__sid=2")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	// Cookies parsed upstream are replaced by the namespace's own.
+	parsed := ParseRequest(NewNamespace("app1").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m, _ := FromContext(r.Context()); m.Encode() != "sid=1" {
+			t.Errorf("FromContext in namespaced handler = %#q, want %#q", m.Encode(), "sid=1")
+		}
+	})))
+	parsed.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := []string{"app1__sid=2; Path=/", "__Host-app1__csrf=x; Secure; Path=/"}
+	if got := w.Result().Header["Set-Cookie"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Set-Cookie headers = %q, want %q", got, want)
+	}
+	if got := r.Header.Get("Cookie"); got != "app1__sid=1; app2__sid=2" {
+		t.Errorf("original request modified: %#q", got)
+	}
+}
+
+func TestNamespaceHandlerMalformed(t *testing.T) {
+	h := NewNamespace("app1").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Cookie"); got != "sid=1; theme=dark" {
+			t.Errorf("handler received Cookie header %#q", got)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "app1__sid=1; junk; app1__theme=dark")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+// hijackRecorder is a ResponseRecorder supporting Hijack, which records the
+// "Set-Cookie" headers at the time of the call.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked []string
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = append([]string{}, w.Header()["Set-Cookie"]...)
+	return nil, nil, nil
+}
+
+func TestNamespaceHandlerController(t *testing.T) {
+	ns := NewNamespace("app1")
+	want := []string{"app1__sid=2; Path=/"}
+
+	// Flushing through a ResponseController writes rewritten headers.
+	h := ns.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "sid=2; Path=/")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if got := w.Result().Header["Set-Cookie"]; !w.Flushed || !reflect.DeepEqual(got, want) {
+		t.Errorf("Set-Cookie headers after Flush = %q, want %q", got, want)
+	}
+
+	// So does hijacking the connection.
+	h = ns.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "sid=2; Path=/")
+		http.NewResponseController(w).Hijack()
+	}))
+
+	hw := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(hw, httptest.NewRequest("GET", "/", nil))
+
+	if !reflect.DeepEqual(hw.hijacked, want) {
+		t.Errorf("Set-Cookie headers at Hijack = %q, want %q", hw.hijacked, want)
+	}
+}
@@ -0,0 +1,179 @@
+package cookie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netscapeHeader is the first line of a cookies.txt file, which some tools
+// require to recognize the format.
+const netscapeHeader = "# Netscape HTTP Cookie File\n"
+
+// netscapeHttpOnly is the prefix used by curl to mark HttpOnly cookies, which
+// the original format can't represent, without older readers choking on them.
+const netscapeHttpOnly = "#HttpOnly_"
+
+// ExportNetscape writes the jar's unexpired entries to w in the tab-separated
+// Netscape cookies.txt format used by curl, wget and youtube-dl. Session
+// cookies are written with an expiry time of 0, and HttpOnly cookies are
+// marked as curl does. Partitioned entries are left out, as the format can't
+// represent them, as are SameSite restrictions. Expiry is judged using the
+// jar's clock (see SetClock).
+func (j *Jar) ExportNetscape(w io.Writer) error {
+	now := j.now()
+
+	var b strings.Builder
+	b.WriteString(netscapeHeader)
+
+	j.mu.RLock()
+	for _, entry := range j.sorted() {
+		if !entry.Expires.IsZero() && !entry.Expires.After(now) || entry.Partition != "" {
+			continue
+		}
+
+		if entry.HttpOnly {
+			b.WriteString(netscapeHttpOnly)
+		}
+		if !entry.HostOnly {
+			b.WriteByte('.')
+		}
+
+		var expires int64
+		if !entry.Expires.IsZero() {
+			expires = entry.Expires.Unix()
+		}
+
+		b.WriteString(entry.Domain)
+		b.WriteString("\t" + netscapeBool(!entry.HostOnly))
+		b.WriteString("\t" + entry.Path)
+		b.WriteString("\t" + netscapeBool(entry.Secure))
+		b.WriteString("\t" + strconv.FormatInt(expires, 10))
+		b.WriteString("\t" + entry.Name)
+		b.WriteString("\t" + entry.Value + "\n")
+	}
+	j.mu.RUnlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ImportNetscape adds the cookies in a Netscape cookies.txt file, as written
+// by ExportNetscape, curl or wget, to the jar. Comments, blank lines and
+// expired cookies are ignored. Cookies are validated as described for Import,
+// so that lines scoping a cookie to a public suffix, such as ".com", are
+// rejected. Cookies are only added if all lines are valid.
+// Expiry is judged using the jar's clock (see SetClock).
+func (j *Jar) ImportNetscape(r io.Reader) error {
+	now := j.now()
+
+	var entries []Entry
+	var n int
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		n++
+
+		entry, err := parseNetscapeLine(s.Text(), now)
+		if err == nil && entry != nil {
+			err = j.checkImport(entry, now)
+		}
+		if err != nil {
+			return fmt.Errorf("cookie: cookies.txt line %d: %v", n, err)
+		}
+		if entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	_, err := j.Import(entries, nil)
+	return err
+}
+
+// parseNetscapeLine parses a line of a cookies.txt file. It returns a nil
+// entry for comments, blank lines and expired cookies.
+func parseNetscapeLine(line string, now time.Time) (*Entry, error) {
+	line = strings.TrimRight(line, "\r")
+
+	var httpOnly bool
+	if strings.HasPrefix(line, netscapeHttpOnly) {
+		line, httpOnly = line[len(netscapeHttpOnly):], true
+	} else if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+
+	// Some writers leave out the value of cookies which have none.
+	fields := strings.Split(line, "\t")
+	if len(fields) == 6 {
+		fields = append(fields, "")
+	}
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+
+	subdomains, ok1 := parseNetscapeBool(fields[1])
+	secure, ok2 := parseNetscapeBool(fields[3])
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if !ok1 || !ok2 || err != nil {
+		return nil, errInvalidEntry
+	}
+
+	name, value := fields[5], fields[6]
+	if !isValidName(name) || value != "" && !isValidValue(value) {
+		return nil, errInvalidEntry
+	}
+
+	domain, err := CanonicalHost(strings.TrimPrefix(fields[0], "."))
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Created:  now,
+		HostOnly: !subdomains,
+		Name:     name,
+		Value:    value,
+		Domain:   domain,
+		Path:     fields[2],
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	}
+
+	if entry.Path == "" || entry.Path[0] != '/' {
+		entry.Path = "/"
+	}
+
+	if expires > 0 {
+		entry.Expires = time.Unix(expires, 0).UTC()
+		if !entry.Expires.After(now) {
+			return nil, nil
+		}
+	}
+
+	return entry, nil
+}
+
+// netscapeBool formats a boolean field of a cookies.txt file.
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// parseNetscapeBool parses a boolean field of a cookies.txt file.
+func parseNetscapeBool(s string) (bool, bool) {
+	switch {
+	case strings.EqualFold(s, "TRUE"):
+		return true, true
+	case strings.EqualFold(s, "FALSE"):
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,196 @@
+package cookie
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrOversize is returned by MarshalWithOptions when a cookie exceeds the
+// limit set by MarshalOptions.MaxSize, and can't be made to fit.
+var ErrOversize = errors.New("cookie.Marshal: cookie exceeds size limit")
+
+// An OversizeAction tells MarshalWithOptions how to handle a cookie exceeding
+// MarshalOptions.MaxSize.
+type OversizeAction int
+
+const (
+	// OversizeReject makes MarshalWithOptions return ErrOversize.
+	OversizeReject OversizeAction = iota
+
+	// OversizeTruncate shortens the cookie's value until the cookie fits,
+	// failing with ErrOversize if that isn't possible.
+	OversizeTruncate
+
+	// OversizeAllow emits the cookie regardless of its size.
+	OversizeAllow
+
+	// OversizeChunk splits the cookie into several, as described for
+	// MarshalChunks. Since that requires more than one header, it is only
+	// honored by MarshalChunks, and makes MarshalWithOptions return
+	// ErrOversize.
+	OversizeChunk
+)
+
+// MarshalChunks is like MarshalWithOptions, but returns a list of headers.
+// When a cookie exceeds MaxSize and OnOversize returns OversizeChunk, it is
+// split into cookies named "name~0", "name~1" and so on, each carrying the
+// original attributes and a part of the value, and fitting within MaxSize.
+// The first chunk's value is prefixed with the number of chunks, followed by
+// a '~', so that chunks left over from a longer value can be told apart.
+// Use JoinChunks to reassemble the value.
+//
+// Since removing a chunked cookie requires removing each chunk, chunking is
+// best combined with MaxAge or Expires.
+func (c *Cookie) MarshalChunks(attrs bool, opts *MarshalOptions) ([]string, error) {
+	s, err := c.marshal(attrs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil || opts.MaxSize <= 0 || len(s) <= opts.MaxSize {
+		return []string{s}, nil
+	}
+
+	action := c.oversizeAction(len(s), opts)
+	if action == OversizeChunk {
+		return c.marshalChunks(attrs, opts)
+	}
+
+	if s, err = c.marshalOversize(s, action, attrs, opts); err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// JoinChunks returns the value of the cookie with the given name, which may
+// have been split by MarshalChunks. If there is no cookie by that name, the
+// value is reassembled from its chunks. It returns false if neither the
+// cookie nor all of its chunks are present.
+func JoinChunks(cookies []*Cookie, name string) (string, bool) {
+	byName := make(map[string]string)
+	for _, c := range cookies {
+		if c.Name == name {
+			return c.Value, true
+		}
+		if _, ok := byName[c.Name]; !ok {
+			byName[c.Name] = c.Value
+		}
+	}
+
+	first, ok := byName[chunkName(name, 0)]
+	if !ok {
+		return "", false
+	}
+
+	i := strings.IndexByte(first, '~')
+	if i < 0 {
+		return "", false
+	}
+	n, err := strconv.Atoi(first[:i])
+	if err != nil || n < 1 {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(first[i+1:])
+
+	for k := 1; k < n; k++ {
+		part, ok := byName[chunkName(name, k)]
+		if !ok {
+			return "", false
+		}
+		b.WriteString(part)
+	}
+
+	return b.String(), true
+}
+
+// chunkName returns the name of a cookie's i-th chunk.
+func chunkName(name string, i int) string {
+	return name + "~" + strconv.Itoa(i)
+}
+
+// oversizeAction returns the action to take for a cookie which marshaled to
+// n bytes, exceeding the size limit.
+func (c *Cookie) oversizeAction(n int, opts *MarshalOptions) OversizeAction {
+	if opts.OnOversize == nil {
+		return OversizeReject
+	}
+	return opts.OnOversize(c, n)
+}
+
+// marshalOversize handles a cookie which marshaled to s, exceeding the size
+// limit.
+func (c *Cookie) marshalOversize(s string, action OversizeAction, attrs bool, opts *MarshalOptions) (string, error) {
+	switch action {
+	case OversizeAllow:
+		return s, nil
+
+	case OversizeTruncate:
+		excess := len(s) - opts.MaxSize
+		if excess >= len(c.Value) {
+			break
+		}
+
+		t := *c
+		t.Value = c.Value[:len(c.Value)-excess]
+
+		// Truncation may expose a character which requires quoting.
+		if s, err := t.marshal(attrs, opts); err == nil && len(s) <= opts.MaxSize {
+			return s, nil
+		}
+	}
+
+	return "", ErrOversize
+}
+
+// marshalChunks splits a cookie exceeding the size limit into chunks.
+func (c *Cookie) marshalChunks(attrs bool, opts *MarshalOptions) ([]string, error) {
+	t := *c
+
+	for n := 2; ; n++ {
+		// Every chunk has room for as much of the value as the last one,
+		// whose index is the longest, less the count prefixed to the first.
+		t.Name, t.Value = chunkName(c.Name, n-1), strconv.Itoa(n)+"~"
+		empty, err := t.marshal(attrs, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		room := opts.MaxSize - len(empty)
+		if room <= 0 {
+			return nil, ErrOversize
+		}
+		if n*room < len(c.Value) {
+			continue
+		}
+
+		chunks := make([]string, 0, n)
+		value := c.Value
+
+		for i := 0; i < n; i++ {
+			part := value
+			if len(part) > room {
+				part = part[:room]
+			}
+			value = value[len(part):]
+
+			t.Name, t.Value = chunkName(c.Name, i), part
+			if i == 0 {
+				t.Value = strconv.Itoa(n) + "~" + part
+			}
+
+			// Chunks may require quoting where the whole value didn't.
+			s, err := t.marshal(attrs, opts)
+			if err != nil {
+				return nil, err
+			}
+			if len(s) > opts.MaxSize {
+				return nil, ErrOversize
+			}
+			chunks = append(chunks, s)
+		}
+
+		return chunks, nil
+	}
+}
@@ -0,0 +1,93 @@
+package cookie
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parseCache holds the results of recent Parse calls, keyed by input.
+var parseCache struct {
+	sync.Mutex
+	size    int
+	cookies map[string]*Cookie
+}
+
+// parseCacheOn lets Parse skip the cache without locking when it's disabled.
+var parseCacheOn atomic.Bool
+
+// SetParseCache makes Parse cache up to size successfully parsed cookies,
+// which pays off when the same "Set-Cookie" header values are parsed over and
+// over, as is common when processing logs. Callers receive copies of the
+// cached cookies, and may modify them freely. The cache is disabled by
+// default, and a size of zero disables it again.
+//
+// Calling SetParseCache empties the cache, which should also be done after
+// changing other package-level settings affecting Parse, such as
+// ExpiresLocation or the set of registered attributes.
+func SetParseCache(size int) {
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	parseCache.size = size
+	parseCache.cookies = nil
+	if size > 0 {
+		parseCache.cookies = make(map[string]*Cookie)
+	}
+	parseCacheOn.Store(size > 0)
+}
+
+// cachedParse looks up raw in the parse cache.
+func cachedParse(raw string) (*Cookie, bool) {
+	if !parseCacheOn.Load() {
+		return nil, false
+	}
+
+	parseCache.Lock()
+	c, ok := parseCache.cookies[raw]
+	parseCache.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	return c.clone(), true
+}
+
+// cacheParse adds a parsed cookie to the cache, if enabled. Rather than
+// keeping track of which entries were used least recently, the cache starts
+// over when it fills up.
+func cacheParse(raw string, c *Cookie) {
+	if !parseCacheOn.Load() {
+		return
+	}
+
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	if parseCache.cookies == nil {
+		return
+	}
+	if len(parseCache.cookies) >= parseCache.size {
+		parseCache.cookies = make(map[string]*Cookie)
+	}
+	parseCache.cookies[raw] = c.clone()
+}
+
+// clone returns a deep copy of c.
+func (c *Cookie) clone() *Cookie {
+	d := *c
+
+	if c.Unparsed != nil {
+		d.Unparsed = append([]string(nil), c.Unparsed...)
+	}
+	if c.AttrOrder != nil {
+		d.AttrOrder = append([]string(nil), c.AttrOrder...)
+	}
+	if c.AttrCase != nil {
+		d.AttrCase = make(map[string]string, len(c.AttrCase))
+		for k, v := range c.AttrCase {
+			d.AttrCase[k] = v
+		}
+	}
+
+	return &d
+}
@@ -0,0 +1,63 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// ParseFunc walks a "Set-Cookie" header value without building a Cookie,
+// for consumers such as statistics collectors and header rewriters which
+// don't need one. The visit function is called first with the cookie's name
+// and value, then with the key and value of each attribute, in order. An
+// attribute without a value has an empty one. The known argument is true if
+// the attribute is handled by Parse, either natively or through RegisterAttr,
+// and is always false for the name and value.
+//
+// Names, values and attributes are validated as by ParseWithOptions, with the
+// same errors, and the options' limits are enforced. Attribute values are not
+// interpreted, though, so an invalid Expires date is not reported, and
+// neither are duplicate attributes. If visit returns an error, ParseFunc
+// stops and returns that error. Passing nil options is equivalent to passing
+// a zero ParseOptions.
+func ParseFunc(raw string, opts *ParseOptions, visit func(key, value string, known bool) error) error {
+	if opts == nil {
+		opts = defaultParseOptions
+	}
+
+	if err := checkSize(raw, opts); err != nil {
+		return err
+	}
+
+	end := nextSemicolon(raw, 0)
+
+	var c Cookie
+	if err := parsePair(&c, trim(raw[:end]), opts); err != nil {
+		return err
+	}
+	if err := visit(c.Name, c.Value, false); err != nil {
+		return err
+	}
+
+	it := attrIter{raw: raw, end: end}
+	for it.next(opts) {
+		key, val, err := splitAttr(it.part, opts)
+		if err != nil {
+			return err
+		}
+
+		if err := visit(key, val, isKnownAttr(key)); err != nil {
+			return err
+		}
+	}
+
+	return it.err
+}
+
+// isKnownAttr returns true if key names an attribute handled by Parse.
+func isKnownAttr(key string) bool {
+	for _, builtin := range builtinAttrs {
+		if strings.EqualFold(key, builtin) {
+			return true
+		}
+	}
+	return lookupAttr(key) != nil
+}
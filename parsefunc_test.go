@@ -0,0 +1,61 @@
+package cookie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFunc(t *testing.T) {
+	type visit struct {
+		key, value string
+		known      bool
+	}
+
+	// Registered attributes count as known.
+	RegisterAttr("Priority",
+		func(*Cookie, string) error { return nil },
+		func(*Cookie) (string, bool) { return "", false },
+	)
+	defer unregisterAttr("Priority")
+
+	in := `a="b"; path=/; Secure;; Priority=High; Foo=bar; Expires=bogus`
+	want := []visit{
+		{"a", "b", false},
+		{"path", "/", true},
+		{"Secure", "", true},
+		{"Priority", "High", true},
+		{"Foo", "bar", false},
+		{"Expires", "bogus", true},
+	}
+
+	var got []visit
+	err := ParseFunc(in, nil, func(key, value string, known bool) error {
+		got = append(got, visit{key, value, known})
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFunc(%#q):", in)
+		t.Errorf("  got  %+v, %v", got, err)
+		t.Errorf("  want %+v, %v", want, nil)
+	}
+
+	// Errors returned by visit stop the walk.
+	stop := errors.New("stop")
+	var n int
+	err = ParseFunc(in, nil, func(key, value string, known bool) error {
+		if n++; key == "Secure" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Errorf("ParseFunc stopped after %d visits with error %v, want 3, %v", n, err, stop)
+	}
+
+	for _, in := range []string{"a", "a b=c", "a=b; =c", "a=b; c=\"d"} {
+		if err := ParseFunc(in, nil, func(string, string, bool) error { return nil }); err == nil {
+			t.Errorf("ParseFunc(%#q) succeeded", in)
+		}
+	}
+}
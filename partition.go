@@ -0,0 +1,68 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errInsecurePartitioned = errors.New("Partitioned cookie without Secure attribute")
+)
+
+// SetCookieInContext is like SetCookie, but stores partitioned cookies under
+// the top-level site of the request context, so that they are only sent with
+// requests made in the context of the same top-level site. SetCookie is
+// equivalent to calling SetCookieInContext with a zero RequestContext, which
+// partitions cookies by the site of the host setting them.
+func (j *Jar) SetCookieInContext(scheme, host, path string, ctx RequestContext, c *Cookie, now time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, remove, err := j.prepare(scheme, host, ctx.TopLevelSite, c, now)
+	if err != nil {
+		j.reject(host, c, err, now)
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	j.store(entry, remove, now)
+	return nil
+}
+
+// partitionKey returns the key under which partitioned cookies are stored
+// for a request to a canonical host, made in the context of a top-level site,
+// such as "https://example.com". The key consists of the scheme and domain
+// root of the top-level site, or of the request itself if topLevel is empty.
+func (j *Jar) partitionKey(scheme, host, topLevel string) string {
+	if topLevel != "" {
+		scheme, host = "https", topLevel
+		if i := strings.Index(host, "://"); i >= 0 {
+			scheme, host = host[:i], host[i+3:]
+		}
+		if i := strings.IndexByte(host, '/'); i >= 0 {
+			host = host[:i]
+		}
+		if h, err := CanonicalHost(host); err == nil {
+			host = h
+		}
+	}
+
+	site := j.root(host)
+	if site == "" {
+		site = host
+	}
+	return strings.ToLower(scheme) + "://" + site
+}
+
+// entryKey returns the key identifying an entry in its bucket, taking its
+// partition into account.
+func (j *Jar) entryKey(e *jarEntry) string {
+	key := j.key(e.Domain, e.Path, e.Name, e.HostOnly)
+	if e.Partition != "" {
+		key += ";" + e.Partition
+	}
+	return key
+}
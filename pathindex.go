@@ -0,0 +1,83 @@
+package cookie
+
+// A pathIndex groups the jar's entries by root, then by path, making it
+// possible to find the entries matching a request path without considering
+// every entry in the bucket.
+type pathIndex map[string]pathBucket
+
+// A pathBucket maps paths to the entries with that path, keyed by entry key.
+type pathBucket map[string]map[string]*jarEntry
+
+// EnablePathIndex makes the jar maintain an index of its entries' paths. This
+// speeds up Cookies for domains with many path-scoped cookies, at the cost of
+// some memory and slightly slower updates.
+func (j *Jar) EnablePathIndex() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.paths != nil {
+		return
+	}
+
+	profile("Jar.EnablePathIndex", func() {
+		j.paths = make(pathIndex)
+		for _, bucket := range j.ent {
+			for _, entry := range bucket {
+				j.paths.add(entry)
+			}
+		}
+	})
+}
+
+// add adds an entry to the index.
+func (idx pathIndex) add(entry *jarEntry) {
+	pb, ok := idx[entry.Root]
+	if !ok {
+		pb = make(pathBucket)
+		idx[entry.Root] = pb
+	}
+
+	m, ok := pb[entry.Path]
+	if !ok {
+		m = make(map[string]*jarEntry)
+		pb[entry.Path] = m
+	}
+
+	m[entry.Key] = entry
+}
+
+// remove removes an entry from the index.
+func (idx pathIndex) remove(entry *jarEntry) {
+	pb := idx[entry.Root]
+	m := pb[entry.Path]
+
+	if m[entry.Key] != entry {
+		return
+	}
+
+	delete(m, entry.Key)
+	if len(m) == 0 {
+		delete(pb, entry.Path)
+		if len(pb) == 0 {
+			delete(idx, entry.Root)
+		}
+	}
+}
+
+// each calls fn for every entry whose path path-matches the request path, as
+// defined in RFC 6265, section 5.1.4. Only prefixes of the request path can
+// match, so at most len(path) lookups are made.
+func (pb pathBucket) each(path string, fn func(*jarEntry)) {
+	if len(pb) == 0 {
+		return
+	}
+
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i-1] != '/' && path[i] != '/' {
+			continue
+		}
+		for _, entry := range pb[path[:i]] {
+			fn(entry)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cookie
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// A Policy inspects a cookie set by a handler wrapped by EnforcePolicy. It may
+// modify the cookie, and returns false if the cookie should be dropped.
+type Policy func(r *http.Request, c *Cookie) bool
+
+// EnforcePolicy returns a handler which passes every cookie set by h through
+// policy before the response headers are sent. Cookies modified by the policy
+// are re-serialized, while the "Set-Cookie" headers of cookies it leaves
+// alone are passed through as is, as are headers which can't be parsed, such
+// as those deleting a cookie by setting an empty value.
+func EnforcePolicy(h http.Handler, policy Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &headerRewriter{ResponseWriter: w, rewrite: func(header http.Header) {
+			applyPolicy(header, r, policy)
+		}}
+		h.ServeHTTP(pw, r)
+
+		// Handlers which never write anything leave the headers to be
+		// sent by the server.
+		pw.before()
+	})
+}
+
+// applyPolicy applies a policy to the "Set-Cookie" headers in header.
+func applyPolicy(header http.Header, r *http.Request, policy Policy) {
+	raw := header["Set-Cookie"]
+	if len(raw) == 0 {
+		return
+	}
+
+	out := raw[:0]
+	for _, s := range raw {
+		c, err := Parse(s)
+		if err != nil {
+			out = append(out, s)
+			continue
+		}
+
+		orig := c.clone()
+		if !policy(r, c) {
+			continue
+		}
+		if !reflect.DeepEqual(c, orig) {
+			if s, err = c.Marshal(true); err != nil {
+				continue
+			}
+		}
+		out = append(out, s)
+	}
+
+	if len(out) == 0 {
+		header.Del("Set-Cookie")
+	} else {
+		header["Set-Cookie"] = out
+	}
+}
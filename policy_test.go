@@ -0,0 +1,78 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEnforcePolicy(t *testing.T) {
+	policy := func(r *http.Request, c *Cookie) bool {
+		if c.Name == "tracking" {
+			return false
+		}
+		c.Secure = true
+		c.HttpOnly = r.URL.Path != "/js"
+		return true
+	}
+
+	tests := []struct {
+		path  string
+		write bool
+		out   []string
+	}{
+		{"/", true, []string{"a=1; Path=/; HttpOnly; Secure", "b=2; HttpOnly; Secure; foo", "bogus"}},
+		{"/", false, []string{"a=1; Path=/; HttpOnly; Secure", "b=2; HttpOnly; Secure; foo", "bogus"}},
+		{"/js", true, []string{"a=1; Path=/; Secure", "b=2; Secure; foo", "bogus"}},
+	}
+
+	for _, test := range tests {
+		h := EnforcePolicy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Set-Cookie", "a=1; path=/")
+			w.Header().Add("Set-Cookie", "tracking=x")
+			w.Header().Add("Set-Cookie", "b=2; foo")
+			w.Header().Add("Set-Cookie", "bogus")
+			if test.write {
+				w.Write([]byte("hello"))
+				w.Header().Add("Set-Cookie", "late=1")
+			}
+		}), policy)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+
+		if out := w.Result().Header["Set-Cookie"]; !reflect.DeepEqual(out, test.out) {
+			t.Errorf("EnforcePolicy(%q, write=%v):", test.path, test.write)
+			t.Errorf("  got  %q", out)
+			t.Errorf("  want %q", test.out)
+		}
+	}
+}
+
+func TestEnforcePolicyPassThrough(t *testing.T) {
+	// Headers the policy leaves alone are passed through byte for byte,
+	// including deletions which don't survive a round trip through Parse.
+	in := []string{
+		"sid=; Max-Age=0",
+		"x=1; SameSite=None",
+		"y=1; Expires=Thu, 01 Jan 1970 00:00:00 GMT",
+		"z=1;  path=/",
+	}
+
+	h := EnforcePolicy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range in {
+			w.Header().Add("Set-Cookie", s)
+		}
+		http.NewResponseController(w).Flush()
+	}), func(*http.Request, *Cookie) bool { return true })
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if out := w.Result().Header["Set-Cookie"]; !w.Flushed || !reflect.DeepEqual(out, in) {
+		t.Errorf("EnforcePolicy with accept-all policy:")
+		t.Errorf("  got  %q", out)
+		t.Errorf("  want %q", in)
+	}
+}
@@ -0,0 +1,63 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrCookiePrefix is returned by Jar.SetCookie for a cookie whose name starts
+// with "__Secure-" or "__Host-", but which doesn't meet the requirements of
+// that prefix.
+var ErrCookiePrefix = errors.New("cookie violates the requirements of its name prefix")
+
+// A PrefixPolicy determines how a jar treats cookies which violate the
+// requirements of their name prefix, as specified by RFC 6265bis, section
+// 4.1.3: a "__Secure-" cookie must be Secure and set over HTTPS, and a
+// "__Host-" cookie must additionally have no Domain attribute and a Path
+// attribute of "/".
+type PrefixPolicy int
+
+const (
+	// PrefixReject makes SetCookie return ErrCookiePrefix. This is the
+	// default.
+	PrefixReject PrefixPolicy = iota
+
+	// PrefixDiscard makes SetCookie silently ignore the cookie, as browsers
+	// do.
+	PrefixDiscard
+)
+
+// SetPrefixPolicy sets the jar's prefix policy.
+func (j *Jar) SetPrefixPolicy(p PrefixPolicy) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prefixes = p
+}
+
+// checkPrefix returns a description of the way a cookie set over a secure
+// connection (or not) violates the requirements of its name prefix, or an
+// empty string if it doesn't. Prefixes are matched case-insensitively.
+func checkPrefix(c *Cookie, secure bool) string {
+	switch {
+	case hasPrefixFold(c.Name, "__Secure-"):
+		if !c.Secure || !secure {
+			return "__Secure- cookies must be Secure"
+		}
+	case hasPrefixFold(c.Name, "__Host-"):
+		switch {
+		case !c.Secure || !secure:
+			return "__Host- cookies must be Secure"
+		case c.Domain != "":
+			return "__Host- cookies must not have a Domain attribute"
+		case c.Path != "/":
+			return `__Host- cookies must have a Path attribute of "/"`
+		}
+	}
+	return ""
+}
+
+// hasPrefixFold is like strings.HasPrefix, but case-insensitive.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
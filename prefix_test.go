@@ -0,0 +1,60 @@
+package cookie
+
+import (
+	"testing"
+)
+
+var prefixTests = []struct {
+	scheme string
+	c      *Cookie
+	ok     bool
+}{
+	{"https", &Cookie{Name: "__Secure-a", Value: "1", Secure: true}, true},
+	{"https", &Cookie{Name: "__secure-a", Value: "1", Secure: true, Domain: "example.com"}, true},
+	{"https", &Cookie{Name: "__Secure-a", Value: "1"}, false},
+	{"http", &Cookie{Name: "__Secure-a", Value: "1", Secure: true}, false},
+	{"https", &Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/"}, true},
+	{"https", &Cookie{Name: "__HOST-a", Value: "1", Secure: true, Path: "/"}, true},
+	{"https", &Cookie{Name: "__Host-a", Value: "1", Path: "/"}, false},
+	{"http", &Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/"}, false},
+	{"https", &Cookie{Name: "__Host-a", Value: "1", Secure: true}, false},
+	{"https", &Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/x"}, false},
+	{"https", &Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/", Domain: "example.com"}, false},
+	{"http", &Cookie{Name: "_Host-a", Value: "1"}, true},
+}
+
+func TestCookiePrefix(t *testing.T) {
+	for _, test := range prefixTests {
+		if test.scheme == "https" {
+			_, err := test.c.Marshal(true)
+			if (err == nil) != test.ok {
+				t.Errorf("(%+v).Marshal(true) returned %v, want error %v", test.c, err, !test.ok)
+			}
+			if _, err := test.c.Marshal(false); err != nil {
+				t.Errorf("(%+v).Marshal(false) returned %v", test.c, err)
+			}
+		}
+
+		j := NewJar(testPSL{})
+		err := j.SetCookie(test.scheme, "www.example.com", "/", test.c, testNow)
+		var want error
+		if !test.ok {
+			want = ErrCookiePrefix
+		}
+		if err != want {
+			t.Errorf("SetCookie(%s, %+v) returned %v, want %v", test.scheme, test.c, err, want)
+		}
+
+		j.SetPrefixPolicy(PrefixDiscard)
+		if err := j.SetCookie(test.scheme, "www.example.com", "/", test.c, testNow); err != nil {
+			t.Errorf("SetCookie(%s, %+v) returned %v under PrefixDiscard", test.scheme, test.c, err)
+		}
+		if n := j.len(); test.ok != (n > 0) {
+			t.Errorf("jar holds %d entries after SetCookie(%s, %+v)", n, test.scheme, test.c)
+		}
+
+		if o, err := j.Preview(test.scheme, "www.example.com", "/", test.c, testNow); !test.ok && (o.Action != SetIgnored || err != nil) {
+			t.Errorf("Preview(%s, %+v) = %v, %v, want SetIgnored, nil", test.scheme, test.c, o.Action, err)
+		}
+	}
+}
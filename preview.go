@@ -0,0 +1,151 @@
+package cookie
+
+import (
+	"sort"
+	"time"
+)
+
+// A SetAction describes what SetCookie does with a cookie.
+type SetAction int
+
+const (
+	// SetStored means the cookie is stored as a new entry.
+	SetStored SetAction = iota
+
+	// SetReplaced means the cookie replaces an existing entry.
+	SetReplaced
+
+	// SetRemoved means the cookie has expired, and removes an existing
+	// entry.
+	SetRemoved
+
+	// SetIgnored means the cookie has expired, and there is no entry for it
+	// to remove, or that it's discarded under the PrefixDiscard policy.
+	SetIgnored
+
+	// SetRejected means the cookie is rejected with an error.
+	SetRejected
+)
+
+// Outcome describes what SetCookie would do with a cookie, as reported by
+// Preview.
+type Outcome struct {
+	Action SetAction
+
+	// The entry that would be stored or removed. Its HostOnly field tells
+	// whether the cookie would be sent to the setting host only, or to its
+	// entire Domain. Unset if the cookie is rejected.
+	Entry Entry
+
+	// The existing entry that would be replaced or removed, if any.
+	Previous *Entry
+
+	// Entries that would be evicted to keep the jar within its limits, in
+	// the order they would be evicted.
+	Evicted []Entry
+}
+
+// Preview reports what SetCookie would do with a cookie, without modifying
+// the jar. If SetCookie would fail, Preview returns the same error, along with
+// an Outcome whose Action is SetRejected.
+func (j *Jar) Preview(scheme, host, path string, c *Cookie, now time.Time) (Outcome, error) {
+	return j.PreviewInContext(scheme, host, path, RequestContext{}, c, now)
+}
+
+// PreviewInContext is like Preview, but reports what SetCookieInContext would
+// do with a cookie set in the request context ctx, which determines the
+// partition of partitioned cookies.
+func (j *Jar) PreviewInContext(scheme, host, path string, ctx RequestContext, c *Cookie, now time.Time) (Outcome, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	entry, remove, err := j.prepare(scheme, host, ctx.TopLevelSite, c, now)
+	if err != nil {
+		return Outcome{Action: SetRejected}, err
+	}
+	if entry == nil {
+		return Outcome{Action: SetIgnored}, nil
+	}
+
+	o := Outcome{Entry: entry.Entry}
+
+	prev, exists := j.ent[entry.Root][entry.Key]
+	if exists {
+		o.Previous = &Entry{}
+		*o.Previous = prev.Entry
+	}
+
+	switch {
+	case remove && exists:
+		o.Action = SetRemoved
+		return o, nil
+	case remove:
+		o.Action = SetIgnored
+		return o, nil
+	case exists:
+		o.Action = SetReplaced
+		o.Entry.Labels = prev.Labels
+		o.Entry.Sends = prev.Sends
+	default:
+		o.Action = SetStored
+	}
+
+	// Limits are enforced whenever an entry is stored, which may evict
+	// entries even when replacing one, if the limits were lowered.
+	o.Evicted = j.previewEvictions(entry, prev)
+
+	return o, nil
+}
+
+// previewEvictions returns the entries enforceLimits would evict after storing
+// entry in place of prev, which may be nil, in the order they'd be evicted.
+func (j *Jar) previewEvictions(entry, prev *jarEntry) []Entry {
+	if j.limits.PerRoot <= 0 && j.limits.Total <= 0 {
+		return nil
+	}
+
+	// The replaced entry is neither counted nor a candidate for eviction,
+	// while the new entry is counted but spared.
+	gone := map[*jarEntry]bool{prev: true}
+
+	var evicted []*jarEntry
+
+	if j.limits.PerRoot > 0 {
+		bucket := j.byEviction(j.ent[entry.Root], gone)
+		for i := 0; i < len(bucket)+1-j.limits.PerRoot; i++ {
+			evicted = append(evicted, bucket[i])
+			gone[bucket[i]] = true
+		}
+	}
+
+	if j.limits.Total > 0 {
+		var all []*jarEntry
+		for _, bucket := range j.ent {
+			all = append(all, j.byEviction(bucket, gone)...)
+		}
+		sort.Slice(all, func(a, b int) bool { return j.evictsBefore(all[a], all[b]) })
+
+		for i := 0; i < len(all)+1-j.limits.Total; i++ {
+			evicted = append(evicted, all[i])
+		}
+	}
+
+	entries := make([]Entry, len(evicted))
+	for i, e := range evicted {
+		entries[i] = e.Entry
+	}
+	return entries
+}
+
+// byEviction returns the entries of a bucket which aren't in skip, in the
+// order they would be evicted.
+func (j *Jar) byEviction(bucket map[string]*jarEntry, skip map[*jarEntry]bool) []*jarEntry {
+	entries := make([]*jarEntry, 0, len(bucket))
+	for _, e := range bucket {
+		if !skip[e] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool { return j.evictsBefore(entries[a], entries[b]) })
+	return entries
+}
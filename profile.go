@@ -0,0 +1,53 @@
+package cookie
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// profileHook holds the hook set by SetProfileHook, if any.
+var profileHook atomic.Pointer[func(op string, run func())]
+
+// SetProfileHook makes hook run each of the package's heavy operations, such
+// as importing cookies in bulk or rebuilding a jar's indexes, as well as every
+// call to Parse. The op argument names the operation (e.g. "Jar.ImportHAR"),
+// and run performs it; the hook must call run exactly once. A nil hook
+// removes it again. See PprofHook.
+//
+// SetProfileHook may be called while jars are in use.
+func SetProfileHook(hook func(op string, run func())) {
+	if hook == nil {
+		profileHook.Store(nil)
+		return
+	}
+	profileHook.Store(&hook)
+}
+
+// PprofHook returns a hook for SetProfileHook which runs operations with a "cookie" pprof
+// label set to the operation's name, in addition to the labels of ctx, so
+// that CPU profiles attribute time spent in the package to the operations
+// responsible. If timing is non-nil, it is called with each operation's name
+// and duration.
+func PprofHook(ctx context.Context, timing func(op string, d time.Duration)) func(string, func()) {
+	return func(op string, run func()) {
+		start := time.Now()
+		pprof.Do(ctx, pprof.Labels("cookie", op), func(context.Context) {
+			run()
+		})
+		if timing != nil {
+			timing(op, time.Since(start))
+		}
+	}
+}
+
+// profile runs an operation through the profile hook, if set.
+func profile(op string, run func()) {
+	hook := profileHook.Load()
+	if hook == nil {
+		run()
+		return
+	}
+	(*hook)(op, run)
+}
@@ -0,0 +1,43 @@
+package cookie
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProfileHook(t *testing.T) {
+	defer SetProfileHook(nil)
+
+	var ops, timed []string
+
+	hook := PprofHook(context.Background(), func(op string, d time.Duration) {
+		timed = append(timed, op)
+	})
+	SetProfileHook(func(op string, run func()) {
+		ops = append(ops, op)
+		hook(op, run)
+	})
+
+	j := NewJar(testPSL{})
+	j.ImportHAR([]HARCookie{{Name: "a", Value: "1", Domain: "example.com", Path: "/"}}, testNow)
+	j.EnablePathIndex()
+	j.SetPublicSuffixList(nil)
+	c, err := Parse("a=1; Path=/")
+
+	want := []string{"Jar.ImportHAR", "Jar.EnablePathIndex", "Jar.SetPublicSuffixList", "Parse"}
+	if !reflect.DeepEqual(ops, want) || !reflect.DeepEqual(timed, want) {
+		t.Errorf("profiled operations:")
+		t.Errorf("  got  %q, timed %q", ops, timed)
+		t.Errorf("  want %q, timed %q", want, want)
+	}
+
+	if c == nil || c.Name != "a" || c.Path != "/" || err != nil {
+		t.Errorf("profiled Parse returned %+v, %v", c, err)
+	}
+
+	if cookies, _ := j.Cookies("http", "example.com", "/", testNow); len(cookies) != 1 {
+		t.Errorf("profiled operations lost cookies: %+v", cookies)
+	}
+}
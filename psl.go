@@ -0,0 +1,51 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// PublicSuffixFunc adapts a function returning the public suffix of a domain
+// to the PublicSuffixList interface. The list defined in package
+// golang.org/x/net/publicsuffix already satisfies the interface, but the
+// function can be used with a small wrapper:
+//
+//	psl := cookie.NewPublicSuffixList(func(domain string) string {
+//		suffix, _ := publicsuffix.PublicSuffix(domain)
+//		return suffix
+//	})
+//
+// Results are normalized so that the jar's assumptions hold even for sloppy
+// implementations: an empty domain has an empty suffix, and a result which
+// isn't a suffix of the domain (on a label boundary) is replaced with the
+// domain's last label, following the "*" rule of the public suffix list.
+type PublicSuffixFunc func(domain string) string
+
+// NewPublicSuffixList returns fn as a PublicSuffixList.
+func NewPublicSuffixList(fn func(domain string) string) PublicSuffixFunc {
+	return PublicSuffixFunc(fn)
+}
+
+// PublicSuffix implements PublicSuffixList.
+func (fn PublicSuffixFunc) PublicSuffix(domain string) string {
+	if domain == "" {
+		return ""
+	}
+
+	suffix := fn(domain)
+	if suffix == domain {
+		return suffix
+	}
+
+	if i := len(domain) - len(suffix); suffix == "" || i <= 0 ||
+		domain[i-1] != '.' || domain[i:] != suffix {
+		return domain[strings.LastIndexByte(domain, '.')+1:]
+	}
+
+	return suffix
+}
+
+// String implements the String method of the PublicSuffixList interface
+// defined in package net/http/cookiejar.
+func (fn PublicSuffixFunc) String() string {
+	return "cookie.PublicSuffixFunc"
+}
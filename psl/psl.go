@@ -0,0 +1,160 @@
+// Package psl implements the cookie.PublicSuffixList interface using the
+// Mozilla Public Suffix List (https://publicsuffix.org).
+//
+// A compressed copy of the list is embedded in the package and returned by
+// DefaultPSL. Since the list changes over time, long-running programs may
+// load an updated copy using Load or LoadFile, and hand it to their jars
+// through Jar.SetPublicSuffixList. The list is distributed under the terms
+// of the Mozilla Public License, v. 2.0.
+package psl
+
+//go:generate sh -c "curl -sSf https://publicsuffix.org/list/public_suffix_list.dat | gzip -9n > public_suffix_list.dat.gz"
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed public_suffix_list.dat.gz
+var defaultData []byte
+
+var (
+	defaultOnce sync.Once
+	defaultList *List
+)
+
+// Kinds of rules, stored as bits so that a name can be subject to several.
+const (
+	ruleNormal    = 1 << iota // "example.com"
+	ruleWildcard              // "*.example.com", stored under "example.com"
+	ruleException             // "!www.example.com"
+)
+
+// A List is a parsed public suffix list. It is safe for concurrent use by
+// multiple goroutines.
+type List struct {
+	rules map[string]uint8
+}
+
+// DefaultPSL returns the copy of the public suffix list embedded in the
+// package. The list is parsed on first use.
+func DefaultPSL() *List {
+	defaultOnce.Do(func() {
+		r, err := gzip.NewReader(bytes.NewReader(defaultData))
+		if err == nil {
+			defaultList, err = Load(r)
+		}
+		if err != nil {
+			panic("psl: invalid embedded list: " + err.Error())
+		}
+	})
+	return defaultList
+}
+
+// Load parses a public suffix list in the format of the public_suffix_list.dat
+// file published at https://publicsuffix.org/list/. Rules containing
+// non-ASCII characters are converted to punycode, so that they match the
+// canonical hosts used by cookie jars.
+func Load(r io.Reader) (*List, error) {
+	l := &List{rules: make(map[string]uint8)}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		// Rules end at the first whitespace character.
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+
+		if err := l.add(fields[0]); err != nil {
+			return nil, fmt.Errorf("psl: line %d: %v", n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// LoadFile is like Load, but reads the list from the named file.
+func LoadFile(name string) (*List, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// add adds a single rule to the list.
+func (l *List) add(rule string) error {
+	kind := uint8(ruleNormal)
+	switch {
+	case strings.HasPrefix(rule, "!"):
+		kind, rule = ruleException, rule[1:]
+	case strings.HasPrefix(rule, "*."):
+		kind, rule = ruleWildcard, rule[2:]
+	}
+
+	name, err := toASCII(rule)
+	if err != nil {
+		return err
+	}
+	if name == "" || strings.ContainsAny(name, "*!") {
+		return fmt.Errorf("invalid rule %s", strconv.Quote(rule))
+	}
+
+	l.rules[name] |= kind
+	return nil
+}
+
+// Len returns the number of names the list has rules for.
+func (l *List) Len() int {
+	return len(l.rules)
+}
+
+// PublicSuffix returns the public suffix of a domain, which should be in the
+// canonical form used by cookie jars: lower case, and with internationalized
+// labels in punycode. Domains not covered by any rule are treated as having
+// their top-level domain as public suffix, following the implicit "*" rule.
+func (l *List) PublicSuffix(domain string) string {
+	// Rules are considered from the longest candidate down, so the first
+	// match is the longest one. An exception rule outranks a wildcard rule
+	// matching the same name.
+	for s := domain; ; {
+		var parent string
+		i := strings.IndexByte(s, '.')
+		if i >= 0 {
+			parent = s[i+1:]
+		}
+
+		kind := l.rules[s]
+		if kind&ruleException != 0 {
+			return parent
+		}
+		if kind&ruleNormal != 0 || i >= 0 && l.rules[parent]&ruleWildcard != 0 {
+			return s
+		}
+
+		if i < 0 {
+			return s
+		}
+		s = parent
+	}
+}
+
+// String implements the String method of the PublicSuffixList interface
+// defined in package net/http/cookiejar.
+func (l *List) String() string {
+	return "psl.List (" + strconv.Itoa(len(l.rules)) + " names)"
+}
@@ -0,0 +1,82 @@
+package psl
+
+import (
+	"strings"
+	"testing"
+)
+
+var publicSuffixTests = []struct {
+	in  string
+	out string
+}{
+	{"com", "com"},
+	{"example.com", "com"},
+	{"www.example.co.uk", "co.uk"},
+	{"example.zzz", "zzz"},
+	{"foo.example.ck", "example.ck"},
+	{"www.ck", "ck"},
+	{"a.b.kawasaki.jp", "b.kawasaki.jp"},
+	{"city.kawasaki.jp", "kawasaki.jp"},
+	{"x.city.kawasaki.jp", "kawasaki.jp"},
+	{"user.github.io", "github.io"},
+	{"example.xn--fiqs8s", "xn--fiqs8s"},
+}
+
+func TestDefaultPSL(t *testing.T) {
+	l := DefaultPSL()
+	if l.Len() < 1000 {
+		t.Fatalf("DefaultPSL() has only %d names", l.Len())
+	}
+
+	for _, test := range publicSuffixTests {
+		if out := l.PublicSuffix(test.in); out != test.out {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	l, err := Load(strings.NewReader("// comment\n\ncom\n*.ck\n!www.ck\nbücher.example  trailing\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"example.com", "com"},
+		{"a.b.ck", "b.ck"},
+		{"www.ck", "ck"},
+		{"a.xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"example.org", "org"},
+	}
+
+	for _, test := range tests {
+		if out := l.PublicSuffix(test.in); out != test.out {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+
+	if _, err := Load(strings.NewReader("com\na.*.b\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Load of invalid rule returned %v", err)
+	}
+}
+
+var toASCIITests = []struct {
+	in  string
+	out string
+}{
+	{"example.com", "example.com"},
+	{"Bücher.example", "xn--bcher-kva.example"},
+	{"中国", "xn--fiqs8s"},
+	{"münchen-ost.de", "xn--mnchen-ost-9db.de"},
+}
+
+func TestToASCII(t *testing.T) {
+	for _, test := range toASCIITests {
+		if out, err := toASCII(test.in); out != test.out || err != nil {
+			t.Errorf("toASCII(%q) = %q, %v, want %q", test.in, out, err, test.out)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package psl
+
+import (
+	"strings"
+
+	"github.com/erkl/cookie"
+)
+
+// toASCII converts the non-ASCII labels of a domain name to punycode.
+func toASCII(name string) (string, error) {
+	labels := strings.Split(strings.ToLower(name), ".")
+
+	var buf []byte
+	for i, label := range labels {
+		if i > 0 {
+			buf = append(buf, '.')
+		}
+
+		var err error
+		if buf, err = cookie.PunycodeEncodeLabel(label, buf); err != nil {
+			return "", err
+		}
+	}
+
+	return string(buf), nil
+}
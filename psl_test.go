@@ -0,0 +1,52 @@
+package cookie
+
+import (
+	"testing"
+)
+
+// ukPSL mimics a public suffix list knowing about "co.uk".
+func ukPSL(domain string) string {
+	if domain == "co.uk" || hasDotSuffix(domain, "co.uk") {
+		return "co.uk"
+	}
+	return domain[len(domain)-2:]
+}
+
+var pslTests = []struct {
+	fn     func(string) string
+	domain string
+	suffix string
+}{
+	{ukPSL, "", ""},
+	{ukPSL, "www.example.co.uk", "co.uk"},
+	{ukPSL, "co.uk", "co.uk"},
+	{ukPSL, "uk", "uk"},
+	{ukPSL, "example.com", "com"}, // "om" isn't on a label boundary
+	{ukPSL, "example.io", "io"},   // well-behaved
+	{func(string) string { return "" }, "example.com", "com"},
+	{func(string) string { return "org" }, "example.com", "com"},
+	{func(string) string { return "a.example.com" }, "example.com", "com"},
+}
+
+func TestPublicSuffixFunc(t *testing.T) {
+	for _, test := range pslTests {
+		if suffix := NewPublicSuffixList(test.fn).PublicSuffix(test.domain); suffix != test.suffix {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", test.domain, suffix, test.suffix)
+		}
+	}
+
+	// The jar should treat registrable domains as roots, and refuse cookies
+	// for public suffixes.
+	j := NewJar(NewPublicSuffixList(ukPSL))
+	if err := j.SetCookie("http", "www.example.co.uk", "/", &Cookie{Name: "a", Value: "1", Domain: "co.uk"}, testNow); err == nil {
+		t.Errorf("SetCookie accepted cookie for public suffix")
+	}
+
+	j.SetCookie("http", "www.example.co.uk", "/", &Cookie{Name: "a", Value: "1", Domain: "example.co.uk"}, testNow)
+	if cookies, _ := j.Cookies("http", "example.co.uk", "/", testNow); len(cookies) != 1 {
+		t.Errorf("Cookies(example.co.uk) returned %+v", cookies)
+	}
+	if cookies, _ := j.Cookies("http", "other.co.uk", "/", testNow); len(cookies) != 0 {
+		t.Errorf("Cookies(other.co.uk) returned %+v", cookies)
+	}
+}
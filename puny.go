@@ -2,13 +2,50 @@ package cookie
 
 import (
 	"errors"
+	"math"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
 	errInvalidDomain = errors.New("invalid domain")
+
+	// ErrLabelTooLong is returned when the ASCII form of a domain label
+	// would exceed the 63 byte limit imposed by DNS.
+	ErrLabelTooLong = errors.New("domain label too long")
 )
 
+// maxLabelLen is the maximum length of a domain label in its ASCII form.
+const maxLabelLen = 63
+
+// An IDNAError is returned when an internationalized domain name can't be
+// converted to its ASCII (punycode) form.
+type IDNAError struct {
+	// The offending label, as given.
+	Label string
+
+	// Byte offset of the problem within the domain name.
+	Offset int
+
+	// Description of the problem.
+	Reason string
+
+	// Underlying error, if any.
+	Err error
+}
+
+func (e *IDNAError) Error() string {
+	return "invalid domain label " + strconv.Quote(e.Label) + " at offset " +
+		strconv.Itoa(e.Offset) + ": " + e.Reason
+}
+
+// Unwrap returns the underlying error, making it possible to test for e.g.
+// ErrLabelTooLong using errors.Is.
+func (e *IDNAError) Unwrap() error {
+	return e.Err
+}
+
 const (
 	base int32 = 36
 	damp int32 = 700
@@ -29,22 +66,57 @@ func toASCII(domain string) (string, error) {
 	labels := strings.Split(domain, ".")
 	buf := make([]byte, 0, 512)
 
+	var offset int
 	for i := range labels {
-		if isASCII(labels[i]) {
+		label := labels[i]
+		if isASCII(label) {
+			offset += len(label) + 1
 			continue
 		}
 
 		var err error
 
-		labels[i], err = encode(labels[i], buf)
-		if err != nil {
-			return "", err
+		labels[i], err = encode(label, buf)
+		if err == ErrLabelTooLong {
+			return "", &IDNAError{Label: label, Offset: offset, Reason: "label too long", Err: err}
+		} else if err != nil {
+			return "", &IDNAError{Label: label, Offset: offset, Reason: "punycode overflow"}
 		}
+
+		offset += len(label) + 1
 	}
 
 	return strings.Join(labels, "."), nil
 }
 
+// checkUTF8 returns an IDNAError if domain isn't valid UTF-8. This has to be
+// checked before the domain is lowercased, which replaces invalid sequences.
+func checkUTF8(domain string) error {
+	if utf8.ValidString(domain) {
+		return nil
+	}
+
+	var start int
+	for i := 0; i < len(domain); {
+		if domain[i] == '.' {
+			start = i + 1
+		}
+
+		r, n := utf8.DecodeRuneInString(domain[i:])
+		if r == utf8.RuneError && n <= 1 {
+			label := domain[start:]
+			if dot := strings.IndexByte(label, '.'); dot >= 0 {
+				label = label[:dot]
+			}
+			return &IDNAError{Label: label, Offset: i, Reason: "invalid UTF-8"}
+		}
+
+		i += n
+	}
+
+	return nil
+}
+
 // isASCII returns true if the input string contains only ASCII characters.
 func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
@@ -56,8 +128,38 @@ func isASCII(s string) bool {
 	return true
 }
 
+// PunycodeEncodeLabel appends the ASCII form of a domain label to dst and
+// returns the extended buffer. Labels containing non-ASCII characters are
+// encoded as punycode with an "xn--" prefix, as specified by RFC 3492, while
+// ASCII labels are appended as is. The label isn't lowercased or otherwise
+// normalized. No memory is allocated if dst has enough spare capacity, making
+// it cheap to convert many labels using the same buffer.
+//
+// ErrLabelTooLong is returned if the encoded label would be longer than 63
+// bytes.
+func PunycodeEncodeLabel(label string, dst []byte) ([]byte, error) {
+	if isASCII(label) {
+		if len(label) > maxLabelLen {
+			return nil, ErrLabelTooLong
+		}
+		return append(dst, label...), nil
+	}
+	return appendPunycode(dst, label)
+}
+
 // encode converts a non-ASCII domain label to its punycode representation.
 func encode(s string, buf []byte) (string, error) {
+	buf, err := appendPunycode(buf, s)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// appendPunycode appends the punycode representation of a domain label,
+// including the "xn--" prefix, to buf. Encoding is abandoned with
+// ErrLabelTooLong as soon as the output exceeds maxLabelLen bytes.
+func appendPunycode(buf []byte, s string) ([]byte, error) {
 	var bias = initialBias
 	var n = initialN
 
@@ -65,6 +167,14 @@ func encode(s string, buf []byte) (string, error) {
 	var q, k, t int32
 	var rem int
 
+	// Every rune contributes at least one byte to the output, so long inputs
+	// can be rejected up front. This also bounds the work done below, which
+	// is quadratic in the number of runes.
+	if utf8.RuneCountInString(s) > maxLabelLen-len("xn--") {
+		return nil, ErrLabelTooLong
+	}
+	start := len(buf)
+
 	// Begin by writing the "ASCII Compatible Encoding" prefix.
 	buf = append(buf, "xn--"...)
 
@@ -83,6 +193,9 @@ func encode(s string, buf []byte) (string, error) {
 	if b > 0 {
 		buf = append(buf, '-')
 	}
+	if len(buf)-start > maxLabelLen {
+		return nil, ErrLabelTooLong
+	}
 
 	h = b
 
@@ -100,14 +213,14 @@ func encode(s string, buf []byte) (string, error) {
 		n = m
 
 		if d < 0 {
-			return "", errInvalidDomain
+			return nil, errInvalidDomain
 		}
 
 		// Encode the next non-ASCII character.
 		for _, r := range s {
 			if r < n {
 				if d++; d < 0 {
-					return "", errInvalidDomain
+					return nil, errInvalidDomain
 				}
 				continue
 			}
@@ -145,6 +258,10 @@ func encode(s string, buf []byte) (string, error) {
 				buf = append(buf, byte('0'-26+digit))
 			}
 
+			if len(buf)-start > maxLabelLen {
+				return nil, ErrLabelTooLong
+			}
+
 			bias = adapt(d, h+1, h == b)
 			d = 0
 			h = h + 1
@@ -155,7 +272,109 @@ func encode(s string, buf []byte) (string, error) {
 		n++
 	}
 
-	return string(buf), nil
+	return buf, nil
+}
+
+// PunycodeDecodeLabel appends the Unicode form of a domain label to dst and
+// returns the extended buffer. Labels with an "xn--" prefix (in any case) are
+// decoded from punycode, as specified by RFC 3492, while other labels are
+// appended as is. No memory is allocated if dst has enough spare capacity.
+func PunycodeDecodeLabel(label string, dst []byte) ([]byte, error) {
+	if len(label) < 4 || !strings.EqualFold(label[:4], "xn--") {
+		return append(dst, label...), nil
+	}
+	label = label[4:]
+
+	// Basic code points precede the last delimiter, if there is one.
+	start := len(dst)
+	if d := strings.LastIndexByte(label, '-'); d >= 0 {
+		for i := 0; i < d; i++ {
+			if label[i] >= 0x80 {
+				return nil, errInvalidDomain
+			}
+		}
+		dst = append(dst, label[:d]...)
+		label = label[d+1:]
+	}
+
+	var n, i, bias = initialN, int32(0), initialBias
+	var runes = int32(utf8.RuneCount(dst[start:]))
+
+	for len(label) > 0 {
+		oldi, w := i, int32(1)
+
+		for k := base; ; k += base {
+			if len(label) == 0 {
+				return nil, errInvalidDomain
+			}
+
+			digit := decodeDigit(label[0])
+			label = label[1:]
+			if digit < 0 || digit > (math.MaxInt32-i)/w {
+				return nil, errInvalidDomain
+			}
+			i += digit * w
+
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+
+			if digit < t {
+				break
+			}
+			if w > math.MaxInt32/(base-t) {
+				return nil, errInvalidDomain
+			}
+			w *= base - t
+		}
+
+		runes++
+		bias = adapt(i-oldi, runes, oldi == 0)
+
+		if i/runes > math.MaxInt32-n {
+			return nil, errInvalidDomain
+		}
+		n += i / runes
+		i %= runes
+
+		if n < 0x80 || !utf8.ValidRune(n) {
+			return nil, errInvalidDomain
+		}
+
+		// Insert the rune at position i, shifting the rest of the output.
+		at := start
+		for j := int32(0); j < i; j++ {
+			_, size := utf8.DecodeRune(dst[at:])
+			at += size
+		}
+
+		size := utf8.RuneLen(n)
+		for j := 0; j < size; j++ {
+			dst = append(dst, 0)
+		}
+		copy(dst[at+size:], dst[at:len(dst)-size])
+		utf8.EncodeRune(dst[at:], n)
+
+		i++
+	}
+
+	return dst, nil
+}
+
+// decodeDigit returns the value of a punycode digit, or -1 if c isn't one.
+func decodeDigit(c byte) int32 {
+	switch {
+	case '0' <= c && c <= '9':
+		return int32(c-'0') + 26
+	case 'a' <= c && c <= 'z':
+		return int32(c - 'a')
+	case 'A' <= c && c <= 'Z':
+		return int32(c - 'A')
+	}
+	return -1
 }
 
 // adapt is the bias adaption function from RFC 3492, 6.1.
@@ -1,7 +1,9 @@
 package cookie
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 var encodeTests = []struct {
@@ -122,11 +124,93 @@ var encodeTests = []struct {
 
 func TestEncode(t *testing.T) {
 	for _, test := range encodeTests {
+		want, wantErr := test.out, test.err
+
+		// Some of the RFC 3492 samples are too long to be domain labels.
+		if len(want) > maxLabelLen {
+			want, wantErr = "", ErrLabelTooLong
+		}
+
 		out, err := encode(test.in, nil)
-		if out != test.out || err != test.err {
+		if out != want || err != wantErr {
 			t.Errorf("encode(%q):", test.in)
 			t.Errorf("  got  %q, %+v", out, err)
-			t.Errorf("  want %q, %+v", test.out, test.err)
+			t.Errorf("  want %q, %+v", want, wantErr)
+		}
+	}
+}
+
+func FuzzEncode(f *testing.F) {
+	for _, test := range encodeTests {
+		f.Add(test.in)
+	}
+	f.Add(strings.Repeat("\U0010FFFF", 60))
+	f.Add(strings.Repeat("a", 58) + "\u00fc")
+	f.Add(strings.Repeat("0", 59))
+
+	f.Fuzz(func(t *testing.T, in string) {
+		out, err := encode(in, nil)
+		if err != nil {
+			return
+		}
+		if len(out) > maxLabelLen {
+			t.Fatalf("encode(%q) = %q, longer than %d bytes", in, out, maxLabelLen)
+		}
+		if !utf8.ValidString(in) {
+			return
+		}
+		if dec, err := PunycodeDecodeLabel(out, nil); string(dec) != in || err != nil {
+			t.Fatalf("PunycodeDecodeLabel(%q) = %q, %v, want %q", out, dec, err, in)
+		}
+	})
+}
+
+func TestPunycodeLabel(t *testing.T) {
+	for _, test := range encodeTests {
+		if test.err != nil {
+			continue
+		}
+
+		out, err := PunycodeDecodeLabel(test.out, nil)
+		if string(out) != test.in || err != nil {
+			t.Errorf("PunycodeDecodeLabel(%q):", test.out)
+			t.Errorf("  got  %q, %+v", out, err)
+			t.Errorf("  want %q, %+v", test.in, nil)
 		}
 	}
+
+	for _, in := range []string{"example", "bücher", "Hello世界"} {
+		enc, err := PunycodeEncodeLabel(in, nil)
+		if err != nil {
+			t.Errorf("PunycodeEncodeLabel(%q): %v", in, err)
+			continue
+		}
+		if dec, err := PunycodeDecodeLabel(string(enc), nil); string(dec) != in || err != nil {
+			t.Errorf("PunycodeDecodeLabel(PunycodeEncodeLabel(%q)) = %q, %v", in, dec, err)
+		}
+	}
+
+	for _, in := range []string{strings.Repeat("a", 64), strings.Repeat("ü", 60), strings.Repeat("ü\U0010FFFF", 25)} {
+		if out, err := PunycodeEncodeLabel(in, nil); err != ErrLabelTooLong {
+			t.Errorf("PunycodeEncodeLabel(%q) = %q, %v, want ErrLabelTooLong", in, out, err)
+		}
+	}
+
+	for _, in := range []string{"xn--ü-", "xn--99999999999", "xn--9", "xn--!"} {
+		if out, err := PunycodeDecodeLabel(in, nil); err == nil {
+			t.Errorf("PunycodeDecodeLabel(%q) = %q, want error", in, out)
+		}
+	}
+}
+
+func TestPunycodeLabelAllocs(t *testing.T) {
+	buf := make([]byte, 0, 64)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		PunycodeEncodeLabel("bücher", buf[:0])
+		PunycodeDecodeLabel("xn--bcher-kva", buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("encoding and decoding allocated %v times per run, want 0", allocs)
+	}
 }
@@ -0,0 +1,141 @@
+package cookie
+
+import (
+	"sort"
+)
+
+// Range calls fn with a copy of each of the jar's entries, sorted by domain
+// root, domain, path and name, stopping early if fn returns false.
+//
+// The entries are those held by the jar when Range was called, as if it had
+// taken a snapshot, but no lock is held while fn runs, so fn may use the jar,
+// including modifying it, without deadlocking or blocking other goroutines
+// for the duration of the iteration. Rather than copying the jar up front,
+// each domain root's entries are copied when Range gets to them, or just
+// before they're first modified, whichever comes first. Send counts, which
+// change whenever cookies are sent rather than when they're stored, may be
+// more recent than the snapshot.
+func (j *Jar) Range(fn func(e Entry) bool) {
+	it := j.beginRange()
+	defer j.endRange(it)
+
+	for _, root := range it.roots {
+		for _, e := range j.visit(it, root) {
+			if !fn(e) {
+				return
+			}
+		}
+	}
+}
+
+// Range calls fn with a copy of each of the jar's entries, as described for
+// Jar.Range.
+func (v *View) Range(fn func(e Entry) bool) {
+	v.j.Range(fn)
+}
+
+// iteration is the state of an ongoing call to Range.
+type iteration struct {
+	// Domain roots held by the jar when the iteration began, sorted, and
+	// those yet to be visited.
+	roots   []string
+	pending map[string]bool
+
+	// Copies of the buckets modified since the iteration began, as they were
+	// before, for those yet to be visited.
+	saved map[string][]Entry
+}
+
+// beginRange registers a new iteration, starting a new generation.
+func (j *Jar) beginRange() *iteration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	it := &iteration{
+		roots:   make([]string, 0, len(j.ent)),
+		pending: make(map[string]bool, len(j.ent)),
+		saved:   make(map[string][]Entry),
+	}
+	for root := range j.ent {
+		it.roots = append(it.roots, root)
+		it.pending[root] = true
+	}
+	sort.Strings(it.roots)
+
+	if j.iters == nil {
+		j.iters = make(map[*iteration]bool)
+		j.preserved = make(map[string]uint64)
+	}
+	j.iters[it] = true
+	j.iterGen++
+
+	return it
+}
+
+// endRange unregisters an iteration.
+func (j *Jar) endRange(it *iteration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.iters, it)
+	if len(j.iters) == 0 {
+		j.iters, j.preserved = nil, nil
+	}
+}
+
+// visit returns the entries of a domain root as they were when an iteration
+// began.
+func (j *Jar) visit(it *iteration, root string) []Entry {
+	// The iteration's state is only modified by its own goroutine, or by
+	// preserve, which requires the write lock.
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	delete(it.pending, root)
+
+	if entries, ok := it.saved[root]; ok {
+		delete(it.saved, root)
+		return entries
+	}
+	return bucketEntries(j.ent[root])
+}
+
+// preserve saves a copy of a bucket about to be modified for the ongoing
+// iterations which have yet to visit it. Each bucket is copied at most once
+// per generation, as iterations which began before the last copy was made
+// have already been given one.
+func (j *Jar) preserve(root string) {
+	if len(j.iters) == 0 || j.preserved[root] == j.iterGen {
+		return
+	}
+	j.preserved[root] = j.iterGen
+
+	var entries []Entry
+	var copied bool
+
+	for it := range j.iters {
+		if _, ok := it.saved[root]; ok || !it.pending[root] {
+			continue
+		}
+		if !copied {
+			entries, copied = bucketEntries(j.ent[root]), true
+		}
+		it.saved[root] = entries
+	}
+}
+
+// bucketEntries returns copies of a bucket's entries, sorted by domain,
+// path and name.
+func bucketEntries(bucket map[string]*jarEntry) []Entry {
+	sorted := make([]*jarEntry, 0, len(bucket))
+	for _, entry := range bucket {
+		sorted = append(sorted, entry)
+	}
+	sortEntries(sorted)
+
+	entries := make([]Entry, len(sorted))
+	for i, entry := range sorted {
+		entries[i] = entry.Entry
+	}
+	return entries
+}
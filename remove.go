@@ -0,0 +1,73 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// Clear removes all of the jar's entries, for example when a client logs
+// out of everything. The jar's options and observers are kept, and observers
+// are notified of all removals in a single call. It returns the number of
+// entries removed.
+func (j *Jar) Clear() int {
+	return j.removeWhere(func(*jarEntry) bool { return true })
+}
+
+// RemoveByDomain removes all entries whose domain is domain or one of its
+// subdomains, whether host-only or not, for example to forget everything
+// stored by a site. A leading dot is ignored, and domain is otherwise
+// canonicalized like a host, so internationalized domain names may be given
+// in Unicode. Observers are notified of all removals in a single call. It
+// returns the number of entries removed.
+func (j *Jar) RemoveByDomain(domain string) int {
+	domain, err := CanonicalHost(strings.TrimPrefix(domain, "."))
+	if err != nil {
+		return 0
+	}
+
+	return j.removeWhere(func(entry *jarEntry) bool {
+		return entry.Domain == domain || hasDotSuffix(entry.Domain, domain)
+	})
+}
+
+// Remove removes the entries identified by domain, path and name, including
+// both the host-only and the domain cookie if the jar keeps them separate,
+// and partitioned cookies of every partition. A leading dot in domain is
+// ignored, and domain is otherwise canonicalized as by RemoveByDomain. It
+// returns the number of entries removed.
+func (j *Jar) Remove(domain, path, name string) int {
+	domain, err := CanonicalHost(strings.TrimPrefix(domain, "."))
+	if err != nil {
+		return 0
+	}
+
+	return j.removeWhere(identifiedBy(domain, path, name))
+}
+
+// identifiedBy returns a function reporting whether an entry, of any
+// partition, is identified by the canonical domain, path and name.
+func identifiedBy(domain, path, name string) func(entry *jarEntry) bool {
+	return func(entry *jarEntry) bool {
+		return entry.Domain == domain && entry.Path == path && entry.Name == name
+	}
+}
+
+// removeWhere removes the entries for which fn returns true, reporting the
+// removals to observers in a single call.
+func (j *Jar) removeWhere(fn func(entry *jarEntry) bool) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var n int
+	now := j.clockNow()
+
+	j.batched(func() {
+		for _, entry := range j.sorted() {
+			if fn(entry) {
+				j.remove(entry, now)
+				n++
+			}
+		}
+	})
+
+	return n
+}
@@ -0,0 +1,56 @@
+package cookie
+
+import (
+	"reflect"
+)
+
+// ReplaceFrom replaces the jar's entries with copies of those in other, for
+// example after loading a refreshed snapshot into a new jar. The jar's
+// observers, options and revision history are preserved: only the entries
+// that differ are updated or removed, and observers are notified of all
+// changes in a single call. Entries are re-filed according to this jar's
+// public suffix list and conflict policy, and its limits are enforced.
+func (j *Jar) ReplaceFrom(other *Jar) {
+	if other == j {
+		return
+	}
+
+	// Copy the other jar's entries first, so that the two jars are never
+	// locked at the same time.
+	other.mu.RLock()
+	entries := other.sorted()
+	for i, e := range entries {
+		entries[i] = &jarEntry{Entry: e.Entry}
+	}
+	other.mu.RUnlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	profile("Jar.ReplaceFrom", func() {
+		incoming := make(map[string]bool, len(entries))
+
+		for _, entry := range entries {
+			entry.Root = j.root(entry.Domain)
+			entry.Key = j.entryKey(entry)
+			incoming[entry.Root+"\x00"+entry.Key] = true
+		}
+
+		now := j.clockNow()
+
+		j.batched(func() {
+			for _, entry := range j.sorted() {
+				if !incoming[entry.Root+"\x00"+entry.Key] {
+					j.remove(entry, now)
+				}
+			}
+
+			for _, entry := range entries {
+				if prev, ok := j.ent[entry.Root][entry.Key]; ok && reflect.DeepEqual(prev.Entry, entry.Entry) {
+					continue
+				}
+				j.set(entry, now)
+			}
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package cookie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotParsed is returned by FromContext when the context wasn't
+	// derived from one returned by WithParsed.
+	ErrNotParsed = errors.New("cookie: request cookies not parsed")
+
+	// ErrNoCookie is returned by the typed getters of CookieMap when there
+	// is no cookie with the given name.
+	ErrNoCookie = errors.New("cookie: named cookie not present")
+)
+
+// parsedKey is the context key under which WithParsed stores its result.
+type parsedKey struct{}
+
+// parsed is the result of parsing a request's cookies, along with the
+// header it was parsed from.
+type parsed struct {
+	header string
+	m      *CookieMap
+}
+
+// WithParsed parses the "Cookie" headers of a request and returns a copy of
+// ctx holding the result, which can be retrieved using FromContext. If ctx
+// already holds cookies parsed from the same header, it is returned as is, so
+// any number of middlewares can call WithParsed while the header is only
+// parsed once. Middlewares which change the header, such as
+// Namespace.Handler, must call WithParsed again for the request they pass on.
+//
+//	r = r.WithContext(cookie.WithParsed(r.Context(), r))
+func WithParsed(ctx context.Context, r *http.Request) context.Context {
+	header := strings.Join(r.Header["Cookie"], "; ")
+	if p, ok := ctx.Value(parsedKey{}).(*parsed); ok && p.header == header {
+		return ctx
+	}
+
+	return context.WithValue(ctx, parsedKey{}, &parsed{header, ParseRequestHeader(header)})
+}
+
+// FromContext returns the cookies parsed by WithParsed, which never fails,
+// as invalid pairs are skipped. ErrNotParsed is returned if WithParsed
+// hasn't been called.
+//
+// The returned map is shared by all users of the context, and must not be
+// modified.
+func FromContext(ctx context.Context) (*CookieMap, error) {
+	p, ok := ctx.Value(parsedKey{}).(*parsed)
+	if !ok {
+		return nil, ErrNotParsed
+	}
+	return p.m, nil
+}
+
+// ParseRequest returns an http.Handler which calls WithParsed on each request
+// before passing it on to next.
+func ParseRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithParsed(r.Context(), r)))
+	})
+}
+
+// GetInt returns the value of the first cookie with the given name, parsed
+// as a base 10 integer.
+func (m *CookieMap) GetInt(name string) (int64, error) {
+	v, ok := m.Get(name)
+	if !ok {
+		return 0, ErrNoCookie
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// GetBool returns the value of the first cookie with the given name, parsed
+// by strconv.ParseBool.
+func (m *CookieMap) GetBool(name string) (bool, error) {
+	v, ok := m.Get(name)
+	if !ok {
+		return false, ErrNoCookie
+	}
+	return strconv.ParseBool(v)
+}
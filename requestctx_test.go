@@ -0,0 +1,70 @@
+package cookie
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithParsed(t *testing.T) {
+	if _, err := FromContext(context.Background()); err != ErrNotParsed {
+		t.Errorf("FromContext(Background) returned %v, want ErrNotParsed", err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("Cookie", "a=1; b=true")
+	r.Header.Add("Cookie", "c=x")
+
+	var calls int
+	h := ParseRequest(ParseRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		m, err := FromContext(r.Context())
+		if err != nil {
+			t.Fatalf("FromContext: %v", err)
+		}
+		if m.Len() != 3 {
+			t.Errorf("Len() = %d, want 3", m.Len())
+		}
+		if v, err := m.GetInt("a"); v != 1 || err != nil {
+			t.Errorf("GetInt(a) = %d, %v, want 1, nil", v, err)
+		}
+		if v, err := m.GetBool("b"); !v || err != nil {
+			t.Errorf("GetBool(b) = %v, %v, want true, nil", v, err)
+		}
+		if _, err := m.GetInt("c"); err == nil {
+			t.Errorf("GetInt(c) returned no error")
+		}
+		if _, err := m.GetInt("d"); err != ErrNoCookie {
+			t.Errorf("GetInt(d) returned %v, want ErrNoCookie", err)
+		}
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	// Parsing should only happen once per context and header.
+	ctx := WithParsed(context.Background(), r)
+	first, _ := FromContext(ctx)
+	if again, _ := FromContext(WithParsed(ctx, r)); again != first {
+		t.Errorf("WithParsed parsed the header a second time")
+	}
+	r.Header.Set("Cookie", "z=9")
+	if m, _ := FromContext(WithParsed(ctx, r)); m.Encode() != "z=9" {
+		t.Errorf("WithParsed kept %#q after the header changed", m.Encode())
+	}
+
+	r.Header.Set("Cookie", "a=1; b")
+	if m, err := FromContext(WithParsed(context.Background(), r)); m == nil || m.Encode() != "a=1" || err != nil {
+		t.Errorf("FromContext = %v, %v for an invalid header, want the valid pairs", m, err)
+	}
+
+	// Requests without cookies yield an empty map, never a nil one.
+	r.Header.Del("Cookie")
+	if m, err := FromContext(WithParsed(context.Background(), r)); m == nil || m.Len() != 0 || err != nil {
+		t.Errorf("FromContext = %v, %v for a request without cookies", m, err)
+	}
+}
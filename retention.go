@@ -0,0 +1,64 @@
+package cookie
+
+import (
+	"strings"
+	"time"
+)
+
+// A RetentionRule overrides the lifetime of cookies set by matching hosts.
+type RetentionRule struct {
+	// Host the rule applies to. A "*." prefix makes the rule apply to the
+	// domain itself as well as all of its subdomains.
+	Host string
+
+	// MaxTTL caps the lifetime of matching cookies, including session
+	// cookies. A zero value means no limit.
+	MaxTTL time.Duration
+
+	// SessionTTL, if non-zero, makes matching session cookies persist for
+	// the specified duration.
+	SessionTTL time.Duration
+}
+
+// matches returns true if the rule applies to cookies set by host.
+func (r *RetentionRule) matches(host string) bool {
+	pattern := strings.ToLower(r.Host)
+	if strings.HasPrefix(pattern, "*.") {
+		pattern = pattern[2:]
+		return host == pattern || hasDotSuffix(host, pattern)
+	}
+	return host == pattern
+}
+
+// AddRetentionRule adds a retention rule to the jar. Rules are evaluated by
+// SetCookie in the order they were added, and only the first matching rule
+// is applied.
+func (j *Jar) AddRetentionRule(rule RetentionRule) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.rules = append(j.rules, rule)
+}
+
+// applyRetention adjusts the expiration time of a new entry according to the
+// first retention rule matching host.
+func (j *Jar) applyRetention(host string, entry *jarEntry, now time.Time) {
+	for i := range j.rules {
+		r := &j.rules[i]
+		if !r.matches(host) {
+			continue
+		}
+
+		if entry.Expires.IsZero() && r.SessionTTL > 0 {
+			entry.Expires = now.Add(r.SessionTTL)
+		}
+
+		if r.MaxTTL > 0 {
+			if max := now.Add(r.MaxTTL); entry.Expires.IsZero() || entry.Expires.After(max) {
+				entry.Expires = max
+			}
+		}
+
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package cookie
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	errRewrapInvalid = errors.New("rewrapped value is not a valid cookie value")
+)
+
+// A RewrapError is returned by Rewrap when the values of one or more entries
+// couldn't be transformed, in which case none of the jar's entries are
+// modified.
+type RewrapError struct {
+	// The entries that failed, in the order returned by Entries.
+	Failures []RewrapFailure
+}
+
+// A RewrapFailure describes an entry whose value couldn't be transformed.
+type RewrapFailure struct {
+	Entry Entry
+	Err   error
+}
+
+func (e *RewrapError) Error() string {
+	f := e.Failures[0]
+	msg := "cookie.Rewrap: " + f.Entry.Domain + f.Entry.Path + " " + f.Entry.Name + ": " + f.Err.Error()
+	if len(e.Failures) > 1 {
+		msg += " (and " + strconv.Itoa(len(e.Failures)-1) + " more)"
+	}
+	return msg
+}
+
+// Rewrap replaces the value of every entry in the jar with the result of
+// calling fn on it, for example to re-encrypt values after a key rotation.
+// The transformation is transactional: if fn returns an error for any entry,
+// or a value that isn't a valid cookie value, no entries are modified and a
+// *RewrapError listing every failed entry is returned. Otherwise, entries
+// whose values changed are updated and observers are notified of all changes
+// in a single call.
+//
+// fn is called with the jar locked, and must not call the jar's methods.
+func (j *Jar) Rewrap(fn func(old string) (string, error)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var updated []*jarEntry
+	var failures []RewrapFailure
+
+	for _, entry := range j.sorted() {
+		value, err := fn(entry.Value)
+		if err == nil && !isValidValue(value) {
+			err = errRewrapInvalid
+		}
+		if err != nil {
+			failures = append(failures, RewrapFailure{Entry: entry.Entry, Err: err})
+			continue
+		}
+
+		if value != entry.Value {
+			e := *entry
+			e.Value = value
+			updated = append(updated, &e)
+		}
+	}
+
+	if len(failures) > 0 {
+		return &RewrapError{Failures: failures}
+	}
+
+	now := j.clockNow()
+
+	j.batched(func() {
+		for _, entry := range updated {
+			j.set(entry, now)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cookie
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// headerRewriter is a http.ResponseWriter which calls rewrite on the response
+// headers once, right before they're written, however that comes about. It's
+// shared by the handlers rewriting "Set-Cookie" headers.
+type headerRewriter struct {
+	http.ResponseWriter
+	rewrite func(header http.Header)
+	done    bool
+}
+
+// before rewrites the headers, unless already done.
+func (w *headerRewriter) before() {
+	if !w.done {
+		w.done = true
+		w.rewrite(w.Header())
+	}
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *headerRewriter) WriteHeader(code int) {
+	w.before()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *headerRewriter) Write(b []byte) (int, error) {
+	w.before()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *headerRewriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError flushes the response, for http.ResponseController, which would
+// otherwise reach past the writer using Unwrap and write the headers before
+// they're rewritten.
+func (w *headerRewriter) FlushError() error {
+	w.before()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, rewriting the headers first, as FlushError
+// does.
+func (w *headerRewriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.before()
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *headerRewriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
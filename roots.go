@@ -0,0 +1,169 @@
+package cookie
+
+// maxRoots is the maximum number of entries in a jar's domain root cache.
+const maxRoots = 4096
+
+// root returns the domain root for a host, consulting the jar's cache before
+// falling back to domainRoot.
+func (j *Jar) root(host string) string {
+	j.rootsMu.Lock()
+	defer j.rootsMu.Unlock()
+
+	if root, ok := j.roots[host]; ok {
+		return root
+	}
+
+	// Rather than keeping track of which hosts were used least recently,
+	// start over when the cache fills up.
+	if len(j.roots) >= maxRoots {
+		j.roots = make(map[string]string)
+	}
+
+	root := domainRoot(host, j.psl)
+	j.roots[host] = root
+
+	return root
+}
+
+// SetPublicSuffixList replaces the jar's public suffix list. Cached domain
+// roots are discarded, and existing entries are moved to the buckets of
+// their new domain roots.
+func (j *Jar) SetPublicSuffixList(psl PublicSuffixList) {
+	j.SetPublicSuffixListWithOptions(psl, nil)
+}
+
+// PSLOptions controls the behavior of SetPublicSuffixListWithOptions.
+type PSLOptions struct {
+	// RemoveCrossSite deletes domain cookies whose Domain attribute is a
+	// public suffix under the new list, which would otherwise be sent to
+	// every site below it. Their removal is reported to observers.
+	RemoveCrossSite bool
+}
+
+// A Rescope describes an entry affected by a new public suffix list, either
+// because its domain root changed, widening or narrowing the set of hosts
+// considered same-site with it, or because its Domain became (or ceased to
+// be) a public suffix.
+type Rescope struct {
+	Entry Entry
+
+	// Domain roots under the old and the new list.
+	OldRoot string
+	NewRoot string
+
+	// CrossSite is true if the entry is a domain cookie whose Domain is a
+	// public suffix under the new list.
+	CrossSite bool
+
+	// Removed is true if the entry was deleted because of RemoveCrossSite.
+	Removed bool
+}
+
+// SetPublicSuffixListWithOptions is like SetPublicSuffixList, but reports
+// the entries affected by the change, sorted by their new domain root,
+// domain, path and name. Passing nil options is equivalent to calling
+// SetPublicSuffixList.
+func (j *Jar) SetPublicSuffixListWithOptions(psl PublicSuffixList, opts *PSLOptions) (rescoped []Rescope) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if opts == nil {
+		opts = &PSLOptions{}
+	}
+
+	profile("Jar.SetPublicSuffixList", func() { rescoped = j.setPublicSuffixList(psl, opts) })
+	return
+}
+
+// setPublicSuffixList implements SetPublicSuffixListWithOptions.
+func (j *Jar) setPublicSuffixList(psl PublicSuffixList, opts *PSLOptions) []Rescope {
+	j.rootsMu.Lock()
+	oldPSL := j.psl
+	j.psl = psl
+	j.roots = make(map[string]string)
+	j.rootsMu.Unlock()
+
+	// Entries may move between buckets, so preserve all of them for
+	// ongoing iterations.
+	for root := range j.ent {
+		j.preserve(root)
+	}
+
+	old := j.ent
+	j.ent = make(map[string]map[string]*jarEntry)
+
+	if j.paths != nil {
+		j.paths = make(pathIndex)
+	}
+
+	var moved, doomed []*jarEntry
+	oldRoots := make(map[*jarEntry]string)
+
+	for _, bucket := range old {
+		for _, entry := range bucket {
+			root := j.root(entry.Domain)
+			if root != entry.Root || !entry.HostOnly &&
+				isPublicSuffix(entry.Domain, oldPSL) != isPublicSuffix(entry.Domain, psl) {
+				moved = append(moved, entry)
+				oldRoots[entry] = entry.Root
+			}
+
+			entry.Root = root
+			j.insert(entry)
+		}
+	}
+
+	sortEntries(moved)
+
+	rescoped := make([]Rescope, len(moved))
+	for i, entry := range moved {
+		r := Rescope{
+			Entry:     entry.Entry,
+			OldRoot:   oldRoots[entry],
+			NewRoot:   entry.Root,
+			CrossSite: !entry.HostOnly && isPublicSuffix(entry.Domain, psl),
+		}
+		if r.CrossSite && opts.RemoveCrossSite {
+			r.Removed = true
+			doomed = append(doomed, entry)
+		}
+		rescoped[i] = r
+	}
+
+	if len(doomed) > 0 {
+		now := j.clockNow()
+
+		j.batched(func() {
+			for _, entry := range doomed {
+				j.remove(entry, now)
+			}
+		})
+	}
+
+	return rescoped
+}
+
+// isPublicSuffix returns true if domain is a public suffix according to psl.
+func isPublicSuffix(domain string, psl PublicSuffixList) bool {
+	if psl == nil {
+		return false
+	}
+	suffix := psl.PublicSuffix(domain)
+	return suffix != "" && !hasDotSuffix(domain, suffix)
+}
+
+// insert adds an entry to its bucket (and the path index, if enabled)
+// without treating it as a change.
+func (j *Jar) insert(entry *jarEntry) {
+	bucket, ok := j.ent[entry.Root]
+	if !ok {
+		bucket = make(map[string]*jarEntry)
+		j.ent[entry.Root] = bucket
+	}
+
+	bucket[entry.Key] = entry
+
+	if j.paths != nil {
+		j.paths.add(entry)
+	}
+}
@@ -0,0 +1,91 @@
+package cookie
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is the interface implemented by Jar, View and JarRouter.
+type CookieJar interface {
+	Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error)
+	SetCookie(scheme, host, path string, c *Cookie, now time.Time) error
+}
+
+// A JarRouter dispatches cookies to different jars by domain root, making it
+// possible to apply different policies to different sites within a single
+// client, such as persisting the cookies of allowlisted sites while keeping
+// those of every other site in an ephemeral jar.
+//
+// Cookies are routed by the domain root of the host setting or requesting
+// them (e.g. "example.com" for "www.example.com"), so cookies shared between
+// hosts under the same root always end up in the same jar.
+//
+// A JarRouter is safe for concurrent use, including adding routes while it's
+// in use, provided that the jars it dispatches to are.
+type JarRouter struct {
+	psl      PublicSuffixList
+	fallback CookieJar
+
+	mu     sync.RWMutex
+	routes map[string]CookieJar
+}
+
+// NewJarRouter creates a JarRouter which determines domain roots using psl,
+// and dispatches cookies for domain roots without a route to fallback.
+func NewJarRouter(psl PublicSuffixList, fallback CookieJar) *JarRouter {
+	return &JarRouter{
+		psl:      psl,
+		routes:   make(map[string]CookieJar),
+		fallback: fallback,
+	}
+}
+
+// Route dispatches cookies for hosts under the given domain root to j,
+// replacing any previous route for it. A leading dot is ignored.
+func (r *JarRouter) Route(root string, j CookieJar) {
+	root = strings.ToLower(strings.TrimPrefix(root, "."))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[root] = j
+}
+
+// Jar returns the jar cookies for host are dispatched to.
+func (r *JarRouter) Jar(host string) (CookieJar, error) {
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	root := domainRoot(host, r.psl)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if j, ok := r.routes[root]; ok {
+		return j, nil
+	}
+	return r.fallback, nil
+}
+
+// Cookies returns a slice of cookies relevant for the scheme, host and path
+// combination, from the jar the host is routed to.
+func (r *JarRouter) Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error) {
+	j, err := r.Jar(host)
+	if err != nil {
+		return nil, err
+	}
+	return j.Cookies(scheme, host, path, now)
+}
+
+// SetCookie updates the jar the host is routed to with a cookie from a
+// "Set-Cookie" header.
+func (r *JarRouter) SetCookie(scheme, host, path string, c *Cookie, now time.Time) error {
+	j, err := r.Jar(host)
+	if err != nil {
+		return err
+	}
+	return j.SetCookie(scheme, host, path, c, now)
+}
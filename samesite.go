@@ -0,0 +1,150 @@
+package cookie
+
+import (
+	"strings"
+	"time"
+)
+
+// SameSite describes a cookie's SameSite restriction, as specified by
+// draft-ietf-httpbis-rfc6265bis.
+type SameSite int
+
+const (
+	// SameSiteDefault means the cookie carries no (valid) SameSite
+	// attribute, in which case the jar's default applies.
+	SameSiteDefault SameSite = iota
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+// String returns the attribute value corresponding to s, or an empty string
+// for SameSiteDefault.
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteLax:
+		return "Lax"
+	case SameSiteStrict:
+		return "Strict"
+	case SameSiteNone:
+		return "None"
+	}
+	return ""
+}
+
+// parseSameSite parses a SameSite attribute value, case-insensitively.
+// Unrecognized values yield SameSiteDefault.
+func parseSameSite(val string) SameSite {
+	switch {
+	case strings.EqualFold(val, "Lax"):
+		return SameSiteLax
+	case strings.EqualFold(val, "Strict"):
+		return SameSiteStrict
+	case strings.EqualFold(val, "None"):
+		return SameSiteNone
+	}
+	return SameSiteDefault
+}
+
+// sameSiteOf returns the SameSite restriction of a cookie. If its SameSite
+// field is unset, the attribute is looked for among the unparsed ones, in
+// case the cookie was built by hand.
+func sameSiteOf(c *Cookie) SameSite {
+	if c.SameSite != SameSiteDefault {
+		return c.SameSite
+	}
+	for _, attr := range c.Unparsed {
+		key, val := attr, ""
+		if eq := strings.IndexByte(attr, '='); eq >= 0 {
+			key, val = trim(attr[:eq]), trim(attr[eq+1:])
+		}
+		if strings.EqualFold(key, "SameSite") {
+			return parseSameSite(val)
+		}
+	}
+	return SameSiteDefault
+}
+
+// A RequestContext describes the circumstances of a request, for the purpose
+// of enforcing SameSite restrictions. The zero value describes a same-site
+// request, to which all cookies are sent.
+type RequestContext struct {
+	// CrossSite is true if the request was initiated by a different site
+	// than the one it's made to.
+	CrossSite bool
+
+	// TopLevel is true if the request is a top-level navigation, such as a
+	// user following a link.
+	TopLevel bool
+
+	// The request's HTTP method. Defaults to GET.
+	Method string
+
+	// Site of the top-level document the request is made from, such as
+	// "https://example.com", which determines the partition of partitioned
+	// cookies. Defaults to the site of the request itself.
+	TopLevelSite string
+}
+
+// allows returns true if a cookie with the given SameSite restriction may be
+// sent with a request made in the context.
+func (ctx *RequestContext) allows(s SameSite) bool {
+	if !ctx.CrossSite {
+		return true
+	}
+
+	switch s {
+	case SameSiteNone:
+		return true
+	case SameSiteLax:
+		switch ctx.Method {
+		case "", "GET", "HEAD", "OPTIONS", "TRACE":
+			return ctx.TopLevel
+		}
+	}
+
+	return false
+}
+
+// SetDefaultSameSite sets the restriction applied to cookies without a
+// SameSite attribute. The default, SameSiteNone, matches the behavior of
+// legacy browsers, while SameSiteLax matches modern browsers, which treat
+// such cookies as "Lax by default".
+func (j *Jar) SetDefaultSameSite(s SameSite) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.sameSite = s
+}
+
+// CookiesInContext is like Cookies, but omits cookies whose SameSite
+// restriction forbids sending them in the request context, as well as
+// partitioned cookies set in the context of another top-level site. Cookies is
+// equivalent to calling CookiesInContext with a zero RequestContext.
+func (j *Jar) CookiesInContext(scheme, host, path string, ctx RequestContext, now time.Time) ([]*Cookie, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.entries(scheme, host, path, ctx.TopLevelSite, now, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies []*Cookie
+	for _, entry := range entries {
+		s := entry.SameSite
+		if s == SameSiteDefault {
+			s = j.sameSite
+		}
+		if !ctx.allows(s) {
+			continue
+		}
+		j.sent(entry, now)
+		cookies = append(cookies, &Cookie{
+			Name:  entry.Name,
+			Value: entry.Value,
+		})
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,132 @@
+package cookie
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	errSaveVersion = errors.New("unsupported jar file version")
+)
+
+// saveVersion is the version of the format written by Jar.Save.
+const saveVersion = 1
+
+// savedJar is the JSON representation of a jar written by Jar.Save.
+type savedJar struct {
+	Version int          `json:"version"`
+	Entries []savedEntry `json:"entries"`
+}
+
+// savedEntry is the JSON representation of a jar entry.
+type savedEntry struct {
+	Name      string            `json:"name"`
+	Value     string            `json:"value"`
+	Domain    string            `json:"domain"`
+	Path      string            `json:"path"`
+	HostOnly  bool              `json:"hostOnly,omitempty"`
+	Secure    bool              `json:"secure,omitempty"`
+	HttpOnly  bool              `json:"httpOnly,omitempty"`
+	SameSite  string            `json:"sameSite,omitempty"`
+	Created   time.Time         `json:"created"`
+	Expires   time.Time         `json:"expires"`
+	LastUsed  time.Time         `json:"lastUsed,omitzero"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Unparsed  []string          `json:"unparsed,omitempty"`
+	Partition string            `json:"partition,omitempty"`
+}
+
+// Save writes the jar's persistent, unexpired entries to w as JSON, so that
+// they can be restored with LoadJar, for example by a long-running crawler
+// being restarted. Session cookies are left out, as are send counts, though
+// the time each entry was last sent is kept. Entries are written sorted by
+// domain root, domain, path and name, so saving the same entries always
+// produces the same output. Expiry is judged using the jar's clock (see
+// SetClock).
+func (j *Jar) Save(w io.Writer) error {
+	s := j.saved(j.now())
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// saved returns the representation of the jar written by Save.
+func (j *Jar) saved(now time.Time) *savedJar {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	s := &savedJar{Version: saveVersion, Entries: []savedEntry{}}
+
+	for _, entry := range j.sorted() {
+		if entry.Expires.IsZero() || !entry.Expires.After(now) {
+			continue
+		}
+
+		s.Entries = append(s.Entries, savedEntry{
+			Name:      entry.Name,
+			Value:     entry.Value,
+			Domain:    entry.Domain,
+			Path:      entry.Path,
+			HostOnly:  entry.HostOnly,
+			Secure:    entry.Secure,
+			HttpOnly:  entry.HttpOnly,
+			SameSite:  entry.SameSite.String(),
+			Created:   entry.Created.UTC(),
+			Expires:   entry.Expires.UTC(),
+			LastUsed:  entry.LastUsed.UTC(),
+			Labels:    entry.Labels,
+			Unparsed:  entry.Unparsed,
+			Partition: entry.Partition,
+		})
+	}
+
+	return s
+}
+
+// LoadJar creates a new jar using psl, and fills it with the entries written
+// by Save. Entries which have expired since they were saved are ignored.
+func LoadJar(r io.Reader, psl PublicSuffixList) (*Jar, error) {
+	var s savedJar
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Version != saveVersion {
+		return nil, errSaveVersion
+	}
+
+	now := Now()
+	entries := make([]Entry, 0, len(s.Entries))
+
+	for _, e := range s.Entries {
+		if !e.Expires.After(now) {
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Created:   e.Created,
+			Expires:   e.Expires,
+			LastUsed:  e.LastUsed,
+			HostOnly:  e.HostOnly,
+			SameSite:  parseSameSite(e.SameSite),
+			Name:      e.Name,
+			Value:     e.Value,
+			Domain:    e.Domain,
+			Path:      e.Path,
+			Secure:    e.Secure,
+			HttpOnly:  e.HttpOnly,
+			Labels:    e.Labels,
+			Unparsed:  e.Unparsed,
+			Partition: e.Partition,
+		})
+	}
+
+	j := NewJar(psl)
+	if _, err := j.Import(entries, nil); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
@@ -0,0 +1,64 @@
+package cookie
+
+import (
+	"strings"
+)
+
+// A Scanner parses the cookies in a "Cookie" request header one at a time,
+// making it cheap to look for a single cookie in a long header. Like
+// ParseRequestHeader, it skips invalid pairs.
+//
+//	s := cookie.NewScanner(header)
+//	for s.Next() {
+//		if s.Cookie().Name == "session" {
+//			break
+//		}
+//	}
+type Scanner struct {
+	raw    string
+	cookie *Cookie
+}
+
+// NewScanner returns a Scanner reading from the value of a "Cookie" header.
+func NewScanner(header string) *Scanner {
+	return &Scanner{raw: header}
+}
+
+// Next parses the next cookie in the header, making it available through the
+// Cookie method. It returns false when there are no more cookies.
+func (s *Scanner) Next() bool {
+	s.cookie = nil
+
+	for s.raw != "" {
+		var part string
+
+		if i := strings.IndexByte(s.raw, ';'); i < 0 {
+			part, s.raw = s.raw, ""
+		} else {
+			part, s.raw = s.raw[:i], s.raw[i+1:]
+		}
+
+		if part = trim(part); part == "" {
+			continue
+		}
+
+		c := new(Cookie)
+		if parsePair(c, part, defaultParseOptions) == nil {
+			s.cookie = c
+			return true
+		}
+	}
+
+	return false
+}
+
+// Cookie returns the cookie parsed by the latest call to Next.
+func (s *Scanner) Cookie() *Cookie {
+	return s.cookie
+}
+
+// Err always returns nil, since invalid cookies are skipped. It is kept for
+// compatibility.
+func (s *Scanner) Err() error {
+	return nil
+}
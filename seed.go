@@ -0,0 +1,64 @@
+package cookie
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// SetSeed makes the jar deterministic, for simulations and property-based
+// tests which must reproduce exactly across runs and platforms. Go randomizes
+// the iteration order of maps, which by default shows through in the order
+// of the cookies returned by Cookies and CookiesAt, and in the order in which
+// expired entries are swept and so the revisions of their removals. Once a
+// seed is set, entries are instead visited in a pseudo-random order derived
+// from the seed and their domain, path and name, so that different seeds
+// still exercise different orders.
+//
+// Everything else the jar does is already deterministic: evictions break ties
+// between equally ranked entries by revision, and exports are sorted. Jars
+// used for simulations should also be given a clock using SetClock.
+func (j *Jar) SetSeed(seed int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seed = uint64(seed)
+	j.seeded = true
+}
+
+// ordered sorts entries in the order derived from the jar's seed, if it has
+// one, and otherwise leaves them as they are.
+func (j *Jar) ordered(entries []*jarEntry) {
+	if !j.seeded || len(entries) < 2 {
+		return
+	}
+
+	keys := make(map[*jarEntry]uint64, len(entries))
+	for _, entry := range entries {
+		keys[entry] = j.seedHash(entry)
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		x, y := entries[a], entries[b]
+		if kx, ky := keys[x], keys[y]; kx != ky {
+			return kx < ky
+		}
+		if x.Root != y.Root {
+			return x.Root < y.Root
+		}
+		return x.Key < y.Key
+	})
+}
+
+// seedHash returns the sort key of an entry under the jar's seed.
+func (j *Jar) seedHash(entry *jarEntry) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], j.seed)
+
+	h := fnv.New64a()
+	h.Write(b[:])
+	h.Write([]byte(entry.Root))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Key))
+	return h.Sum64()
+}
@@ -0,0 +1,77 @@
+package cookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrBadSignature is returned by Signer.Verify when a cookie's value carries
+// no signature, or one which none of the signer's keys produced.
+var ErrBadSignature = errors.New("cookie: invalid signature")
+
+var (
+	errNoSignerKey = errors.New("no signing key")
+)
+
+// A Signer signs cookie values using HMAC-SHA256, so that applications can
+// detect values tampered with by clients. A signed value consists of the
+// original value, a dot and the base64url-encoded signature, which covers the
+// cookie's name as well, so that a signed value can't be moved to another
+// cookie. Signing doesn't hide the value from clients.
+type Signer struct {
+	// Keys used to verify signatures, the first of which is also used to
+	// sign cookies. Keys should be at least 32 random bytes. To rotate keys,
+	// prepend a new key and keep the old ones around until the cookies
+	// signed with them have expired.
+	Keys [][]byte
+}
+
+// NewSigner returns a Signer signing cookies with key, and verifying them with
+// key or any of the older keys.
+func NewSigner(key []byte, old ...[]byte) *Signer {
+	return &Signer{Keys: append([][]byte{key}, old...)}
+}
+
+// Sign appends a signature to the value of a cookie, using the first key.
+func (s *Signer) Sign(c *Cookie) error {
+	if len(s.Keys) == 0 || len(s.Keys[0]) == 0 {
+		return errNoSignerKey
+	}
+
+	c.Value += "." + signValue(s.Keys[0], c.Name, c.Value)
+	return nil
+}
+
+// Verify checks the signature of a cookie's value against each of the keys,
+// and removes it if it's valid. Otherwise, it returns ErrBadSignature and
+// leaves the cookie unchanged.
+func (s *Signer) Verify(c *Cookie) error {
+	dot := strings.LastIndexByte(c.Value, '.')
+	if dot < 0 {
+		return ErrBadSignature
+	}
+
+	value, sig := c.Value[:dot], c.Value[dot+1:]
+
+	for _, key := range s.Keys {
+		if len(key) > 0 && hmac.Equal([]byte(sig), []byte(signValue(key, c.Name, value))) {
+			c.Value = value
+			return nil
+		}
+	}
+
+	return ErrBadSignature
+}
+
+// signValue returns the base64url-encoded HMAC-SHA256 signature of a cookie's
+// name and value.
+func signValue(key []byte, name, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'='})
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
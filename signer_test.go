@@ -0,0 +1,64 @@
+package cookie
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	oldKey := []byte("fedcba9876543210fedcba9876543210")
+	newKey := []byte("0123456789abcdef0123456789abcdef")
+
+	old := NewSigner(oldKey)
+	s := NewSigner(newKey, oldKey)
+
+	c := &Cookie{Name: "session", Value: "user.42", Path: "/"}
+	if err := s.Sign(c); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !strings.HasPrefix(c.Value, "user.42.") || !isValidValue(c.Value) {
+		t.Fatalf("Sign produced value %q", c.Value)
+	}
+	signed := c.Value
+
+	// Signed values must survive a round trip through a header.
+	raw, err := c.Marshal(true)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if c, err = Parse(raw); err != nil {
+		t.Fatalf("Parse(%#q): %v", raw, err)
+	}
+
+	if err := s.Verify(c); err != nil || c.Value != "user.42" {
+		t.Errorf("Verify returned %v, value %q", err, c.Value)
+	}
+
+	// Cookies signed with a retired key still verify, but only with signers
+	// which know it.
+	c = &Cookie{Name: "session", Value: "user.42"}
+	old.Sign(c)
+	if err := s.Verify(&Cookie{Name: c.Name, Value: c.Value}); err != nil {
+		t.Errorf("Verify rejected a cookie signed with an old key: %v", err)
+	}
+	if err := NewSigner(newKey).Verify(&Cookie{Name: c.Name, Value: c.Value}); err != ErrBadSignature {
+		t.Errorf("Verify accepted a cookie signed with an unknown key: %v", err)
+	}
+
+	tampered := []*Cookie{
+		{Name: "session", Value: "user.43" + signed[len("user.42"):]},
+		{Name: "other", Value: signed},
+		{Name: "session", Value: "user"},
+		{Name: "session", Value: signed + "x"},
+	}
+	for _, c := range tampered {
+		value := c.Value
+		if err := s.Verify(c); err != ErrBadSignature || c.Value != value {
+			t.Errorf("Verify(%s=%s) returned %v, value %q", c.Name, value, err, c.Value)
+		}
+	}
+
+	if err := (&Signer{}).Sign(&Cookie{Name: "a", Value: "b"}); err == nil {
+		t.Errorf("Sign succeeded without a key")
+	}
+}
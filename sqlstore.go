@@ -0,0 +1,297 @@
+package cookie
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errSchemaVersion = errors.New("cookie: database schema is newer than supported")
+)
+
+// sqlMigrations bring the database used by SQLStore up to date, each entry
+// upgrading it by one version. The version of a database is kept in SQLite's
+// user_version pragma. Databases created before it was are at version 0, but
+// already hold the table of version 1, so that the first migration only
+// creates it if it doesn't exist.
+//
+// Migrations must never be changed once released. New columns are added by
+// appending a migration, with a default for the rows already stored.
+var sqlMigrations = [][]string{
+	// Version 1. The primary key doubles as the index used to look up entries
+	// by domain root.
+	{
+		`CREATE TABLE IF NOT EXISTS cookies (
+			root      TEXT    NOT NULL,
+			domain    TEXT    NOT NULL,
+			path      TEXT    NOT NULL,
+			name      TEXT    NOT NULL,
+			value     TEXT    NOT NULL,
+			created   INTEGER NOT NULL,
+			expires   INTEGER NOT NULL,
+			host_only INTEGER NOT NULL,
+			secure    INTEGER NOT NULL,
+			http_only INTEGER NOT NULL,
+			same_site INTEGER NOT NULL,
+			labels    TEXT    NOT NULL,
+			PRIMARY KEY (root, domain, path, name)
+		)`,
+	},
+
+	// Version 2 adds host_only to the primary key, since a jar may hold both
+	// a host-only and a domain cookie with the same domain, path and name.
+	// SQLite can't alter a table's primary key, so the table is rebuilt.
+	{
+		`CREATE TABLE cookies_v2 (
+			root      TEXT    NOT NULL,
+			domain    TEXT    NOT NULL,
+			path      TEXT    NOT NULL,
+			name      TEXT    NOT NULL,
+			value     TEXT    NOT NULL,
+			created   INTEGER NOT NULL,
+			expires   INTEGER NOT NULL,
+			host_only INTEGER NOT NULL,
+			secure    INTEGER NOT NULL,
+			http_only INTEGER NOT NULL,
+			same_site INTEGER NOT NULL,
+			labels    TEXT    NOT NULL,
+			PRIMARY KEY (root, domain, path, name, host_only)
+		)`,
+		`INSERT INTO cookies_v2 SELECT * FROM cookies`,
+		`DROP TABLE cookies`,
+		`ALTER TABLE cookies_v2 RENAME TO cookies`,
+	},
+
+	// Version 3 adds the partition key of partitioned entries, which is also
+	// part of the primary key, as the same cookie may be stored once for
+	// each top-level site. The column isn't called partition, since that's
+	// an SQLite keyword.
+	{
+		`CREATE TABLE cookies_v3 (
+			root      TEXT    NOT NULL,
+			domain    TEXT    NOT NULL,
+			path      TEXT    NOT NULL,
+			name      TEXT    NOT NULL,
+			value     TEXT    NOT NULL,
+			created   INTEGER NOT NULL,
+			expires   INTEGER NOT NULL,
+			host_only INTEGER NOT NULL,
+			secure    INTEGER NOT NULL,
+			http_only INTEGER NOT NULL,
+			same_site INTEGER NOT NULL,
+			labels    TEXT    NOT NULL,
+			partition_key TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (root, domain, path, name, host_only, partition_key)
+		)`,
+		`INSERT INTO cookies_v3 SELECT *, '' FROM cookies`,
+		`DROP TABLE cookies`,
+		`ALTER TABLE cookies_v3 RENAME TO cookies`,
+	},
+
+	// Version 4 adds the entry's unparsed attributes, separated by
+	// semicolons, and its send count and the time it was last sent.
+	{
+		`ALTER TABLE cookies ADD COLUMN unparsed TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE cookies ADD COLUMN sends INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE cookies ADD COLUMN last_used INTEGER NOT NULL DEFAULT 0`,
+	},
+}
+
+const (
+	sqlVersion = `PRAGMA user_version`
+	sqlSelect  = `SELECT domain, path, name, value, created, expires, host_only, secure, http_only, same_site, labels, partition_key, unparsed, sends, last_used FROM cookies`
+	sqlUpsert  = `INSERT OR REPLACE INTO cookies (root, domain, path, name, value, created, expires, host_only, secure, http_only, same_site, labels, partition_key, unparsed, sends, last_used) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	sqlDelete  = `DELETE FROM cookies WHERE root = ? AND domain = ? AND path = ? AND name = ? AND host_only = ? AND partition_key = ?`
+)
+
+// SQLStore persists the contents of a Jar in an SQLite database, accessed
+// through package database/sql. Any SQLite driver may be used; building with
+// the cookie_sqlite tag adds OpenSQLite, which uses a pure-Go driver.
+//
+// SQLStore implements Storage, and Load and Attach are shorthands for
+// LoadStorage and AttachStorage.
+type SQLStore struct {
+	db *sql.DB
+	j  *Jar
+	w  *StorageWriter
+}
+
+// NewSQLStore returns a store backed by db, creating its table if it doesn't
+// already exist, or upgrading it if it was created by an older version.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// migrate runs the migrations a database hasn't seen yet, each in a
+// transaction of its own.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(sqlVersion).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqlMigrations) {
+		return errSchemaVersion
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		stmts := append([]string{}, sqlMigrations[version]...)
+		stmts = append(stmts, sqlVersion+" = "+strconv.Itoa(version+1))
+
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load adds the stored entries to a jar. It should be called before Attach,
+// so that the entries aren't needlessly written back.
+func (s *SQLStore) Load(j *Jar) (err error) {
+	profile("SQLStore.Load", func() { err = LoadStorage(j, s) })
+	return
+}
+
+// Entries returns the stored entries.
+func (s *SQLStore) Entries() ([]Entry, error) {
+	rows, err := s.db.Query(sqlSelect)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+
+	for rows.Next() {
+		var e Entry
+		var created, expires, sameSite, sends, lastUsed int64
+		var labels, unparsed string
+
+		err := rows.Scan(&e.Domain, &e.Path, &e.Name, &e.Value,
+			&created, &expires, &e.HostOnly, &e.Secure, &e.HttpOnly, &sameSite, &labels, &e.Partition,
+			&unparsed, &sends, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Labels, err = decodeLabels(labels); err != nil {
+			return nil, err
+		}
+
+		e.Created = time.Unix(0, created).UTC()
+		e.SameSite = SameSite(sameSite)
+		if expires != 0 {
+			e.Expires = time.Unix(0, expires).UTC()
+		}
+		if unparsed != "" {
+			e.Unparsed = strings.Split(unparsed, ";")
+		}
+		e.Sends = uint64(sends)
+		if lastUsed != 0 {
+			e.LastUsed = time.Unix(0, lastUsed).UTC()
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Attach registers the store as an observer of a jar, so that every change is
+// written to the database, as described for AttachStorage. Changes committed
+// as part of a Tx are written in a single database transaction. A store
+// should only be attached to one jar, and Close should be called once the
+// jar is no longer used.
+func (s *SQLStore) Attach(j *Jar) {
+	s.j = j
+	s.w = AttachStorage(j, s)
+}
+
+// Flush waits for the changes made to the attached jar so far to be written,
+// and returns the first error encountered while writing, if any.
+func (s *SQLStore) Flush() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Flush()
+}
+
+// Close flushes the store and stops writing changes. It doesn't close the
+// database.
+func (s *SQLStore) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Close()
+}
+
+// Err returns the first error encountered while writing changes, if any.
+func (s *SQLStore) Err() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Err()
+}
+
+// Write writes a batch of changes in a single transaction.
+func (s *SQLStore) Write(changes []Change) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		e := &c.Entry
+		root := s.root(e.Domain)
+
+		if c.Removed {
+			_, err = tx.Exec(sqlDelete, root, e.Domain, e.Path, e.Name, e.HostOnly, e.Partition)
+		} else {
+			var expires, lastUsed int64
+			if !e.Expires.IsZero() {
+				expires = e.Expires.UnixNano()
+			}
+			if !e.LastUsed.IsZero() {
+				lastUsed = e.LastUsed.UnixNano()
+			}
+			_, err = tx.Exec(sqlUpsert, root, e.Domain, e.Path, e.Name, e.Value,
+				e.Created.UnixNano(), expires, e.HostOnly, e.Secure, e.HttpOnly, int64(e.SameSite),
+				encodeLabels(e.Labels), e.Partition,
+				strings.Join(e.Unparsed, ";"), int64(e.Sends), lastUsed)
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// root returns the domain root under which a domain's entries are stored,
+// using the attached jar's public suffix list.
+func (s *SQLStore) root(domain string) string {
+	if s.j == nil {
+		return domain
+	}
+	return s.j.root(domain)
+}
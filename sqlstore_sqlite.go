@@ -0,0 +1,27 @@
+//go:build cookie_sqlite
+// +build cookie_sqlite
+
+package cookie
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens (or creates) an SQLite database at path using a pure-Go
+// driver, and returns a store backed by it.
+func OpenSQLite(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewSQLStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
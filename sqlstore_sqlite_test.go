@@ -0,0 +1,103 @@
+//go:build cookie_sqlite
+// +build cookie_sqlite
+
+package cookie
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestSQLiteMigrations runs the schema migrations and SQLStore's statements
+// against a real SQLite database, which the fake driver used by the other
+// tests can't vouch for. Run it with "go test -tags cookie_sqlite".
+func TestSQLiteMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.db")
+
+	// Start from a database created before the schema was versioned.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range sqlMigrations[0] {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("creating version 1 table: %v", err)
+		}
+	}
+	_, err = db.Exec(`INSERT INTO cookies VALUES ('example.com', 'example.com', '/', 'old', '1', ?, 0, 1, 0, 0, 0, '')`, testNow.UnixNano())
+	if err != nil {
+		t.Fatalf("inserting version 1 row: %v", err)
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+
+	var version int
+	if err := s.db.QueryRow(sqlVersion).Scan(&version); err != nil || version != len(sqlMigrations) {
+		t.Errorf("migrated database at version %d, %v, want %d", version, err, len(sqlMigrations))
+	}
+
+	j := NewJar(testPSL{})
+	j.KeepUnparsed()
+	if err := s.Load(j); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cookies, _ := j.Cookies("http", "example.com", "/", testNow); len(cookies) != 1 || cookies[0].Name != "old" {
+		t.Errorf("migrated entries = %+v, want the old cookie", cookies)
+	}
+
+	s.Attach(j)
+
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1", Unparsed: []string{"Priority=High"}}, testNow)
+	j.SetConflictPolicy(ConflictKeepBoth)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "2", Domain: "example.com"}, testNow)
+	for _, site := range []string{"https://a.org", "https://b.org"} {
+		ctx := RequestContext{TopLevelSite: site}
+		j.SetCookieInContext("https", "widget.net", "/", ctx, &Cookie{Name: "p", Value: "1", Secure: true, Partitioned: true}, testNow)
+	}
+	j.Remove("example.com", "/", "old")
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s.db.Close()
+
+	// Reopening the database must not run any migrations again.
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.db.Close()
+
+	loaded := NewJar(testPSL{})
+	loaded.SetConflictPolicy(ConflictKeepBoth)
+	if err := s.Load(loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, want := loaded.Entries(), j.Entries()
+	for _, list := range [][]Entry{got, want} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Value != list[j].Value {
+				return list[i].Value < list[j].Value
+			}
+			return list[i].Partition < list[j].Partition
+		})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded entries:")
+		t.Errorf("  got  %+v", got)
+		t.Errorf("  want %+v", want)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database file: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package cookie
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDB is a database/sql driver understanding just the statements issued
+// by SQLStore.
+type fakeDB struct {
+	rows    map[string][]driver.Value
+	version int64
+	commits int
+}
+
+var fakeDBs = make(map[string]*fakeDB)
+
+func init() {
+	sql.Register("cookie-fake", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return fakeConn{fakeDBs[name]}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{c.db}, nil }
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error   { tx.db.commits++; return nil }
+func (tx fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, sqlVersion+" = "):
+		s.db.version, _ = strconv.ParseInt(s.query[len(sqlVersion+" = "):], 10, 64)
+	case strings.HasPrefix(s.query, "INSERT INTO cookies_"):
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.db.rows[fakeKey(args[:4], args[7], args[12])] = args[1:]
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.db.rows, fakeKey(args[:4], args[4], args[5]))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query == sqlVersion {
+		return &fakeRows{cols: []string{"user_version"}, rows: [][]driver.Value{{s.db.version}}}, nil
+	}
+
+	r := &fakeRows{cols: []string{"domain", "path", "name", "value", "created", "expires", "host_only", "secure", "http_only", "same_site", "labels", "partition_key", "unparsed", "sends", "last_used"}}
+	for _, row := range s.db.rows {
+		r.rows = append(r.rows, row)
+	}
+	return r, nil
+}
+
+func fakeKey(args []driver.Value, hostOnly, partition driver.Value) string {
+	return fmt.Sprint(args[0], ";", args[1], ";", args[2], ";", args[3], ";", hostOnly, ";", partition)
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	fake := &fakeDB{rows: make(map[string][]driver.Value)}
+	fakeDBs[t.Name()] = fake
+
+	db, err := sql.Open("cookie-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	if fake.version != int64(len(sqlMigrations)) || fake.commits != len(sqlMigrations) {
+		t.Errorf("NewSQLStore left version %d after %d commits", fake.version, fake.commits)
+	}
+	fake.commits = 0
+
+	j := NewJar(testPSL{})
+	j.KeepUnparsed()
+	s.Attach(j)
+	defer s.Close()
+
+	j.SetCookie("https", "www.example.com", "/", &Cookie{Name: "a", Value: "1", Secure: true, MaxAge: 60}, testNow)
+	j.SetCookie("http", "www.example.com", "/", &Cookie{Name: "b", Value: "2", Domain: "example.com"}, testNow)
+
+	// Send counts are written along with the next change to the entry.
+	j.Cookies("http", "www.example.com", "/", testNow.Add(time.Second))
+	j.Label("example.com", "/", "b", "account", "alice & bob")
+
+	tx := j.Begin()
+	tx.SetCookie("http", "other.org", "/x", &Cookie{Name: "c", Value: "3", HttpOnly: true, Unparsed: []string{"Priority=High", "Flag"}}, testNow)
+	tx.SetCookie("http", "other.org", "/", &Cookie{Name: "d", Value: "4"}, testNow)
+	tx.Remove("other.org", "/", "d", testNow)
+	tx.Commit()
+
+	// Partitioned cookies are stored once per top-level site.
+	for _, site := range []string{"https://a.org", "https://b.org"} {
+		ctx := RequestContext{TopLevelSite: site}
+		j.SetCookieInContext("https", "widget.net", "/", ctx, &Cookie{Name: "p", Value: site, Secure: true, Partitioned: true}, testNow)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// Changes queued while a transaction is being written are written in the
+	// next, so there may be fewer transactions than modifications.
+	if fake.commits < 1 || fake.commits > 6 {
+		t.Errorf("store committed %d transactions, want 1 to 6", fake.commits)
+	}
+	if len(fake.rows) != 5 {
+		t.Errorf("store holds %d rows, want 5", len(fake.rows))
+	}
+
+	// A jar loaded from the store should have the same entries.
+	loaded := NewJar(testPSL{})
+	if err := s.Load(loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, _ := loaded.ChangesSince(0)
+	want, _ := j.ChangesSince(0)
+
+	entries := func(changes []Change) []Entry {
+		var list []Entry
+		for _, c := range changes {
+			list = append(list, c.Entry)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Name != list[j].Name {
+				return list[i].Name < list[j].Name
+			}
+			return list[i].Partition < list[j].Partition
+		})
+		return list
+	}
+
+	if e := entries(got)[1]; e.Sends != 1 || e.LastUsed.IsZero() {
+		t.Errorf("loaded entry b was sent %d times, last at %v", e.Sends, e.LastUsed)
+	}
+	if !reflect.DeepEqual(entries(got), entries(want)) {
+		t.Errorf("loaded entries:")
+		t.Errorf("  got  %+v", entries(got))
+		t.Errorf("  want %+v", entries(want))
+	}
+
+	if cookies, _ := loaded.Cookies("https", "www.example.com", "/", testNow.Add(time.Minute)); len(cookies) != 1 {
+		t.Errorf("expired entry survived loading: %+v", cookies)
+	}
+}
+
+func TestSQLStoreKeepBoth(t *testing.T) {
+	fake := &fakeDB{rows: make(map[string][]driver.Value)}
+	fakeDBs[t.Name()] = fake
+
+	db, err := sql.Open("cookie-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+
+	j := NewJar(testPSL{})
+	j.SetConflictPolicy(ConflictKeepBoth)
+	s.Attach(j)
+	defer s.Close()
+
+	// A host-only and a domain cookie sharing a domain, path and name are
+	// stored as separate rows, and removing one leaves the other alone.
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "host"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", Value: "domain", Domain: "example.com"}, testNow)
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(fake.rows) != 2 {
+		t.Errorf("store holds %d rows, want 2", len(fake.rows))
+	}
+
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "sid", MaxAge: -1}, testNow)
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	loaded := NewJar(testPSL{})
+	loaded.SetConflictPolicy(ConflictKeepBoth)
+	if err := s.Load(loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, _ := loaded.ChangesSince(0)
+	want, _ := j.ChangesSince(0)
+	if len(got) != len(want) || len(got) != 1 || got[0].Entry.Value != want[0].Entry.Value {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
+
+func TestSQLStoreVersion(t *testing.T) {
+	tests := []struct {
+		version int64
+		commits int
+		err     error
+	}{
+		{0, len(sqlMigrations), nil},
+		{1, len(sqlMigrations) - 1, nil},
+		{int64(len(sqlMigrations)), 0, nil},
+		{int64(len(sqlMigrations)) + 1, 0, errSchemaVersion},
+	}
+
+	for i, test := range tests {
+		name := t.Name() + strconv.Itoa(i)
+		fake := &fakeDB{rows: make(map[string][]driver.Value), version: test.version}
+		fakeDBs[name] = fake
+
+		db, err := sql.Open("cookie-fake", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = NewSQLStore(db)
+		if err != test.err || fake.commits != test.commits {
+			t.Errorf("NewSQLStore at version %d: %d migrations, error %v", test.version, fake.commits, err)
+		} else if err == nil && fake.version != int64(len(sqlMigrations)) {
+			t.Errorf("NewSQLStore at version %d left version %d", test.version, fake.version)
+		}
+
+		db.Close()
+	}
+}
@@ -0,0 +1,176 @@
+package cookie
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Stats summarizes the entries held by a jar.
+type Stats struct {
+	// Number of entries without an expiry time.
+	Session int
+
+	// Number of entries with an expiry time that has not passed.
+	Persistent int
+
+	// Number of entries that have expired, but are yet to be swept by a call
+	// to Cookies or CookieHeader.
+	Expired int
+
+	// Number of entries, of any class, which have never been sent.
+	Unsent int
+}
+
+// Total returns the total number of entries.
+func (s Stats) Total() int {
+	return s.Session + s.Persistent + s.Expired
+}
+
+// Stats returns the number of entries in the jar by expiry class. The counts
+// are maintained as entries are stored and removed, so Stats is cheap enough
+// to be polled frequently, e.g. from a health endpoint.
+//
+// The running counts only ever move entries from Persistent to Expired, up
+// to the latest time Stats has been called with. Calls with an earlier time,
+// such as from a caller with a skewed clock, are answered correctly by
+// counting the entries instead, which takes time proportional to their
+// number, and leave the running counts alone.
+func (j *Jar) Stats(now time.Time) Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.currentStats(now)
+}
+
+// currentStats implements Stats.
+func (j *Jar) currentStats(now time.Time) Stats {
+	if now.Before(j.statsAt) {
+		return j.countStats(now)
+	}
+	j.statsAt = now
+
+	for len(j.expiries) > 0 {
+		entry := j.expiries[0]
+		if entry.Expires.After(now) {
+			break
+		}
+		heap.Pop(&j.expiries)
+
+		// Skip entries that were removed or replaced since they were
+		// counted.
+		if j.ent[entry.Root][entry.Key] != entry || entry.expired {
+			continue
+		}
+
+		entry.expired = true
+		j.stats.Persistent--
+		j.stats.Expired++
+	}
+
+	return j.stats
+}
+
+// countStats counts the jar's entries by expiry class at a time earlier than
+// the running counts have been advanced to.
+func (j *Jar) countStats(now time.Time) Stats {
+	s := Stats{Unsent: j.stats.Unsent}
+
+	for _, bucket := range j.ent {
+		for _, entry := range bucket {
+			switch {
+			case entry.Expires.IsZero():
+				s.Session++
+			case entry.Expires.After(now):
+				s.Persistent++
+			default:
+				s.Expired++
+			}
+		}
+	}
+
+	return s
+}
+
+// count adds an entry to the jar's statistics.
+func (j *Jar) count(entry *jarEntry) {
+	entry.expired = false
+
+	if entry.Sends == 0 {
+		j.stats.Unsent++
+	}
+
+	if entry.Expires.IsZero() {
+		j.stats.Session++
+		return
+	}
+	j.stats.Persistent++
+
+	// The expiry heap keeps removed entries until their expiry time comes
+	// around, so rebuild it once those make up most of it.
+	if n := len(j.expiries); n >= 64 && n > 2*j.stats.Persistent {
+		j.expiries = j.expiries[:0]
+		for _, bucket := range j.ent {
+			for _, e := range bucket {
+				if !e.Expires.IsZero() && !e.expired && e != entry {
+					j.expiries = append(j.expiries, e)
+				}
+			}
+		}
+		heap.Init(&j.expiries)
+	}
+
+	heap.Push(&j.expiries, entry)
+}
+
+// uncount removes an entry from the jar's statistics.
+func (j *Jar) uncount(entry *jarEntry) {
+	if entry.Sends == 0 {
+		j.stats.Unsent--
+	}
+
+	switch {
+	case entry.Expires.IsZero():
+		j.stats.Session--
+	case entry.expired:
+		j.stats.Expired--
+	default:
+		j.stats.Persistent--
+	}
+}
+
+// sent records that an entry has been included in a request made at time now.
+func (j *Jar) sent(entry *jarEntry, now time.Time) {
+	if entry.Sends == 0 {
+		j.stats.Unsent--
+	}
+	entry.Sends++
+	entry.LastUsed = now
+
+	if j.evictions != nil && j.evictions.order == EvictLeastRecentlyUsed {
+		heap.Fix(j.evictions, entry.evictIndex)
+	}
+}
+
+// len returns the number of entries in the jar.
+func (j *Jar) len() int {
+	return j.stats.Total()
+}
+
+// expiryHeap implements heap.Interface, ordering entries by expiry time.
+type expiryHeap []*jarEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].Expires.Before(h[j].Expires) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) {
+	*h = append(*h, x.(*jarEntry))
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
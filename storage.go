@@ -0,0 +1,140 @@
+package cookie
+
+import (
+	"sync"
+)
+
+// A Storage persists the contents of a Jar, such as SQLStore does. Storages
+// are used through LoadStorage and AttachStorage, and need not be safe for
+// concurrent use.
+type Storage interface {
+	// Entries returns the stored entries.
+	Entries() ([]Entry, error)
+
+	// Write applies a batch of changes, as reported to observers of the
+	// jar, all or nothing.
+	Write(changes []Change) error
+}
+
+// LoadStorage adds the entries held by s to a jar. It should be called before
+// AttachStorage, so that the entries aren't needlessly written back.
+func LoadStorage(j *Jar, s Storage) error {
+	entries, err := s.Entries()
+	if err != nil {
+		return err
+	}
+
+	changes := make([]Change, len(entries))
+	for i := range entries {
+		changes[i].Entry = entries[i]
+	}
+
+	return j.Apply(changes)
+}
+
+// A StorageWriter writes the changes made to a jar to a Storage. Observers
+// are called with the jar locked, so rather than writing changes as they're
+// made, which would stall every other user of the jar for the duration, they
+// are queued and written by a goroutine of the writer's own. Changes queued
+// while a write is in progress are written together, so a single call to
+// Write may cover several modifications, but never part of one.
+type StorageWriter struct {
+	s Storage
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	queue   []Change
+	writing bool
+	closed  bool
+	err     error
+}
+
+// AttachStorage registers a StorageWriter as an observer of a jar, so that
+// every change is written to s. A storage should only be attached to one jar,
+// and Close should be called once the jar is no longer used.
+//
+// Since observers can't fail, errors are recorded rather than returned, and
+// the writer stops writing after the first one. Use Err or Flush to check for
+// them.
+func AttachStorage(j *Jar, s Storage) *StorageWriter {
+	w := &StorageWriter{s: s}
+	w.cond.L = &w.mu
+
+	j.Observe(w.enqueue)
+	go w.run()
+
+	return w
+}
+
+// enqueue queues a batch of changes reported by the jar.
+func (w *StorageWriter) enqueue(changes []Change) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || w.err != nil {
+		return
+	}
+	w.queue = append(w.queue, changes...)
+	w.cond.Broadcast()
+}
+
+// run writes queued changes until the writer is closed.
+func (w *StorageWriter) run() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			return
+		}
+
+		changes := w.queue
+		w.queue, w.writing = nil, true
+		w.mu.Unlock()
+
+		err := w.s.Write(changes)
+
+		w.mu.Lock()
+		w.writing = false
+		if err != nil && w.err == nil {
+			w.err, w.queue = err, nil
+		}
+		w.cond.Broadcast()
+	}
+}
+
+// Flush waits for the changes made so far to be written, and returns the
+// first error encountered while writing, if any.
+func (w *StorageWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for len(w.queue) > 0 || w.writing {
+		w.cond.Wait()
+	}
+	return w.err
+}
+
+// Close flushes the writer and stops its goroutine. Changes made to the jar
+// afterwards are no longer written.
+func (w *StorageWriter) Close() error {
+	err := w.Flush()
+
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	return err
+}
+
+// Err returns the first error encountered while writing changes, if any.
+func (w *StorageWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
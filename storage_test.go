@@ -0,0 +1,65 @@
+package cookie
+
+import (
+	"errors"
+	"testing"
+)
+
+// blockingStorage is a Storage whose writes wait for a signal.
+type blockingStorage struct {
+	release chan bool
+	written [][]Change
+	err     error
+}
+
+func (s *blockingStorage) Entries() ([]Entry, error) { return nil, nil }
+
+func (s *blockingStorage) Write(changes []Change) error {
+	<-s.release
+	s.written = append(s.written, changes)
+	return s.err
+}
+
+func TestStorageWriter(t *testing.T) {
+	s := &blockingStorage{release: make(chan bool)}
+
+	j := NewJar(testPSL{})
+	w := AttachStorage(j, s)
+
+	// The jar remains usable while a write is stalled.
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "a", Value: "1"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "b", Value: "2"}, testNow)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "c", Value: "3"}, testNow)
+
+	if cookies, _ := j.Cookies("http", "example.com", "/", testNow); len(cookies) != 3 {
+		t.Fatalf("jar returned %d cookies", len(cookies))
+	}
+
+	close(s.release)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var names string
+	for _, changes := range s.written {
+		for _, c := range changes {
+			names += c.Entry.Name
+		}
+	}
+	if names != "abc" {
+		t.Errorf("wrote changes to %q, want %q", names, "abc")
+	}
+
+	// The writer stops at the first error.
+	s.err = errors.New("disk full")
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "d", Value: "4"}, testNow)
+	if err := w.Flush(); err != s.err {
+		t.Errorf("Flush returned %v", err)
+	}
+
+	n := len(s.written)
+	j.SetCookie("http", "example.com", "/", &Cookie{Name: "e", Value: "5"}, testNow)
+	if err := w.Close(); err != s.err || len(s.written) != n {
+		t.Errorf("Close returned %v after %d writes, want %d", err, len(s.written), n)
+	}
+}
@@ -0,0 +1,95 @@
+package cookie
+
+import (
+	"strings"
+	"time"
+)
+
+// TableOptions controls the output of Table.
+type TableOptions struct {
+	// Redact replaces cookie values with a placeholder.
+	Redact bool
+
+	// Maximum number of value characters included in the preview. Longer
+	// values are truncated. A zero value means no limit.
+	MaxValue int
+}
+
+// TableHeader returns the column titles matching the rows returned by Table.
+func TableHeader() []string {
+	return []string{"Name", "Value", "Domain", "Path", "Expires", "Flags"}
+}
+
+// Table converts a slice of cookies to rows of strings suitable for tabular
+// output. Columns are ordered as in TableHeader.
+func Table(cookies []*Cookie, opts *TableOptions) [][]string {
+	if opts == nil {
+		opts = &TableOptions{}
+	}
+
+	rows := make([][]string, len(cookies))
+
+	for i, c := range cookies {
+		rows[i] = []string{
+			c.Name,
+			previewValue(c.Value, opts),
+			c.Domain,
+			c.Path,
+			formatExpiry(c),
+			formatFlags(c),
+		}
+	}
+
+	return rows
+}
+
+// previewValue formats a cookie value for display.
+func previewValue(s string, opts *TableOptions) string {
+	if opts.Redact {
+		return "[redacted]"
+	}
+	if opts.MaxValue <= 0 || len(s) <= opts.MaxValue {
+		return s
+	}
+
+	// Cut after MaxValue runes, never in the middle of one.
+	n := 0
+	for i := range s {
+		if n == opts.MaxValue {
+			return s[:i] + "..."
+		}
+		n++
+	}
+	return s
+}
+
+// formatExpiry describes when a cookie expires.
+func formatExpiry(c *Cookie) string {
+	switch {
+	case c.MaxAge < 0:
+		return "now"
+	case c.MaxAge > 0:
+		return (time.Duration(c.MaxAge) * time.Second).String()
+	case !c.Expires.IsZero():
+		return c.Expires.UTC().Format(time.RFC3339)
+	}
+	return "session"
+}
+
+// formatFlags lists a cookie's boolean attributes and SameSite restriction.
+func formatFlags(c *Cookie) string {
+	var flags []string
+	if c.HttpOnly {
+		flags = append(flags, "HttpOnly")
+	}
+	if c.Secure {
+		flags = append(flags, "Secure")
+	}
+	if c.SameSite != SameSiteDefault {
+		flags = append(flags, "SameSite="+c.SameSite.String())
+	}
+	if c.Partitioned {
+		flags = append(flags, "Partitioned")
+	}
+	return strings.Join(flags, ",")
+}
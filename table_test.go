@@ -0,0 +1,106 @@
+package cookie
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var tableTests = []struct {
+	in   *Cookie
+	opts *TableOptions
+	out  []string
+}{
+	{
+		&Cookie{Name: "a", Value: "1"},
+		nil,
+		[]string{"a", "1", "", "", "session", ""},
+	},
+	{
+		&Cookie{Name: "sid", Value: "secret", Domain: "example.com", Path: "/", MaxAge: 90, HttpOnly: true, Secure: true},
+		nil,
+		[]string{"sid", "secret", "example.com", "/", "1m30s", "HttpOnly,Secure"},
+	},
+	{
+		&Cookie{Name: "a", Value: "1", MaxAge: -1, Secure: true},
+		nil,
+		[]string{"a", "1", "", "", "now", "Secure"},
+	},
+	{
+		&Cookie{Name: "a", Value: "1", Secure: true, SameSite: SameSiteNone, Partitioned: true},
+		nil,
+		[]string{"a", "1", "", "", "session", "Secure,SameSite=None,Partitioned"},
+	},
+	{
+		&Cookie{Name: "a", Value: "1", SameSite: SameSiteLax},
+		nil,
+		[]string{"a", "1", "", "", "session", "SameSite=Lax"},
+	},
+	{
+		&Cookie{Name: "a", Value: "1", Expires: time.Date(2015, 1, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600))},
+		nil,
+		[]string{"a", "1", "", "", "2015-01-01T00:00:00Z", ""},
+	},
+
+	// Max-Age takes precedence over Expires.
+	{
+		&Cookie{Name: "a", Value: "1", MaxAge: 60, Expires: testNow},
+		nil,
+		[]string{"a", "1", "", "", "1m0s", ""},
+	},
+
+	// Values can be redacted or truncated.
+	{
+		&Cookie{Name: "a", Value: "secret", HttpOnly: true},
+		&TableOptions{Redact: true},
+		[]string{"a", "[redacted]", "", "", "session", "HttpOnly"},
+	},
+	{
+		&Cookie{Name: "a", Value: "0123456789"},
+		&TableOptions{MaxValue: 4},
+		[]string{"a", "0123...", "", "", "session", ""},
+	},
+	{
+		&Cookie{Name: "a", Value: "0123"},
+		&TableOptions{MaxValue: 4},
+		[]string{"a", "0123", "", "", "session", ""},
+	},
+
+	// Truncation counts runes, not bytes.
+	{
+		&Cookie{Name: "a", Value: "h\u00e9llo w\u00f6rld"},
+		&TableOptions{MaxValue: 4},
+		[]string{"a", "h\u00e9ll...", "", "", "session", ""},
+	},
+	{
+		&Cookie{Name: "a", Value: "\u00e9\u00e9\u00e9"},
+		&TableOptions{MaxValue: 3},
+		[]string{"a", "\u00e9\u00e9\u00e9", "", "", "session", ""},
+	},
+}
+
+func TestTableHeader(t *testing.T) {
+	h := TableHeader()
+	h[0] = "changed"
+	if TableHeader()[0] != "Name" {
+		t.Errorf("modifying the slice returned by TableHeader changed later results")
+	}
+}
+
+func TestTable(t *testing.T) {
+	for _, test := range tableTests {
+		rows := Table([]*Cookie{test.in}, test.opts)
+		if len(rows) != 1 || !reflect.DeepEqual(rows[0], test.out) {
+			t.Errorf("Table(%+v, %+v):", test.in, test.opts)
+			t.Errorf("  got  %q", rows)
+			t.Errorf("  want %q", test.out)
+		}
+		if len(rows) == 1 && len(rows[0]) != len(TableHeader()) {
+			t.Errorf("Table returned %d columns, TableHeader has %d", len(rows[0]), len(TableHeader()))
+		}
+	}
+
+	if rows := Table(nil, nil); len(rows) != 0 {
+		t.Errorf("Table(nil) = %q", rows)
+	}
+}
@@ -0,0 +1,176 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidTCF = errors.New("invalid TCF consent string")
+)
+
+// tcfCookieNames are the cookie names conventionally used to store IAB
+// Transparency and Consent Framework (TCF) consent strings.
+var tcfCookieNames = []string{"euconsent-v2", "euconsent"}
+
+// A TCFConsent holds the high-level fields of an IAB Transparency and Consent
+// Framework (TCF) consent string, as decoded by ParseTCF. Vendor consents and
+// publisher restrictions are not decoded.
+type TCFConsent struct {
+	// Version of the consent string format, 1 or 2.
+	Version int
+
+	// When consent was first recorded and last updated.
+	Created     time.Time
+	LastUpdated time.Time
+
+	// Consent management platform which recorded consent, and its version.
+	CMPID      int
+	CMPVersion int
+
+	// Two-letter language code of the consent dialog, such as "EN".
+	Language string
+
+	// Version of the global vendor list in use when consent was recorded.
+	VendorListVersion int
+
+	// Purposes consented to, with purpose n stored in bit n-1.
+	Purposes uint32
+}
+
+// PurposeCount returns the number of purposes consented to.
+func (c *TCFConsent) PurposeCount() int {
+	return bits.OnesCount32(c.Purposes)
+}
+
+// HasPurpose returns true if purpose n (counting from 1) was consented to.
+func (c *TCFConsent) HasPurpose(n int) bool {
+	return n >= 1 && n <= 24 && c.Purposes&(1<<uint(n-1)) != 0
+}
+
+// ParseTCF decodes the core segment of a TCF consent string, of version 1 or
+// 2 of the format.
+func ParseTCF(value string) (*TCFConsent, error) {
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		value = value[:i]
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, errInvalidTCF
+	}
+	r := &bitReader{b: b}
+
+	c := &TCFConsent{Version: r.int(6)}
+	if c.Version != 1 && c.Version != 2 {
+		return nil, errInvalidTCF
+	}
+
+	c.Created = deciseconds(r.uint(36))
+	c.LastUpdated = deciseconds(r.uint(36))
+	c.CMPID = r.int(12)
+	c.CMPVersion = r.int(12)
+	r.skip(6) // ConsentScreen
+	c.Language = r.letter() + r.letter()
+	c.VendorListVersion = r.int(12)
+
+	if c.Version == 2 {
+		r.skip(6 + 1 + 1 + 12) // TcfPolicyVersion through SpecialFeatureOptIns
+	}
+
+	for n := 0; n < 24; n++ {
+		if r.uint(1) == 1 {
+			c.Purposes |= 1 << uint(n)
+		}
+	}
+
+	if r.short {
+		return nil, errInvalidTCF
+	}
+	return c, nil
+}
+
+// DetectTCF returns the consent held by a cookie, if it stores a TCF consent
+// string. Cookies with a conventional name, such as "euconsent-v2", only need
+// a value ParseTCF accepts, while other cookies must also have a plausible
+// CMP ID and consent language, to avoid mistaking arbitrary base64 values for
+// consent strings.
+func DetectTCF(c *Cookie) (*TCFConsent, bool) {
+	consent, err := ParseTCF(c.Value)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, name := range tcfCookieNames {
+		if strings.EqualFold(c.Name, name) {
+			return consent, true
+		}
+	}
+
+	if consent.CMPID == 0 || !isUpperAlpha(consent.Language) {
+		return nil, false
+	}
+	return consent, true
+}
+
+// deciseconds converts a TCF timestamp to a time.Time.
+func deciseconds(ds uint64) time.Time {
+	return time.Unix(int64(ds/10), int64(ds%10)*int64(100*time.Millisecond)).UTC()
+}
+
+// isUpperAlpha returns true if s is non-empty and consists of upper case
+// letters only.
+func isUpperAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// A bitReader reads big-endian bit fields from a byte slice. Reading past the
+// end yields zero bits and sets short.
+type bitReader struct {
+	b     []byte
+	off   int
+	short bool
+}
+
+// uint reads an n-bit unsigned integer.
+func (r *bitReader) uint(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.off/8 >= len(r.b) {
+			r.short = true
+		} else if r.b[r.off/8]&(0x80>>uint(r.off%8)) != 0 {
+			v |= 1
+		}
+		r.off++
+	}
+	return v
+}
+
+// int reads an n-bit unsigned integer as an int.
+func (r *bitReader) int(n int) int {
+	return int(r.uint(n))
+}
+
+// skip skips n bits.
+func (r *bitReader) skip(n int) {
+	r.uint(n)
+}
+
+// letter reads a 6-bit letter, where 0 is 'A'. Values beyond 'Z' are returned
+// as '?'.
+func (r *bitReader) letter() string {
+	v := r.uint(6)
+	if v > 25 {
+		return "?"
+	}
+	return string(rune('A' + v))
+}
@@ -0,0 +1,113 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// tcfString builds a consent string from a list of bit fields, given as
+// alternating widths and values.
+func tcfString(fields ...uint64) string {
+	var b []byte
+	var n int
+	for i := 0; i < len(fields); i += 2 {
+		width, v := int(fields[i]), fields[i+1]
+		for j := width - 1; j >= 0; j-- {
+			if n%8 == 0 {
+				b = append(b, 0)
+			}
+			if v&(1<<uint(j)) != 0 {
+				b[n/8] |= 0x80 >> uint(n%8)
+			}
+			n++
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestParseTCF(t *testing.T) {
+	created := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	ds := uint64(created.Unix() * 10)
+
+	v2 := tcfString(6, 2, 36, ds, 36, ds+5, 12, 10, 12, 3, 6, 1, 6, 4, 6, 13, 12, 48,
+		6, 2, 1, 0, 1, 0, 12, 0, 24, 0xd00001, 24, 0)
+	v1 := tcfString(6, 1, 36, ds, 36, ds, 12, 7, 12, 1, 6, 0, 6, 3, 6, 13, 12, 150, 24, 0xff0000, 16, 0)
+
+	tests := []struct {
+		in  string
+		out *TCFConsent
+	}{
+		{v2 + ".YAAAAAAAAAA", &TCFConsent{
+			Version:           2,
+			Created:           created,
+			LastUpdated:       created.Add(500 * time.Millisecond),
+			CMPID:             10,
+			CMPVersion:        3,
+			Language:          "EN",
+			VendorListVersion: 48,
+			Purposes:          1<<0 | 1<<1 | 1<<3 | 1<<23,
+		}},
+		{v1, &TCFConsent{
+			Version:           1,
+			Created:           created,
+			LastUpdated:       created,
+			CMPID:             7,
+			CMPVersion:        1,
+			Language:          "DN",
+			VendorListVersion: 150,
+			Purposes:          0xff,
+		}},
+		{tcfString(6, 3, 36, ds), nil},
+		{v2[:10], nil},
+		{"not base64!", nil},
+	}
+
+	for _, test := range tests {
+		out, err := ParseTCF(test.in)
+		if test.out == nil {
+			if err == nil {
+				t.Errorf("ParseTCF(%q) = %+v, want error", test.in, out)
+			}
+			continue
+		}
+		if err != nil || *out != *test.out {
+			t.Errorf("ParseTCF(%q):", test.in)
+			t.Errorf("  got  %+v, %v", out, err)
+			t.Errorf("  want %+v, %v", test.out, nil)
+		}
+	}
+}
+
+func TestDetectTCF(t *testing.T) {
+	ds := uint64(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC).Unix() * 10)
+	consent := tcfString(6, 2, 36, ds, 36, ds, 12, 10, 12, 3, 6, 1, 6, 4, 6, 13, 12, 48,
+		6, 2, 1, 0, 1, 0, 12, 0, 24, 0xe00000, 24, 0)
+	anonymous := tcfString(6, 2, 36, ds, 36, ds, 12, 0, 12, 0, 6, 0, 6, 31, 6, 31, 12, 0,
+		6, 0, 1, 0, 1, 0, 12, 0, 24, 0, 24, 0)
+
+	tests := []struct {
+		c  *Cookie
+		ok bool
+	}{
+		{&Cookie{Name: "euconsent-v2", Value: consent}, true},
+		{&Cookie{Name: "consent", Value: consent}, true},
+		{&Cookie{Name: "EUCONSENT", Value: anonymous}, true},
+		{&Cookie{Name: "consent", Value: anonymous}, false},
+		{&Cookie{Name: "euconsent-v2", Value: "1"}, false},
+	}
+
+	for _, test := range tests {
+		if _, ok := DetectTCF(test.c); ok != test.ok {
+			t.Errorf("DetectTCF(%+v) returned %v, want %v", test.c, ok, test.ok)
+		}
+	}
+
+	c, _ := DetectTCF(tests[0].c)
+	if n := c.PurposeCount(); n != 3 {
+		t.Errorf("PurposeCount() = %d, want 3", n)
+	}
+	if !c.HasPurpose(1) || !c.HasPurpose(3) || c.HasPurpose(4) || c.HasPurpose(0) || c.HasPurpose(25) {
+		t.Errorf("HasPurpose returned wrong results for %024b", c.Purposes)
+	}
+}
@@ -0,0 +1,181 @@
+package cookie
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// A SharedValue is a cookie value, or a token within one, stored by cookies
+// of more than one registrable domain, as found by Jar.SharedValues. Such
+// values are a strong signal of identifiers being synced between sites for
+// cross-site tracking.
+type SharedValue struct {
+	// The shared value, or token within the entries' values.
+	Value string
+
+	// Identical is true if the entries' values are all identical, and false
+	// if they merely contain Value.
+	Identical bool
+
+	// The distinct domain roots of the entries, sorted.
+	Roots []string
+
+	// Copies of the entries holding the value, sorted by domain root,
+	// domain, path and name.
+	Entries []Entry
+}
+
+// SharedValueOptions controls the analysis done by Jar.SharedValues.
+type SharedValueOptions struct {
+	// MinLength is the minimum length of tokens considered, with shorter
+	// tokens being too likely to be shared by coincidence. Zero means a
+	// default of 12.
+	MinLength int
+}
+
+// defaultMinShared is the default value of SharedValueOptions.MinLength.
+const defaultMinShared = 12
+
+// SharedValues finds identical or near-identical values stored by unexpired
+// cookies of different domain roots. Values are compared as a whole, and by
+// the tokens they consist of when split at characters such as '.', ':', '|'
+// and '&', so that an identifier embedded in differently formatted values,
+// such as "GA1.2.1234567890123" and "uid=1234567890123", is found as well.
+// Tokens consisting solely of letters are ignored, as they tend to be words
+// rather than identifiers.
+//
+// Findings are sorted by the number of domain roots involved, in descending
+// order, then by value. When several tokens are shared by the same set of
+// entries, only the longest is reported. Like ClassifyValue, SharedValues
+// relies on heuristics, and is meant for reports and audits. Passing nil
+// options is equivalent to passing a zero SharedValueOptions.
+func (j *Jar) SharedValues(now time.Time, opts *SharedValueOptions) []SharedValue {
+	minLen := defaultMinShared
+	if opts != nil && opts.MinLength > 0 {
+		minLen = opts.MinLength
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	holders := make(map[string][]*jarEntry)
+
+	for _, entry := range j.sorted() {
+		if !entry.Expires.IsZero() && !entry.Expires.After(now) {
+			continue
+		}
+		for _, token := range valueTokens(entry.Value, minLen) {
+			holders[token] = append(holders[token], entry)
+		}
+	}
+
+	// Keep the longest token for each set of entries spanning several roots.
+	best := make(map[string]string)
+
+	for token, entries := range holders {
+		if len(entryRoots(entries)) < 2 {
+			continue
+		}
+
+		var key strings.Builder
+		for _, entry := range entries {
+			key.WriteString(entry.Root + "\x00" + entry.Key + "\x00")
+		}
+
+		prev, ok := best[key.String()]
+		if !ok || len(token) > len(prev) || len(token) == len(prev) && token < prev {
+			best[key.String()] = token
+		}
+	}
+
+	var shared []SharedValue
+
+	for _, token := range best {
+		entries := holders[token]
+
+		s := SharedValue{
+			Value:     token,
+			Identical: true,
+			Roots:     entryRoots(entries),
+			Entries:   make([]Entry, len(entries)),
+		}
+		for i, entry := range entries {
+			s.Entries[i] = entry.Entry
+			s.Identical = s.Identical && entry.Value == token
+		}
+
+		shared = append(shared, s)
+	}
+
+	sort.Slice(shared, func(a, b int) bool {
+		if x, y := len(shared[a].Roots), len(shared[b].Roots); x != y {
+			return x > y
+		}
+		return shared[a].Value < shared[b].Value
+	})
+
+	return shared
+}
+
+// valueTokens returns the distinct tokens of a cookie value at least minLen
+// characters long and not consisting solely of letters, including the value
+// itself.
+func valueTokens(value string, minLen int) []string {
+	if isQuoted(value) {
+		value = value[1 : len(value)-1]
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(".:|&=,/+;% ", r)
+	})
+	fields = append(fields, value)
+
+	var tokens []string
+	for _, f := range fields {
+		if len(f) < minLen || isLetters(f) {
+			continue
+		}
+
+		dup := false
+		for _, t := range tokens {
+			dup = dup || t == f
+		}
+		if !dup {
+			tokens = append(tokens, f)
+		}
+	}
+
+	return tokens
+}
+
+// isLetters returns true if s consists of ASCII letters only.
+func isLetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// entryRoots returns the distinct domain roots of entries, sorted. Entries
+// without a root, such as those set by IP address hosts, count by domain.
+func entryRoots(entries []*jarEntry) []string {
+	var roots []string
+	for _, entry := range entries {
+		root := entry.Root
+		if root == "" {
+			root = entry.Domain
+		}
+
+		i := sort.SearchStrings(roots, root)
+		if i < len(roots) && roots[i] == root {
+			continue
+		}
+		roots = append(roots, "")
+		copy(roots[i+1:], roots[i:])
+		roots[i] = root
+	}
+	return roots
+}
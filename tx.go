@@ -0,0 +1,125 @@
+package cookie
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errTxDone = errors.New("transaction has already been committed or rolled back")
+)
+
+// A Tx is a set of jar modifications applied atomically on Commit.
+type Tx struct {
+	j    *Jar
+	ops  []txOp
+	err  error
+	done bool
+}
+
+// txOp is a single operation queued in a transaction. Removals queued by
+// Remove have a match function instead of an entry, since the entries to
+// remove can only be known when the transaction is committed.
+type txOp struct {
+	entry  *jarEntry
+	remove bool
+	match  func(entry *jarEntry) bool
+	now    time.Time
+}
+
+// Begin starts a new transaction. The jar isn't modified until the
+// transaction is committed.
+func (j *Jar) Begin() *Tx {
+	return &Tx{j: j}
+}
+
+// SetCookie queues a SetCookie operation. The cookie is validated right away,
+// and if it's invalid the error is returned and the transaction will fail to
+// commit.
+func (tx *Tx) SetCookie(scheme, host, path string, c *Cookie, now time.Time) error {
+	if tx.done {
+		return errTxDone
+	}
+
+	tx.j.mu.RLock()
+	entry, remove, err := tx.j.prepare(scheme, host, "", c, now)
+	tx.j.mu.RUnlock()
+
+	if err != nil {
+		tx.j.mu.Lock()
+		tx.j.reject(host, c, err, now)
+		tx.j.mu.Unlock()
+
+		if tx.err == nil {
+			tx.err = err
+		}
+		return err
+	}
+
+	if entry != nil {
+		tx.ops = append(tx.ops, txOp{entry: entry, remove: remove, now: now})
+	}
+	return nil
+}
+
+// Remove queues the removal of the entries identified by domain, path and
+// name, which like Jar.Remove includes both the host-only and the domain
+// cookie if the jar keeps them separate, and partitioned cookies of every
+// partition. The entries are looked up when the transaction is committed.
+// Like Jar.Remove, domain is canonicalized, and an error is returned if it
+// isn't a valid host.
+func (tx *Tx) Remove(domain, path, name string, now time.Time) error {
+	if tx.done {
+		return errTxDone
+	}
+
+	domain, err := CanonicalHost(strings.TrimPrefix(domain, "."))
+	if err != nil {
+		return err
+	}
+
+	tx.ops = append(tx.ops, txOp{remove: true, match: identifiedBy(domain, path, name), now: now})
+	return nil
+}
+
+// Commit applies all queued operations. If any of the operations failed
+// validation, no changes are made and the first error is returned. Observers
+// are notified of all changes in a single call.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return errTxDone
+	}
+
+	tx.done = true
+
+	if tx.err != nil {
+		return tx.err
+	}
+
+	tx.j.mu.Lock()
+	defer tx.j.mu.Unlock()
+
+	tx.j.batched(func() {
+		for _, op := range tx.ops {
+			if op.match == nil {
+				tx.j.store(op.entry, op.remove, op.now)
+				continue
+			}
+
+			for _, entry := range tx.j.sorted() {
+				if op.match(entry) {
+					tx.j.remove(entry, op.now)
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// Rollback discards all queued operations.
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.ops = nil
+}
@@ -0,0 +1,57 @@
+package cookie
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReadOnly is returned when attempting to modify a Jar through a View.
+var ErrReadOnly = errors.New("jar is read-only")
+
+// A View provides read-only access to a Jar, suitable for sharing with
+// components which should be able to inspect the jar's contents but not
+// modify them.
+type View struct {
+	j *Jar
+}
+
+// ReadOnly returns a read-only view of the jar. Changes made to the jar are
+// immediately visible through the view.
+func (j *Jar) ReadOnly() *View {
+	return &View{j}
+}
+
+// Cookies returns a slice of cookies relevant for the scheme, host and path
+// combination. Unlike Jar.Cookies, it leaves expired entries in place.
+func (v *View) Cookies(scheme, host, path string, now time.Time) ([]*Cookie, error) {
+	v.j.mu.RLock()
+	defer v.j.mu.RUnlock()
+
+	return v.j.cookies(scheme, host, path, now, false)
+}
+
+// SetCookie always returns ErrReadOnly.
+func (v *View) SetCookie(scheme, host, path string, c *Cookie, now time.Time) error {
+	return ErrReadOnly
+}
+
+// Apply always returns ErrReadOnly.
+func (v *View) Apply(changes []Change) error {
+	return ErrReadOnly
+}
+
+// Stats returns the number of entries in the jar by expiry class.
+func (v *View) Stats(now time.Time) Stats {
+	return v.j.Stats(now)
+}
+
+// Entries returns copies of all of the jar's entries.
+func (v *View) Entries() []Entry {
+	return v.j.Entries()
+}
+
+// EntriesFor returns copies of the entries relevant for the scheme, host and
+// path combination, as described for Jar.EntriesFor.
+func (v *View) EntriesFor(scheme, host, path string, now time.Time) ([]Entry, error) {
+	return v.j.EntriesFor(scheme, host, path, now)
+}